@@ -0,0 +1,95 @@
+package editor
+
+import (
+	"os"
+	"strconv"
+	"strings"
+)
+
+// FileArg is a file given on the command line with the position to open it at.
+// Line and Col count from 1, 0 meaning unspecified. Line is -1 for the last line.
+type FileArg struct {
+	Path string
+	Line int
+	Col  int
+}
+
+// Args are the parsed command line arguments.
+type Args struct {
+	// Filter writes the first buffer to stdout on exit.
+	Filter bool
+	Files  []FileArg
+}
+
+// ParseArgs parses the command line arguments. A file is given as "file", "file:line" or
+// "file:line:col", and an argument "+line" (or "+" for the last line) applies to the next file.
+// The file "-" is stdin. Arguments after "--" are all files.
+func ParseArgs(args []string) Args {
+	var a Args
+	line := 0
+	files := false
+	for _, arg := range args {
+		if !files {
+			switch arg {
+			case "--":
+				files = true
+				continue
+			case "--filter":
+				a.Filter = true
+				continue
+			}
+		}
+		if files {
+			a.Files = append(a.Files, FileArg{Path: arg, Line: line})
+			line = 0
+			continue
+		}
+		if arg == "+" {
+			line = -1
+			continue
+		}
+		if strings.HasPrefix(arg, "+") {
+			if n, err := strconv.Atoi(arg[1:]); err == nil && n > 0 {
+				line = n
+				continue
+			}
+		}
+		fa := parseFileArg(arg)
+		if line != 0 {
+			fa.Line = line
+			line = 0
+		}
+		a.Files = append(a.Files, fa)
+	}
+	return a
+}
+
+// parseFileArg splits "file:line:col". An existing file whose name looks like that is taken as is.
+func parseFileArg(arg string) FileArg {
+	if _, err := os.Stat(arg); err == nil {
+		return FileArg{Path: arg}
+	}
+	fa := FileArg{Path: arg}
+	var nums []int
+	p := arg
+	for len(nums) < 2 {
+		i := strings.LastIndexByte(p, ':')
+		if i <= 0 {
+			break
+		}
+		n, err := strconv.Atoi(p[i+1:])
+		if err != nil || n <= 0 {
+			break
+		}
+		nums = append([]int{n}, nums...)
+		p = p[:i]
+	}
+	if len(nums) > 0 {
+		fa.Path = p
+		fa.Line = nums[0]
+	}
+	if len(nums) > 1 {
+		fa.Col = nums[1]
+	}
+	return fa
+}
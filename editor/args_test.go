@@ -0,0 +1,67 @@
+package editor_test
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/mizuochikeita/re/editor"
+)
+
+func TestParseArgs(t *testing.T) {
+	tests := []struct {
+		desc string
+		args []string
+		want editor.Args
+	}{
+		{
+			desc: "no arguments",
+			args: nil,
+			want: editor.Args{},
+		},
+		{
+			desc: "files",
+			args: []string{"a.go", "b.go"},
+			want: editor.Args{Files: []editor.FileArg{{Path: "a.go"}, {Path: "b.go"}}},
+		},
+		{
+			desc: "line option",
+			args: []string{"+123", "a.go", "b.go"},
+			want: editor.Args{Files: []editor.FileArg{{Path: "a.go", Line: 123}, {Path: "b.go"}}},
+		},
+		{
+			desc: "last line option",
+			args: []string{"+", "a.go"},
+			want: editor.Args{Files: []editor.FileArg{{Path: "a.go", Line: -1}}},
+		},
+		{
+			desc: "line and column suffix",
+			args: []string{"a.go:123:4", "b.go:5"},
+			want: editor.Args{Files: []editor.FileArg{{Path: "a.go", Line: 123, Col: 4}, {Path: "b.go", Line: 5}}},
+		},
+		{
+			desc: "not a position",
+			args: []string{"a:b", "c:0"},
+			want: editor.Args{Files: []editor.FileArg{{Path: "a:b"}, {Path: "c:0"}}},
+		},
+		{
+			desc: "stdin",
+			args: []string{"-"},
+			want: editor.Args{Files: []editor.FileArg{{Path: "-"}}},
+		},
+		{
+			desc: "filter",
+			args: []string{"--filter", "a.go"},
+			want: editor.Args{Filter: true, Files: []editor.FileArg{{Path: "a.go"}}},
+		},
+		{
+			desc: "end of options",
+			args: []string{"+2", "--", "--filter", "a.go:3"},
+			want: editor.Args{Files: []editor.FileArg{{Path: "--filter", Line: 2}, {Path: "a.go:3"}}},
+		},
+	}
+	for _, tt := range tests {
+		if diff := cmp.Diff(tt.want, editor.ParseArgs(tt.args)); diff != "" {
+			t.Errorf("%s: %s", tt.desc, diff)
+		}
+	}
+}
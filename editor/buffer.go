@@ -0,0 +1,499 @@
+package editor
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+// Buffer is a text being edited. Windows show buffers through their own Screen.
+type Buffer struct {
+	Name  string
+	Path  string
+	Lines []string
+	Dirty bool
+	// LineEnding is the line terminator of the file, "LF" or "CRLF".
+	LineEnding string
+	Encoding   string
+	// FinalNewline is whether the last line ends with a line ending.
+	FinalNewline bool
+	// BOM is whether the file starts with a byte order mark.
+	BOM bool
+	// ReadOnly buffers cannot be edited or saved.
+	ReadOnly bool
+	// NoWrap shows each line in a single row scrolled horizontally.
+	NoWrap bool
+	Wrap   WrapOptions
+	Indent IndentOptions
+	// LineNumbers is how the gutter shows line numbers.
+	LineNumbers LineNumbers
+	// Marks are the named positions in the text.
+	Marks map[rune]Mark
+	// TrimTrailingWhitespace removes the spaces at the end of the lines when the buffer is saved.
+	TrimTrailingWhitespace bool
+	// Ruler is the column after which a line is too long, marked in the windows, or 0.
+	Ruler int
+
+	// HexMode shows Data, the bytes of the buffer, instead of Lines.
+	HexMode bool
+	Data    []byte
+
+	// Large is set for a file too large to be read as a whole. Lines or Data then hold the part
+	// of the file loaded around the cursor, starting at the line FirstLine or the byte Offset.
+	Large     *LargeFile
+	FirstLine int
+	Offset    int64
+	regionEnd bool
+
+	// undo holds the groups of edits which can be undone, the last one last, and redo the groups
+	// undone. undoOpen is whether the next edit joins the last group, and savedUndo is the number
+	// of groups in undo when the text was saved, or -1 if undoing cannot get back to it.
+	undo      [][]edit
+	redo      [][]edit
+	undoOpen  bool
+	savedUndo int
+
+	// Line, Col and Vscroll remember where the buffer was viewed last.
+	Line    int
+	Col     int
+	Vscroll int
+}
+
+// ErrReadOnly is returned when a read-only buffer is edited or saved.
+var ErrReadOnly = errors.New("buffer is read-only")
+
+func NewBuffer(name string) *Buffer {
+	return &Buffer{
+		Name:         name,
+		Lines:        []string{""},
+		LineEnding:   "LF",
+		Encoding:     "UTF-8",
+		FinalNewline: true,
+	}
+}
+
+func (b *Buffer) Load(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := b.Read(f); err != nil {
+		return err
+	}
+	b.Name = filepath.Base(path)
+	b.Path = path
+	return nil
+}
+
+// Read replaces the text of the buffer with the contents of rd in the encoding detected from them.
+func (b *Buffer) Read(rd io.Reader) error {
+	data, err := io.ReadAll(rd)
+	if err != nil {
+		return err
+	}
+	enc, _ := DetectEncoding(data)
+	sample := data
+	if len(sample) > binarySample {
+		sample = sample[:binarySample]
+	}
+	if IsBinary(sample, enc) {
+		// Binary files are shown read-only, like large ones.
+		b.Lines = []string{""}
+		b.Data = data
+		b.HexMode = true
+		b.ReadOnly = true
+		b.Dirty = false
+		return nil
+	}
+	return b.Decode(data, enc)
+}
+
+// LoadLarge opens the large file at path read-only, and starts indexing its lines.
+// notify is called when the index is built.
+func (b *Buffer) LoadLarge(path string, notify func()) error {
+	lf, err := OpenLargeFile(path)
+	if err != nil {
+		return err
+	}
+	sample, err := lf.ReadBytes(0, binarySample)
+	if err != nil {
+		lf.Close()
+		return err
+	}
+	enc, _ := DetectEncoding(sample)
+	b.Name = filepath.Base(path)
+	b.Path = path
+	b.Large = lf
+	b.ReadOnly = true
+	b.Lines = []string{""}
+	// Lines are split at newlines, which does not work for UTF-16.
+	if strings.HasPrefix(enc, "UTF-16") || IsBinary(sample, enc) {
+		b.HexMode = true
+		return b.LoadRegion(0)
+	}
+	b.Encoding = enc
+	if bytes.HasSuffix(bytes.SplitN(sample, []byte("\n"), 2)[0], []byte("\r")) {
+		b.LineEnding = "CRLF"
+	}
+	go lf.BuildIndex(notify)
+	return b.LoadRegion(0)
+}
+
+// LoadRegion loads the part of a large file around pos, a line, or a byte offset in the hex mode.
+func (b *Buffer) LoadRegion(pos int64) error {
+	if b.HexMode {
+		off := pos - regionBytes/2
+		if off < 0 {
+			off = 0
+		}
+		off &^= 15 // keeps the rows of the hex layout at the same offsets
+		data, err := b.Large.ReadBytes(off, regionBytes)
+		if err != nil {
+			return err
+		}
+		b.Data, b.Offset = data, off
+		b.regionEnd = off+int64(len(data)) >= b.Large.Size
+		return nil
+	}
+	first := int(pos) - regionLines/2
+	if first < 0 {
+		first = 0
+	}
+	lines, end, err := b.Large.ReadLines(first, regionLines)
+	if err != nil {
+		return err
+	}
+	if len(lines) == 0 {
+		return nil
+	}
+	b.Lines = make([]string, len(lines))
+	for i, l := range lines {
+		if b.LineEnding == "CRLF" {
+			l = bytes.TrimSuffix(l, []byte("\r"))
+		}
+		b.Lines[i], _ = Decode(b.Encoding, l)
+	}
+	b.FirstLine = first
+	b.regionEnd = end
+	return nil
+}
+
+// RegionEdge reports whether the line, or the byte offset in the hex mode, is close to an end
+// of the region of a large file loaded, with more of the file beyond it.
+func (b *Buffer) RegionEdge(pos int64, margin int) bool {
+	if b.HexMode {
+		m := int64(margin) * 16
+		return b.Offset > 0 && pos-b.Offset < m || !b.regionEnd && b.Offset+int64(len(b.Data))-pos < m
+	}
+	line := int(pos) - b.FirstLine
+	return b.FirstLine > 0 && line < margin || !b.regionEnd && len(b.Lines)-line < margin
+}
+
+// Decode replaces the text of the buffer with data in the encoding enc, and records its
+// line ending, final newline and byte order mark so that WriteTo writes the same bytes back.
+// A file mixing LF and CRLF is taken as LF with the CRs kept in the text.
+func (b *Buffer) Decode(data []byte, enc string) error {
+	bom := encodingBOM(enc)
+	hasBOM := bom != "" && bytes.HasPrefix(data, []byte(bom))
+	if hasBOM {
+		data = data[len(bom):]
+	}
+	s, err := Decode(enc, data)
+	if err != nil {
+		return err
+	}
+	b.Encoding = enc
+	b.BOM = hasBOM
+	b.FinalNewline = strings.HasSuffix(s, "\n")
+	s = strings.TrimSuffix(s, "\n")
+	lines := strings.Split(s, "\n")
+
+	// Only the lines followed by a newline have a line ending.
+	terminated := lines
+	if !b.FinalNewline {
+		terminated = lines[:len(lines)-1]
+	}
+	b.LineEnding = "LF"
+	if len(terminated) > 0 {
+		b.LineEnding = "CRLF"
+		for _, l := range terminated {
+			if !strings.HasSuffix(l, "\r") {
+				b.LineEnding = "LF"
+				break
+			}
+		}
+	}
+	if b.LineEnding == "CRLF" {
+		for i := range terminated {
+			terminated[i] = strings.TrimSuffix(terminated[i], "\r")
+		}
+	}
+	b.Lines = lines
+	b.Dirty = false
+	b.clearUndo()
+	return nil
+}
+
+func (b *Buffer) Save() error {
+	if b.Path == "" {
+		return errors.New("buffer has no file")
+	}
+	if b.ReadOnly {
+		return ErrReadOnly
+	}
+	f, err := os.OpenFile(b.Path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if _, err := b.WriteTo(f); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	b.Dirty = false
+	b.savedUndo = len(b.undo)
+	b.endUndoGroup()
+	return nil
+}
+
+// WriteTo writes the text of the buffer to w as it is saved to a file.
+func (b *Buffer) WriteTo(w io.Writer) (int64, error) {
+	if b.Large != nil {
+		return io.Copy(w, io.NewSectionReader(b.Large.f, 0, b.Large.Size))
+	}
+	if b.HexMode {
+		n, err := w.Write(b.Data)
+		return int64(n), err
+	}
+	eol := b.Terminator()
+	s := strings.Join(b.Lines, eol)
+	if b.FinalNewline {
+		s += eol
+	}
+	data, err := Encode(b.Encoding, s)
+	if err != nil {
+		return 0, err
+	}
+	if b.BOM {
+		data = append([]byte(encodingBOM(b.Encoding)), data...)
+	}
+	n, err := w.Write(data)
+	return int64(n), err
+}
+
+// Terminator returns the characters ending the lines in the file.
+func (b *Buffer) Terminator() string {
+	if b.LineEnding == "CRLF" {
+		return "\r\n"
+	}
+	return "\n"
+}
+
+// Change describes an edit which replaced the text from (Line, Col) to (OldEndLine, OldEndCol)
+// with a text ending at (NewEndLine, NewEndCol). In the hex mode, the lines are 0 and the columns
+// are byte offsets in Data.
+type Change struct {
+	Line       int
+	Col        int
+	OldEndLine int
+	OldEndCol  int
+	NewEndLine int
+	NewEndCol  int
+}
+
+// Adjust maps a position before the change to the same text after it.
+// Positions inside the replaced text move to its start.
+func (c Change) Adjust(line, col int) (int, int) {
+	switch {
+	case line < c.Line || line == c.Line && col <= c.Col:
+		return line, col
+	case line == c.OldEndLine && col >= c.OldEndCol:
+		return c.NewEndLine, c.NewEndCol + col - c.OldEndCol
+	case line > c.OldEndLine:
+		return line + c.NewEndLine - c.OldEndLine, col
+	default:
+		return c.Line, c.Col
+	}
+}
+
+// Insert inserts s, which may contain newlines, at the rune offset col in line.
+func (b *Buffer) Insert(line, col int, s string) Change {
+	l := b.Lines[line]
+	i := runeIndex(l, col)
+	ins := strings.Split(s, "\n")
+	last := len(ins) - 1
+	endCol := utf8.RuneCountInString(ins[last])
+	if last == 0 {
+		endCol += col
+	}
+	ins[0] = l[:i] + ins[0]
+	ins[last] += l[i:]
+	b.replaceLines(line, line, ins)
+	c := b.changed(Change{
+		Line:       line,
+		Col:        col,
+		OldEndLine: line,
+		OldEndCol:  col,
+		NewEndLine: line + last,
+		NewEndCol:  endCol,
+	})
+	b.record(c, "", s)
+	return c
+}
+
+// Delete removes the text from (line, col) up to (endLine, endCol) and returns it.
+func (b *Buffer) Delete(line, col, endLine, endCol int) (string, Change) {
+	text := b.Text(line, col, endLine, endCol)
+	head := b.Lines[line][:runeIndex(b.Lines[line], col)]
+	tail := b.Lines[endLine][runeIndex(b.Lines[endLine], endCol):]
+	b.replaceLines(line, endLine, []string{head + tail})
+	c := b.changed(Change{
+		Line:       line,
+		Col:        col,
+		OldEndLine: endLine,
+		OldEndCol:  endCol,
+		NewEndLine: line,
+		NewEndCol:  col,
+	})
+	b.record(c, text, "")
+	return text, c
+}
+
+// Replace replaces the text from (line, col) up to (endLine, endCol) with s in a single change.
+func (b *Buffer) Replace(line, col, endLine, endCol int, s string) Change {
+	old := b.Text(line, col, endLine, endCol)
+	c := b.replace(line, col, endLine, endCol, s)
+	b.record(c, old, s)
+	return c
+}
+
+// replace is Replace without recording the edit for undo.
+func (b *Buffer) replace(line, col, endLine, endCol int, s string) Change {
+	head := b.Lines[line][:runeIndex(b.Lines[line], col)]
+	tail := b.Lines[endLine][runeIndex(b.Lines[endLine], endCol):]
+	ins := strings.Split(s, "\n")
+	last := len(ins) - 1
+	newEndCol := utf8.RuneCountInString(ins[last])
+	if last == 0 {
+		newEndCol += col
+	}
+	ins[0] = head + ins[0]
+	ins[last] += tail
+	b.replaceLines(line, endLine, ins)
+	return b.changed(Change{
+		Line:       line,
+		Col:        col,
+		OldEndLine: endLine,
+		OldEndCol:  endCol,
+		NewEndLine: line + last,
+		NewEndCol:  newEndCol,
+	})
+}
+
+// SetByte overwrites the byte at off in Data with v, or appends v if off is the length of Data.
+func (b *Buffer) SetByte(off int, v byte) Change {
+	end := off
+	if off < len(b.Data) {
+		b.Data[off] = v
+		end++
+	} else {
+		b.Data = append(b.Data, v)
+	}
+	return b.changed(Change{
+		Col:       off,
+		OldEndCol: end,
+		NewEndCol: off + 1,
+	})
+}
+
+// DeleteBytes removes the bytes of Data from off up to end.
+func (b *Buffer) DeleteBytes(off, end int) Change {
+	b.Data = append(b.Data[:off], b.Data[end:]...)
+	return b.changed(Change{
+		Col:       off,
+		OldEndCol: end,
+		NewEndCol: off,
+	})
+}
+
+// EnterHexMode replaces the text of the buffer with its bytes as they are saved.
+func (b *Buffer) EnterHexMode() error {
+	var buf bytes.Buffer
+	if _, err := b.WriteTo(&buf); err != nil {
+		return err
+	}
+	b.Data = buf.Bytes()
+	b.Lines = []string{""}
+	b.HexMode = true
+	return nil
+}
+
+// LeaveHexMode decodes the bytes of the buffer back into text in its encoding.
+func (b *Buffer) LeaveHexMode() error {
+	dirty := b.Dirty
+	if err := b.Decode(b.Data, b.Encoding); err != nil {
+		return err
+	}
+	b.Data = nil
+	b.HexMode = false
+	if dirty {
+		b.modified()
+	}
+	return nil
+}
+
+// replaceLines replaces the lines from line to endLine with lines.
+// Replacing a line with another does not copy the other lines, keeping edits fast in large buffers.
+func (b *Buffer) replaceLines(line, endLine int, lines []string) {
+	if len(lines) == endLine-line+1 {
+		copy(b.Lines[line:], lines)
+		return
+	}
+	ls := make([]string, 0, len(b.Lines)-(endLine-line+1)+len(lines))
+	ls = append(ls, b.Lines[:line]...)
+	ls = append(ls, lines...)
+	ls = append(ls, b.Lines[endLine+1:]...)
+	b.Lines = ls
+}
+
+// Text returns the text from (line, col) up to (endLine, endCol).
+func (b *Buffer) Text(line, col, endLine, endCol int) string {
+	first := b.Lines[line]
+	if line == endLine {
+		return first[runeIndex(first, col):runeIndex(first, endCol)]
+	}
+	last := b.Lines[endLine]
+	ss := []string{first[runeIndex(first, col):]}
+	ss = append(ss, b.Lines[line+1:endLine]...)
+	ss = append(ss, last[:runeIndex(last, endCol)])
+	return strings.Join(ss, "\n")
+}
+
+// LineLen returns the number of runes in line.
+func (b *Buffer) LineLen(line int) int {
+	return utf8.RuneCountInString(b.Lines[line])
+}
+
+// runeIndex returns the byte index of the rune offset col in s.
+func runeIndex(s string, col int) int {
+	for i := range s {
+		if col == 0 {
+			return i
+		}
+		col--
+	}
+	return len(s)
+}
+
+func (b *Buffer) changed(c Change) Change {
+	b.Dirty = true
+	b.Line, b.Col = c.Adjust(b.Line, b.Col)
+	return c
+}
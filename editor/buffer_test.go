@@ -0,0 +1,271 @@
+package editor_test
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/mizuochikeita/re/editor"
+)
+
+func TestBuffer(t *testing.T) {
+	t.Run("Load()", func(t *testing.T) {
+		b := editor.NewBuffer("")
+		if err := b.Load("sample.txt"); err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff("sample.txt", b.Name); diff != "" {
+			t.Error(diff)
+		}
+		if diff := cmp.Diff([]string{"hello world", "bye world"}, b.Lines); diff != "" {
+			t.Error(diff)
+		}
+	})
+
+	t.Run("Insert()", func(t *testing.T) {
+		tests := []struct {
+			desc       string
+			lines      []string
+			line       int
+			col        int
+			text       string
+			wantLines  []string
+			wantChange editor.Change
+		}{
+			{
+				desc:       "insert runes",
+				lines:      []string{"ac"},
+				line:       0,
+				col:        1,
+				text:       "b",
+				wantLines:  []string{"abc"},
+				wantChange: editor.Change{Line: 0, Col: 1, OldEndLine: 0, OldEndCol: 1, NewEndLine: 0, NewEndCol: 2},
+			},
+			{
+				desc:       "split line",
+				lines:      []string{"abcd", "e"},
+				line:       0,
+				col:        2,
+				text:       "\n",
+				wantLines:  []string{"ab", "cd", "e"},
+				wantChange: editor.Change{Line: 0, Col: 2, OldEndLine: 0, OldEndCol: 2, NewEndLine: 1, NewEndCol: 0},
+			},
+			{
+				desc:       "insert lines",
+				lines:      []string{"ad"},
+				line:       0,
+				col:        1,
+				text:       "b\nc",
+				wantLines:  []string{"ab", "cd"},
+				wantChange: editor.Change{Line: 0, Col: 1, OldEndLine: 0, OldEndCol: 1, NewEndLine: 1, NewEndCol: 1},
+			},
+		}
+		for _, tt := range tests {
+			b := editor.NewBuffer("test")
+			b.Lines = tt.lines
+			got := b.Insert(tt.line, tt.col, tt.text)
+			if diff := cmp.Diff(tt.wantLines, b.Lines); diff != "" {
+				t.Errorf("%s: %s", tt.desc, diff)
+			}
+			if diff := cmp.Diff(tt.wantChange, got); diff != "" {
+				t.Errorf("%s: %s", tt.desc, diff)
+			}
+			if diff := cmp.Diff(true, b.Dirty); diff != "" {
+				t.Errorf("%s: %s", tt.desc, diff)
+			}
+		}
+	})
+
+	t.Run("Delete()", func(t *testing.T) {
+		tests := []struct {
+			desc      string
+			lines     []string
+			pos       [4]int
+			wantLines []string
+			wantText  string
+		}{
+			{
+				desc:      "delete runes",
+				lines:     []string{"abcd"},
+				pos:       [4]int{0, 1, 0, 3},
+				wantLines: []string{"ad"},
+				wantText:  "bc",
+			},
+			{
+				desc:      "join lines",
+				lines:     []string{"ab", "cd", "ef"},
+				pos:       [4]int{0, 1, 2, 1},
+				wantLines: []string{"af"},
+				wantText:  "b\ncd\ne",
+			},
+		}
+		for _, tt := range tests {
+			b := editor.NewBuffer("test")
+			b.Lines = tt.lines
+			got, _ := b.Delete(tt.pos[0], tt.pos[1], tt.pos[2], tt.pos[3])
+			if diff := cmp.Diff(tt.wantLines, b.Lines); diff != "" {
+				t.Errorf("%s: %s", tt.desc, diff)
+			}
+			if diff := cmp.Diff(tt.wantText, got); diff != "" {
+				t.Errorf("%s: %s", tt.desc, diff)
+			}
+		}
+	})
+
+	t.Run("Replace()", func(t *testing.T) {
+		tests := []struct {
+			desc       string
+			lines      []string
+			pos        [4]int
+			text       string
+			wantLines  []string
+			wantChange editor.Change
+		}{
+			{
+				desc:       "replace runes",
+				lines:      []string{"abcd"},
+				pos:        [4]int{0, 1, 0, 3},
+				text:       "xyz",
+				wantLines:  []string{"axyzd"},
+				wantChange: editor.Change{Line: 0, Col: 1, OldEndLine: 0, OldEndCol: 3, NewEndLine: 0, NewEndCol: 4},
+			},
+			{
+				desc:       "replace lines",
+				lines:      []string{"ab", "cd", "ef"},
+				pos:        [4]int{0, 1, 2, 1},
+				text:       "x\ny",
+				wantLines:  []string{"ax", "yf"},
+				wantChange: editor.Change{Line: 0, Col: 1, OldEndLine: 2, OldEndCol: 1, NewEndLine: 1, NewEndCol: 1},
+			},
+		}
+		for _, tt := range tests {
+			b := editor.NewBuffer("test")
+			b.Lines = tt.lines
+			got := b.Replace(tt.pos[0], tt.pos[1], tt.pos[2], tt.pos[3], tt.text)
+			if diff := cmp.Diff(tt.wantLines, b.Lines); diff != "" {
+				t.Errorf("%s: %s", tt.desc, diff)
+			}
+			if diff := cmp.Diff(tt.wantChange, got); diff != "" {
+				t.Errorf("%s: %s", tt.desc, diff)
+			}
+		}
+	})
+
+	t.Run("Change.Adjust()", func(t *testing.T) {
+		// "ab|cd" -> "ab" "XY" "Zcd"
+		c := editor.Change{Line: 0, Col: 2, OldEndLine: 0, OldEndCol: 2, NewEndLine: 2, NewEndCol: 1}
+		tests := []struct {
+			desc string
+			pos  [2]int
+			want [2]int
+		}{
+			{desc: "before", pos: [2]int{0, 1}, want: [2]int{0, 1}},
+			{desc: "at change", pos: [2]int{0, 2}, want: [2]int{0, 2}},
+			{desc: "after on same line", pos: [2]int{0, 3}, want: [2]int{2, 2}},
+			{desc: "following line", pos: [2]int{1, 3}, want: [2]int{3, 3}},
+		}
+		for _, tt := range tests {
+			line, col := c.Adjust(tt.pos[0], tt.pos[1])
+			if diff := cmp.Diff(tt.want, [2]int{line, col}); diff != "" {
+				t.Errorf("%s: %s", tt.desc, diff)
+			}
+		}
+	})
+
+	t.Run("Save()", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "a.txt")
+		b := editor.NewBuffer("a.txt")
+		b.Path = path
+		b.Lines = []string{"a", "b"}
+		b.Dirty = true
+		if err := b.Save(); err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(false, b.Dirty); diff != "" {
+			t.Error(diff)
+		}
+		c := editor.NewBuffer("")
+		if err := c.Load(path); err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(b.Lines, c.Lines); diff != "" {
+			t.Error(diff)
+		}
+	})
+	t.Run("Read() and WriteTo()", func(t *testing.T) {
+		b := editor.NewBuffer("")
+		if err := b.Read(strings.NewReader("a\nb\n")); err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff([]string{"a", "b"}, b.Lines); diff != "" {
+			t.Error(diff)
+		}
+		var buf bytes.Buffer
+		if _, err := b.WriteTo(&buf); err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff("a\nb\n", buf.String()); diff != "" {
+			t.Error(diff)
+		}
+	})
+	t.Run("Read() round-trip", func(t *testing.T) {
+		tests := []struct {
+			desc             string
+			data             string
+			wantLines        []string
+			wantLineEnding   string
+			wantFinalNewline bool
+			wantBOM          bool
+		}{
+			{desc: "LF", data: "a\nb\n", wantLines: []string{"a", "b"}, wantLineEnding: "LF", wantFinalNewline: true},
+			{desc: "CRLF", data: "a\r\nb\r\n", wantLines: []string{"a", "b"}, wantLineEnding: "CRLF", wantFinalNewline: true},
+			{desc: "no final newline", data: "a\r\nb", wantLines: []string{"a", "b"}, wantLineEnding: "CRLF"},
+			{desc: "mixed", data: "a\r\nb\n", wantLines: []string{"a\r", "b"}, wantLineEnding: "LF", wantFinalNewline: true},
+			{desc: "BOM", data: "\xef\xbb\xbfa\n", wantLines: []string{"a"}, wantLineEnding: "LF", wantFinalNewline: true, wantBOM: true},
+			{desc: "empty", data: "", wantLines: []string{""}, wantLineEnding: "LF"},
+			{desc: "empty line", data: "\n", wantLines: []string{""}, wantLineEnding: "LF", wantFinalNewline: true},
+		}
+		for _, tt := range tests {
+			b := editor.NewBuffer("")
+			if err := b.Read(strings.NewReader(tt.data)); err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.wantLines, b.Lines); diff != "" {
+				t.Errorf("%s: %s", tt.desc, diff)
+			}
+			got := []interface{}{b.LineEnding, b.FinalNewline, b.BOM}
+			if diff := cmp.Diff([]interface{}{tt.wantLineEnding, tt.wantFinalNewline, tt.wantBOM}, got); diff != "" {
+				t.Errorf("%s: %s", tt.desc, diff)
+			}
+			var buf bytes.Buffer
+			if _, err := b.WriteTo(&buf); err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.data, buf.String()); diff != "" {
+				t.Errorf("%s: %s", tt.desc, diff)
+			}
+		}
+	})
+	t.Run("Read() in a legacy encoding", func(t *testing.T) {
+		data := sampleShiftJIS + "\r\n\xff\r\n"
+		b := editor.NewBuffer("")
+		if err := b.Read(strings.NewReader(data)); err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff([]string{sampleText, string(editor.EscapeRune(0xff))}, b.Lines); diff != "" {
+			t.Error(diff)
+		}
+		if diff := cmp.Diff([]string{"Shift_JIS", "CRLF"}, []string{b.Encoding, b.LineEnding}); diff != "" {
+			t.Error(diff)
+		}
+		var buf bytes.Buffer
+		if _, err := b.WriteTo(&buf); err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(data, buf.String()); diff != "" {
+			t.Error(diff)
+		}
+	})
+}
@@ -0,0 +1,197 @@
+package editor
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Command is a named editor operation which can be bound to keys or run from the command palette.
+type Command struct {
+	Name        string
+	Description string
+	Run         func(e *Editor)
+}
+
+func (e *Editor) RegisterCommand(c *Command) {
+	e.Commands[c.Name] = c
+}
+
+// Execute runs the command named name.
+func (e *Editor) Execute(name string) error {
+	c, ok := e.Commands[name]
+	if !ok {
+		return fmt.Errorf("unknown command: %s", name)
+	}
+	c.Run(e)
+	return nil
+}
+
+// CommandNames returns the names of the registered commands in alphabetical order.
+func (e *Editor) CommandNames() []string {
+	var names []string
+	for name := range e.Commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// PromptCommand reads a command name in the minibuffer and runs it.
+func (e *Editor) PromptCommand() {
+	m := e.Prompt("command", "M-x ", FuzzyCompleter(e.CommandNames()), func(name string, ok bool) {
+		if !ok || name == "" {
+			return
+		}
+		e.Error(e.Execute(name))
+	})
+	m.AcceptMatch = true
+	m.Annotate = func(name string) string {
+		return e.Commands[name].Description
+	}
+}
+
+var defaultCommands = []*Command{
+	{Name: "move-above", Description: "Move the cursor to the row above", Run: (*Editor).MoveAbove},
+	{Name: "move-below", Description: "Move the cursor to the row below", Run: (*Editor).MoveBelow},
+	{Name: "move-right", Description: "Move the cursor forward by a character", Run: (*Editor).MoveRight},
+	{Name: "move-left", Description: "Move the cursor backward by a character", Run: (*Editor).MoveLeft},
+	{Name: "move-beginning", Description: "Move the cursor to the beginning of the line", Run: (*Editor).MoveBeginning},
+	{Name: "move-end", Description: "Move the cursor to the end of the line", Run: (*Editor).MoveEnd},
+	{Name: "scroll-up", Description: "Move the cursor up by half a window", Run: func(e *Editor) {
+		e.MoveCursorRelative(0, -e.Screen.Height/2)
+	}},
+	{Name: "scroll-down", Description: "Move the cursor down by half a window", Run: func(e *Editor) {
+		e.MoveCursorRelative(0, e.Screen.Height/2)
+	}},
+	{Name: "move-buffer-start", Description: "Move the cursor to the beginning of the buffer", Run: (*Editor).MoveBufferStart},
+	{Name: "move-buffer-end", Description: "Move the cursor to the end of the buffer", Run: (*Editor).MoveBufferEnd},
+	{Name: "goto", Description: "Move the cursor to a line, a column or a byte offset", Run: (*Editor).PromptGoto},
+	{Name: "jump-back", Description: "Move the cursor back to the position before the last jump", Run: (*Editor).JumpBack},
+	{Name: "jump-forward", Description: "Move the cursor forward to the position jumped back from", Run: (*Editor).JumpForward},
+	{Name: "set-mark", Description: "Name the position of the cursor with a letter", Run: func(e *Editor) {
+		e.promptName("Set mark: ", e.SetMark)
+	}},
+	{Name: "jump-to-mark", Description: "Move the cursor to a named position", Run: func(e *Editor) {
+		e.promptName("Jump to mark: ", e.JumpToMark)
+	}},
+	{Name: "copy-line-to-register", Description: "Store the line of the cursor in a named register", Run: func(e *Editor) {
+		e.promptName("Copy line to register: ", e.CopyLineToRegister)
+	}},
+	{Name: "insert-register", Description: "Insert the text of a named register", Run: func(e *Editor) {
+		e.promptName("Insert register: ", e.InsertRegister)
+	}},
+	{Name: "newline", Description: "Break the line at the cursor, indenting the new line like it", Run: (*Editor).Newline},
+	{Name: "insert-tab", Description: "Insert a tab, or spaces up to the next indentation level", Run: (*Editor).InsertIndent},
+	{Name: "indent-line", Description: "Indent the current line by a level", Run: (*Editor).IndentLine},
+	{Name: "dedent-line", Description: "Dedent the current line by a level", Run: (*Editor).DedentLine},
+	{Name: "delete-backward-char", Description: "Delete the character before the cursor", Run: (*Editor).DeleteBackward},
+	{Name: "find-file", Description: "Open a file in a buffer", Run: (*Editor).PromptOpenFile},
+	{Name: "save-buffer", Description: "Save the current buffer to its file", Run: (*Editor).SaveBuffer},
+	{Name: "shell-command", Description: "Show the output of a shell command in a buffer", Run: func(e *Editor) {
+		e.promptShellCommand("Shell command: ", e.ShellCommand)
+	}},
+	{Name: "insert-shell-command", Description: "Insert the output of a shell command at the cursor", Run: func(e *Editor) {
+		e.promptShellCommand("Insert output of shell command: ", e.InsertShellCommand)
+	}},
+	{Name: "filter-buffer", Description: "Replace the current buffer with the output of a shell command reading it", Run: func(e *Editor) {
+		e.promptShellCommand("Filter buffer through shell command: ", e.FilterBuffer)
+	}},
+	{Name: "undo", Description: "Revert the last edit of the current buffer", Run: (*Editor).Undo},
+	{Name: "redo", Description: "Make the last edit undone again", Run: (*Editor).Redo},
+	{Name: "format-buffer", Description: "Pipe the current buffer through the formatter for its file type", Run: (*Editor).FormatBuffer},
+	{Name: "write-file", Description: "Save the current buffer to another file", Run: (*Editor).PromptSaveAs},
+	{Name: "switch-to-buffer", Description: "Show another buffer in the current window", Run: (*Editor).PromptSwitchBuffer},
+	{Name: "kill-buffer", Description: "Close a buffer", Run: (*Editor).PromptCloseBuffer},
+	{Name: "other-window", Description: "Select the next window", Run: (*Editor).OtherWindow},
+	{Name: "split-window-below", Description: "Split the current window into upper and lower ones", Run: func(e *Editor) {
+		e.SplitWindow(false)
+	}},
+	{Name: "split-window-right", Description: "Split the current window into left and right ones", Run: func(e *Editor) {
+		e.SplitWindow(true)
+	}},
+	{Name: "delete-window", Description: "Close the current window", Run: (*Editor).CloseWindow},
+	{Name: "convert-to-lf", Description: "Use LF line endings in the current buffer", Run: func(e *Editor) {
+		e.SetLineEnding("LF")
+	}},
+	{Name: "convert-to-crlf", Description: "Use CRLF line endings in the current buffer", Run: func(e *Editor) {
+		e.SetLineEnding("CRLF")
+	}},
+	{Name: "set-file-encoding", Description: "Convert the current buffer to another encoding", Run: (*Editor).PromptSetEncoding},
+	{Name: "reopen-with-encoding", Description: "Read the file of the current buffer again in another encoding", Run: (*Editor).PromptReopenWithEncoding},
+	{Name: "toggle-wrap", Description: "Switch between wrapping the lines of the current buffer and scrolling horizontally", Run: func(e *Editor) {
+		e.SetWrap(e.Buffer.NoWrap)
+	}},
+	{Name: "toggle-word-wrap", Description: "Switch between wrapping the lines of the current buffer at word boundaries and at any character", Run: func(e *Editor) {
+		e.SetWordWrap(!e.Buffer.Wrap.Word)
+	}},
+	{Name: "toggle-line-numbers", Description: "Show no line numbers, absolute ones or relative ones in the current buffer", Run: func(e *Editor) {
+		e.SetLineNumbers((e.Buffer.LineNumbers + 1) % LineNumbers(len(lineNumbersNames)))
+	}},
+	{Name: "toggle-hex-mode", Description: "Switch the current buffer between its text and a hex dump of its bytes", Run: func(e *Editor) {
+		e.Error(e.ToggleHexMode())
+	}},
+	{Name: "execute-command", Description: "Run a command by name", Run: (*Editor).PromptCommand},
+	{Name: "toggle-vi-mode", Description: "Switch between the modal vi input and the default one", Run: func(e *Editor) {
+		e.SetViMode(e.Vi == nil)
+	}},
+	{Name: "suspend", Description: "Stop the editor and return to the shell until it is resumed", Run: (*Editor).Suspend},
+	{Name: "quit", Description: "Exit the editor, asking whether to save the modified buffers", Run: (*Editor).Quit},
+}
+
+var defaultBindings = map[string]string{
+	"C-p":   "move-above",
+	"C-n":   "move-below",
+	"C-f":   "move-right",
+	"C-b":   "move-left",
+	"C-a":   "move-beginning",
+	"C-e":   "move-end",
+	"Up":    "move-above",
+	"Down":  "move-below",
+	"Right": "move-right",
+	"Left":  "move-left",
+	"C-u":   "scroll-up",
+	"C-d":   "scroll-down",
+	"RET":   "newline",
+	"TAB":   "insert-tab",
+	"S-TAB": "dedent-line",
+	"DEL":   "delete-backward-char",
+	"C-h":   "delete-backward-char",
+	"C-o":   "find-file",
+	"C-s":   "save-buffer",
+	"C-l":   "switch-to-buffer",
+	"C-k":   "kill-buffer",
+	"C-w":   "other-window",
+	"C-]":   "split-window-below",
+	"C-\\":  "split-window-right",
+	"C-^":   "delete-window",
+	"M-<":   "move-buffer-start",
+	"M->":   "move-buffer-end",
+	"M-g":   "goto",
+	"M-,":   "jump-back",
+	"M-.":   "jump-forward",
+	"M-!":   "shell-command",
+	"M-|":   "filter-buffer",
+	"M-x":   "execute-command",
+	"C-_":   "undo",
+	"M-_":   "redo",
+	"C-q":   "quit",
+	"C-z":   "suspend",
+
+	"C-x C-f": "find-file",
+	"C-x C-s": "save-buffer",
+	"C-x C-w": "write-file",
+	"C-x b":   "switch-to-buffer",
+	"C-x k":   "kill-buffer",
+	"C-x o":   "other-window",
+	"C-x 2":   "split-window-below",
+	"C-x 3":   "split-window-right",
+	"C-x 0":   "delete-window",
+	"C-x !":   "insert-shell-command",
+	"C-x u":   "undo",
+
+	"C-x r m": "set-mark",
+	"C-x r j": "jump-to-mark",
+	"C-x r s": "copy-line-to-register",
+	"C-x r i": "insert-register",
+	"C-x C-c": "quit",
+}
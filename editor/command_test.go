@@ -0,0 +1,55 @@
+package editor_test
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/mizuochikeita/re/editor"
+)
+
+func TestCommand(t *testing.T) {
+	t.Run("Execute()", func(t *testing.T) {
+		e := editor.New()
+		if err := e.OpenFile("sample.txt"); err != nil {
+			t.Fatal(err)
+		}
+		e.Layout.Arrange(0, 0, 80, 24)
+		for _, name := range []string{"move-below", "move-end", "newline"} {
+			if err := e.Execute(name); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if diff := cmp.Diff([]string{"hello world", "bye world", ""}, e.Buffer.Lines); diff != "" {
+			t.Error(diff)
+		}
+		if err := e.Execute("no-such-command"); err == nil {
+			t.Error("want error for unknown command")
+		}
+	})
+
+	t.Run("default bindings", func(t *testing.T) {
+		e := editor.New()
+		for key, name := range e.Keymap.Bindings {
+			if _, ok := e.Commands[name]; !ok {
+				t.Errorf("%s is bound to unknown command %s", key, name)
+			}
+		}
+	})
+
+	t.Run("RegisterCommand()", func(t *testing.T) {
+		e := editor.New()
+		ran := false
+		e.RegisterCommand(&editor.Command{
+			Name: "test-command",
+			Run: func(*editor.Editor) {
+				ran = true
+			},
+		})
+		if err := e.Execute("test-command"); err != nil {
+			t.Fatal(err)
+		}
+		if !ran {
+			t.Error("command did not run")
+		}
+	})
+}
@@ -0,0 +1,133 @@
+package editor
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Config is a configuration in an INI-like format:
+//
+//	# comment
+//	key = value
+//	[section]
+//	key = value
+//
+// Keys before the first section belong to the section named "".
+type Config struct {
+	Sections []*ConfigSection
+}
+
+type ConfigSection struct {
+	Name   string
+	Keys   []string
+	Values map[string]string
+}
+
+func ParseConfig(r io.Reader) (*Config, error) {
+	c := &Config{}
+	sec := c.section("")
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		switch {
+		case line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";"):
+		case strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]"):
+			sec = c.section(strings.TrimSpace(line[1 : len(line)-1]))
+		case strings.Contains(line, "="):
+			i := strings.Index(line, "=")
+			sec.Set(strings.TrimSpace(line[:i]), strings.TrimSpace(line[i+1:]))
+		default:
+			return nil, fmt.Errorf("line %d: invalid config entry: %s", n, line)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// LoadConfigFile reads the config at path. A missing file is an empty config.
+func LoadConfigFile(path string) (*Config, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &Config{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	c, err := ParseConfig(f)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return c, nil
+}
+
+// Get returns the value of key in the section named name.
+func (c *Config) Get(name, key string) (string, bool) {
+	for i := len(c.Sections) - 1; i >= 0; i-- {
+		if s := c.Sections[i]; s.Name == name {
+			if v, ok := s.Values[key]; ok {
+				return v, true
+			}
+		}
+	}
+	return "", false
+}
+
+// GetDefault returns the value of key in the section named name, or def if it is not set.
+func (c *Config) GetDefault(name, key, def string) string {
+	if v, ok := c.Get(name, key); ok {
+		return v
+	}
+	return def
+}
+
+// GetBool returns the value of key in the section named name as a boolean, or def if it is not set.
+func (c *Config) GetBool(name, key string, def bool) (bool, error) {
+	v, ok := c.Get(name, key)
+	if !ok {
+		return def, nil
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		return def, fmt.Errorf("invalid value of %s in [%s]: %s", key, name, v)
+	}
+	return b, nil
+}
+
+func (c *Config) section(name string) *ConfigSection {
+	s := &ConfigSection{
+		Name:   name,
+		Values: map[string]string{},
+	}
+	c.Sections = append(c.Sections, s)
+	return s
+}
+
+func (s *ConfigSection) Set(key, value string) {
+	if _, ok := s.Values[key]; !ok {
+		s.Keys = append(s.Keys, key)
+	}
+	s.Values[key] = value
+}
+
+// LoadConfig reads the config file in the configuration directory.
+func (e *Editor) LoadConfig() error {
+	dir := ConfigDir()
+	if dir == "" {
+		return nil
+	}
+	c, err := LoadConfigFile(filepath.Join(dir, "config"))
+	if err != nil {
+		return err
+	}
+	e.Config = c
+	return nil
+}
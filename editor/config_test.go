@@ -0,0 +1,54 @@
+package editor_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/mizuochikeita/re/editor"
+)
+
+func TestConfig(t *testing.T) {
+	t.Run("ParseConfig()", func(t *testing.T) {
+		c, err := editor.ParseConfig(strings.NewReader(`# comment
+root = true
+
+[statusline]
+left = mode path
+; another comment
+right=position
+
+[statusline]
+right = percent
+`))
+		if err != nil {
+			t.Fatal(err)
+		}
+		tests := []struct {
+			section string
+			key     string
+			want    string
+			wantOk  bool
+		}{
+			{section: "", key: "root", want: "true", wantOk: true},
+			{section: "statusline", key: "left", want: "mode path", wantOk: true},
+			{section: "statusline", key: "right", want: "percent", wantOk: true},
+			{section: "statusline", key: "center", want: "", wantOk: false},
+		}
+		for _, tt := range tests {
+			got, ok := c.Get(tt.section, tt.key)
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("%s.%s: %s", tt.section, tt.key, diff)
+			}
+			if diff := cmp.Diff(tt.wantOk, ok); diff != "" {
+				t.Errorf("%s.%s: %s", tt.section, tt.key, diff)
+			}
+		}
+	})
+
+	t.Run("ParseConfig() with invalid entry", func(t *testing.T) {
+		if _, err := editor.ParseConfig(strings.NewReader("[a]\nfoo\n")); err == nil {
+			t.Error("want error")
+		}
+	})
+}
@@ -1,14 +1,19 @@
 package editor
 
 import (
-	"bufio"
 	"context"
 	"errors"
 	"fmt"
 	"io"
 	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
 	"syscall"
+	"time"
+	"unicode/utf8"
 
 	"github.com/pkg/term/termios"
 	"golang.org/x/sys/unix"
@@ -16,23 +21,93 @@ import (
 
 type Editor struct {
 	OriginalTermios unix.Termios
-	Cx              int
-	Cy              int
-	Cols            int
-	Rows            int
-	Buffer          []string
-	Vscroll         int
-	Screen          *Screen
+	// In and Out are the terminal the editor reads keys from and draws to.
+	In          *os.File
+	Out         *os.File
+	Cols        int
+	Rows        int
+	Buffers     []*Buffer
+	Layout      *Layout
+	Window      *Window
+	Buffer      *Buffer
+	Screen      *Screen
+	Minibuffer  *Minibuffer
+	Histories   map[string]*History
+	Commands    map[string]*Command
+	Keymap      *Keymap
+	Vi          *Vi
+	Config      *Config
+	Message     *Message
+	Jumps       JumpList
+	Registers   map[rune]Register
+	Output      *Buffer // written to stdout on exit, not saved on quit
+	wakeup      chan struct{}
+	pendingKeys []string
+	quit        bool
+	// typed is whether the key being handled inserted itself, which is undone together with
+	// the runes typed before it.
+	typed bool
+	// input is held while keys are read from In, and by programs using the terminal.
+	input sync.Mutex
 }
 
 func New() *Editor {
-	return &Editor{
-		Screen: &Screen{},
+	e := &Editor{
+		In:        os.Stdin,
+		Out:       os.Stdout,
+		Histories: map[string]*History{},
+		Registers: map[rune]Register{},
+		Commands:  map[string]*Command{},
+		Keymap:    NewKeymap(),
+		Config:    &Config{},
+		wakeup:    make(chan struct{}, 1),
 	}
+	for _, c := range defaultCommands {
+		e.RegisterCommand(c)
+	}
+	for seq, name := range defaultBindings {
+		e.Keymap.Bind(seq, name)
+	}
+	return e
+}
+
+// LoadKeymap applies the user key bindings in the configuration directory.
+func (e *Editor) LoadKeymap() error {
+	dir := ConfigDir()
+	if dir == "" {
+		return nil
+	}
+	return e.Keymap.LoadFile(filepath.Join(dir, "keymap"))
+}
+
+// OpenTerminal uses /dev/tty for the input or the output of the editor when stdin or stdout
+// is not a terminal, so that the editor works in a pipeline.
+func (e *Editor) OpenTerminal() error {
+	e.In, e.Out = os.Stdin, os.Stdout
+	if IsTerminal(os.Stdin) && IsTerminal(os.Stdout) {
+		return nil
+	}
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	if !IsTerminal(os.Stdin) {
+		e.In = tty
+	}
+	if !IsTerminal(os.Stdout) {
+		e.Out = tty
+	}
+	return nil
+}
+
+// IsTerminal reports whether f is a terminal.
+func IsTerminal(f *os.File) bool {
+	var t unix.Termios
+	return termios.Tcgetattr(f.Fd(), &t) == nil
 }
 
 func (e *Editor) SetRawMode() error {
-	if err := termios.Tcgetattr(0, &e.OriginalTermios); err != nil {
+	if err := termios.Tcgetattr(e.In.Fd(), &e.OriginalTermios); err != nil {
 		return err
 	}
 	t := e.OriginalTermios
@@ -42,28 +117,91 @@ func (e *Editor) SetRawMode() error {
 	t.Lflag &^= syscall.ECHO | syscall.ICANON | syscall.IEXTEN | syscall.ISIG
 	t.Cc[unix.VMIN] = 0
 	t.Cc[unix.VTIME] = 1
-	termios.Tcsetattr(0, unix.TCIFLUSH, &t)
+	termios.Tcsetattr(e.In.Fd(), unix.TCIFLUSH, &t)
 	return nil
 }
 
 func (e *Editor) ResetRawMode() {
-	termios.Tcsetattr(0, unix.TCIFLUSH, &e.OriginalTermios)
+	termios.Tcsetattr(e.In.Fd(), unix.TCIFLUSH, &e.OriginalTermios)
+}
+
+// EnterAlternateScreen draws on the alternate screen of the terminal, keeping the screen of the
+// shell to be shown again by LeaveAlternateScreen.
+func (e *Editor) EnterAlternateScreen() {
+	fmt.Fprint(e.Out, "\x1b[?1049h")
+}
+
+func (e *Editor) LeaveAlternateScreen() {
+	fmt.Fprint(e.Out, "\x1b[?1049l")
+}
+
+// Suspend stops the editor as a job of the shell, giving the terminal back as it was until the
+// job is continued. The screen is drawn again on the next refresh, in the size of the terminal
+// at that time.
+func (e *Editor) Suspend() {
+	if !jobControl() {
+		e.Error(errors.New("cannot suspend without job control"))
+		return
+	}
+	cont := make(chan os.Signal, 1)
+	signal.Notify(cont, syscall.SIGCONT)
+	defer signal.Stop(cont)
+	restore := e.releaseTerminal()
+	defer restore()
+	// Only the editor stops, and not the other commands of a pipeline or a script.
+	if err := syscall.Kill(os.Getpid(), syscall.SIGTSTP); err != nil {
+		e.Error(err)
+		return
+	}
+	// The process may stop after Kill returns, and SIGCONT comes when it is continued.
+	<-cont
+}
+
+// jobControl reports whether a shell can continue the editor once it is stopped. A session
+// leader has no shell to continue it, and SIGTSTP is ignored in the commands of a shell without
+// job control, such as those in a command substitution. Go does not tell the latter, so it is
+// read from /proc where there is one.
+func jobControl() bool {
+	if sid, err := unix.Getsid(0); err == nil && sid == os.Getpid() {
+		return false
+	}
+	status, err := os.ReadFile("/proc/self/status")
+	if err != nil {
+		return true
+	}
+	for _, l := range strings.Split(string(status), "\n") {
+		if s := strings.TrimPrefix(l, "SigIgn:"); s != l {
+			ignored, err := strconv.ParseUint(strings.TrimSpace(s), 16, 64)
+			return err != nil || ignored&(1<<(syscall.SIGTSTP-1)) == 0
+		}
+	}
+	return true
 }
 
 func (e *Editor) ClearScreen() {
 	e.HideCursor()
 	defer e.ShowCursor()
-	fmt.Print("\x1b[2J")
+	fmt.Fprint(e.Out, "\x1b[2J")
 }
 
 func (e *Editor) RefreshCursor() {
+	if e.Minibuffer != nil {
+		fmt.Fprintf(e.Out, "\x1b[%d;%dH", e.Rows+1, e.Minibuffer.CursorX()+1)
+		return
+	}
 	x, y := e.Screen.CursorPosition()
-	fmt.Printf("\x1b[%d;%dH", y+2, x+1) // for status bar
+	fmt.Fprintf(e.Out, "\x1b[%d;%dH", e.Window.Y+y+2, e.Window.X+x+1) // for status line
 }
 
 func (e *Editor) MoveCursorRelative(x, y int) {
+	v, h := e.Screen.Vscroll, e.Screen.Hscroll
 	e.Screen.MoveCursorHorizontally(x)
 	e.Screen.MoveCursorVertically(y)
+	e.Screen.ScrollToCursor()
+	if e.Screen.Vscroll != v || e.Screen.Hscroll != h {
+		e.RefreshScreen()
+		return
+	}
 	e.RefreshCursor()
 }
 
@@ -72,116 +210,706 @@ func (e *Editor) MoveCursorAbsolute(x, y int) {
 	e.RefreshCursor()
 }
 
-func (e *Editor) DrawStatusBar() {
-	left := "re/main.go"
-	right := "Saved"
-	padding := e.Cols - len(left) - len(right)
-	fmt.Print("\x1b[2K")
-	fmt.Print("\x1b[37;40m") // white on black
-	fmt.Print(left)
-	fmt.Print(strings.Repeat(" ", padding))
-	fmt.Print(right)
-	fmt.Print("\x1b[0m") // reset color
-	fmt.Print("\r\n")
+func (e *Editor) DrawStatusBar(w *Window) {
+	if w == e.Window {
+		fmt.Fprint(e.Out, "\x1b[37;40m") // white on black
+	} else {
+		fmt.Fprint(e.Out, "\x1b[90;40m") // gray on black
+	}
+	fmt.Fprint(e.Out, e.StatusLine(w, w.Width))
+	fmt.Fprint(e.Out, "\x1b[0m") // reset color
 }
 
 func (e *Editor) RefreshScreen() error {
 	e.HideCursor()
 	defer e.ShowCursor()
-	fmt.Print("\x1b[1;1H")
 	if err := e.UpdateWindowSize(); err != nil {
 		return err
 	}
-	e.DrawStatusBar()
-	rows := e.Screen.View()
-	for i := 0; i < e.Rows; i++ {
-		fmt.Print("\x1b[2K")
+	for _, w := range e.Layout.Windows() {
+		e.DrawWindow(w)
+	}
+	e.DrawMinibuffer()
+	e.RefreshCursor()
+	return nil
+}
+
+func (e *Editor) DrawWindow(w *Window) {
+	fmt.Fprintf(e.Out, "\x1b[%d;%dH", w.Y+1, w.X+1)
+	e.DrawStatusBar(w)
+	rows := w.Screen.View()
+	for i := 0; i < w.Screen.Height; i++ {
+		if w.X > 0 {
+			fmt.Fprintf(e.Out, "\x1b[%d;%dH|", w.Y+i+2, w.X) // separator
+		} else {
+			fmt.Fprintf(e.Out, "\x1b[%d;%dH", w.Y+i+2, w.X+1)
+		}
+		text, width := "~", 1
 		if i < len(rows) {
-			fmt.Print(rows[i].Body)
+			text, width = e.rowText(w, w.Screen.Vscroll+i)
+		}
+		fmt.Fprint(e.Out, text)
+		if padding := w.Width - width; padding > 0 {
+			fmt.Fprint(e.Out, padText(w, i < len(rows), width, padding))
+		}
+	}
+}
+
+// padText returns the spaces filling a row of w after its text of the given width, with the
+// ruler of the buffer if the row has text and does not reach it.
+func padText(w *Window, text bool, width, padding int) string {
+	ruler := w.Screen.Gutter + w.Buffer.Ruler - w.Screen.Hscroll
+	if !text || w.Buffer.Ruler <= 0 || w.Buffer.HexMode || ruler < width || ruler >= w.Width {
+		return strings.Repeat(" ", padding)
+	}
+	return strings.Repeat(" ", ruler-width) + "\x1b[2m|\x1b[0m" + strings.Repeat(" ", w.Width-ruler-1) // faint
+}
+
+// rowText returns the text to draw for the row r of w after its gutter, and its width. Without
+// wrapping, it is the part of the row in view, with "<" and ">" in the first and the last columns
+// if there is more.
+func (e *Editor) rowText(w *Window, r int) (string, int) {
+	s := w.Screen
+	row := s.Rows[r]
+	if s.HexBytes > 0 {
+		// The columns of a row of the hex layout are its bytes, not the runes of its text.
+		return row.Body, StringWidth(row.Body)
+	}
+	first, end, left, right := s.RowSpan(r)
+	var sb strings.Builder
+	sb.WriteString(gutterText(w, r))
+	x := s.Hscroll
+	if left {
+		sb.WriteString("<")
+		x++
+	}
+	if row.Prefix != "" {
+		sb.WriteString("\x1b[2m" + row.Prefix + "\x1b[0m") // faint
+		x += StringWidth(row.Prefix)
+	}
+	if first < row.Len {
+		// A wide character cut by the left edge is not shown.
+		sb.WriteString(strings.Repeat(" ", row.ScreenXs[first]-x))
+		x = row.ScreenXs[first]
+	}
+	sb.WriteString(e.highlightSelection(w, r, first, end))
+	for k := first; k < end; k++ {
+		x += s.RuneWidth(r, k)
+	}
+	if right {
+		sb.WriteString(strings.Repeat(" ", s.Hscroll+s.Width-1-x))
+		sb.WriteString(">")
+		x = s.Hscroll + s.Width
+	}
+	return sb.String(), s.Gutter + x - s.Hscroll
+}
+
+// highlightSelection shows the part of the row r selected in the vi visual mode in reverse video.
+// It returns the text to draw for the runes of the row from first up to end, by displayText.
+func (e *Editor) highlightSelection(w *Window, r, first, end int) string {
+	if w != e.Window || e.Vi == nil || r >= len(w.Screen.Rows) {
+		return displayText(w, r, first, end)
+	}
+	line, col, endLine, endCol, ok := e.Vi.Selection(e)
+	if !ok {
+		return displayText(w, r, first, end)
+	}
+	rl, rc := w.Screen.RowPosition(r)
+	if rl < line || rl > endLine {
+		return displayText(w, r, first, end)
+	}
+	from, to := first, end
+	if rl == line {
+		from = col - rc
+	}
+	if rl == endLine {
+		to = endCol - rc
+	}
+	if from < first {
+		from = first
+	}
+	if to > end {
+		to = end
+	}
+	if from >= to {
+		return displayText(w, r, first, end)
+	}
+	return displayText(w, r, first, from) + "\x1b[7m" + displayText(w, r, from, to) + "\x1b[0m" + displayText(w, r, to, end)
+}
+
+// displayText returns the text to draw for the runes of the row r of w from first up to end, with
+// tabs as spaces up to the next tab stop and escape runes as \xNN in a different color.
+func displayText(w *Window, r, first, end int) string {
+	body := w.Screen.Rows[r].Body
+	body = body[runeIndex(body, first):runeIndex(body, end)]
+	if strings.IndexFunc(body, func(r rune) bool {
+		_, ok := EscapedByte(r)
+		return ok || r == '\t'
+	}) < 0 {
+		return body
+	}
+	var sb strings.Builder
+	k := first
+	for _, c := range body {
+		if c == '\t' {
+			sb.WriteString(strings.Repeat(" ", w.Screen.RuneWidth(r, k)))
+		} else if b, ok := EscapedByte(c); ok {
+			fmt.Fprintf(&sb, "\x1b[35m\\x%02x\x1b[39m", b)
 		} else {
-			fmt.Print("~")
+			sb.WriteRune(c)
 		}
-		if i < e.Rows-1 {
-			fmt.Print("\r\n")
+		k++
+	}
+	return sb.String()
+}
+
+func (e *Editor) DrawMinibuffer() {
+	fmt.Fprintf(e.Out, "\x1b[%d;1H", e.Rows+1)
+	fmt.Fprint(e.Out, "\x1b[2K")
+	switch {
+	case e.Minibuffer != nil:
+		fmt.Fprint(e.Out, TruncateWidth(e.Minibuffer.String(), e.Cols))
+	case e.Message != nil:
+		fmt.Fprint(e.Out, e.Message.Color())
+		fmt.Fprint(e.Out, TruncateWidth(e.Message.Text, e.Cols))
+		fmt.Fprint(e.Out, "\x1b[0m") // reset color
+	}
+}
+
+// OpenFile switches to the buffer visiting path, loading it into a new buffer if needed.
+// A path which does not exist yet opens an empty buffer, and the file is created when it is saved.
+func (e *Editor) OpenFile(path string) error {
+	path = filepath.Clean(path)
+	for _, b := range e.Buffers {
+		if b.Path == path {
+			e.SwitchBuffer(b)
+			return nil
 		}
 	}
-	e.RefreshCursor()
+	b := NewBuffer(path)
+	large, err := e.isLargeFile(path)
+	if err != nil {
+		return err
+	}
+	if large {
+		if err := b.LoadLarge(path, e.wake); err != nil {
+			return err
+		}
+		e.AddBuffer(b)
+		e.Infof("Opened %s read-only as a large file", path)
+		e.Error(e.applyEditorConfig(b))
+		return e.restoreMarks(b)
+	}
+	err = b.Load(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		b.Path = path
+		b.Name = filepath.Base(path)
+		e.AddBuffer(b)
+		e.Infof("(New file) %s", path)
+		e.Error(e.applyEditorConfig(b))
+	case err != nil:
+		return err
+	default:
+		e.AddBuffer(b)
+		e.Error(e.applyEditorConfig(b))
+		return e.restoreMarks(b)
+	}
 	return nil
 }
 
-func (e *Editor) OpenFile(path string) error {
-	f, err := os.Open(path)
+// isLargeFile reports whether the file at path is larger than the large_file_size in the [file] section of the config.
+func (e *Editor) isLargeFile(path string) (bool, error) {
+	fi, err := os.Stat(path)
+	if err != nil || !fi.Mode().IsRegular() {
+		return false, nil
+	}
+	size := int64(defaultLargeFileSize)
+	if v, ok := e.Config.Get("file", "large_file_size"); ok {
+		if size, err = ParseSize(v); err != nil {
+			return false, err
+		}
+	}
+	return fi.Size() >= size, nil
+}
+
+// OpenReader loads the contents of r into a new buffer named name. The buffer has no file.
+func (e *Editor) OpenReader(name string, r io.Reader) error {
+	b := NewBuffer(name)
+	if err := b.Read(r); err != nil {
+		return err
+	}
+	e.AddBuffer(b)
+	return nil
+}
+
+// OpenFileArg opens the file of a command line argument and moves the cursor to its position,
+// loading the region around it in a large file. The path "-" reads stdin.
+func (e *Editor) OpenFileArg(a FileArg) error {
+	var err error
+	if a.Path == "-" {
+		err = e.OpenReader("*stdin*", os.Stdin)
+	} else {
+		err = e.OpenFile(a.Path)
+	}
 	if err != nil {
 		return err
 	}
-	r := bufio.NewReader(f)
-	var buf []string
-	var line []byte
-outer:
-	for {
-		line = line[:0]
-		for {
-			bs, isPrefix, err := r.ReadLine()
-			if errors.Is(err, io.EOF) {
-				break outer
-			}
-			if err != nil {
-				panic(err)
-			}
-			line = append(line, bs...)
-			if !isPrefix {
-				break
-			}
+	switch {
+	case a.Line < 0:
+		e.Screen.SetPosition(len(e.Buffer.Lines)-1, 0)
+	case a.Line > 0 && !e.Buffer.HexMode:
+		col := a.Col - 1
+		if col < 0 {
+			col = 0
+		}
+		return e.gotoLine(a.Line-1, col)
+	}
+	return nil
+}
+
+func (e *Editor) AddBuffer(b *Buffer) {
+	wrap, err := e.wrapOptions()
+	e.Error(err)
+	b.Wrap = wrap
+	n, err := ParseLineNumbers(e.Config.GetDefault("gutter", "line_numbers", "off"))
+	e.Error(err)
+	b.LineNumbers = n
+	indent, err := e.indentOptions(b)
+	e.Error(err)
+	b.Indent = indent
+	b.Name = e.uniqueName(b, b.Name)
+	e.Buffers = append(e.Buffers, b)
+	if e.Window == nil {
+		w := NewWindow(b)
+		e.Layout = NewLayout(w)
+		e.SelectWindow(w)
+	}
+	e.SwitchBuffer(b)
+}
+
+// SwitchBuffer makes b current. The buffer list is kept in least recently used order.
+func (e *Editor) SwitchBuffer(b *Buffer) {
+	for i, bb := range e.Buffers {
+		if bb == b {
+			e.Buffers = append(append(e.Buffers[:i:i], e.Buffers[i+1:]...), b)
+			break
+		}
+	}
+	e.Window.SetBuffer(b)
+	e.SelectWindow(e.Window)
+}
+
+// CloseBuffer removes b from the buffer list, leaving an empty buffer if it was the last one.
+func (e *Editor) CloseBuffer(b *Buffer) {
+	if b.Large != nil {
+		b.Large.Close()
+	}
+	e.Jumps.Remove(b)
+	for i, bb := range e.Buffers {
+		if bb == b {
+			e.Buffers = append(e.Buffers[:i], e.Buffers[i+1:]...)
+			break
+		}
+	}
+	if len(e.Buffers) == 0 {
+		e.Buffers = append(e.Buffers, NewBuffer("*scratch*"))
+	}
+	next := e.Buffers[len(e.Buffers)-1]
+	for _, w := range e.Layout.Windows() {
+		if w.Buffer == b {
+			w.SetBuffer(next)
+		}
+	}
+	e.SelectWindow(e.Window)
+}
+
+// SelectWindow makes w the current window.
+func (e *Editor) SelectWindow(w *Window) {
+	e.Window = w
+	e.Buffer = w.Buffer
+	e.Screen = w.Screen
+}
+
+// SplitWindow divides the current window, showing the same buffer at the same position in both.
+func (e *Editor) SplitWindow(vertical bool) {
+	w := NewWindow(e.Buffer)
+	w.Screen.Vscroll = e.Screen.Vscroll
+	w.Screen.SetPosition(e.Screen.Position())
+	e.Layout.Split(e.Window, w, vertical)
+	e.UpdateWindowSize()
+}
+
+// CloseWindow deletes the current window unless it is the only one.
+func (e *Editor) CloseWindow() {
+	ws := e.Layout.Windows()
+	if !e.Layout.Remove(e.Window) {
+		return
+	}
+	for i, w := range ws {
+		if w == e.Window {
+			e.SelectWindow(ws[(i+len(ws)-1)%len(ws)])
+			break
 		}
-		buf = append(buf, string(line))
 	}
-	e.Buffer = buf
 	e.UpdateWindowSize()
-	e.Screen.Update(buf)
+}
+
+// OtherWindow selects the next window on the screen.
+func (e *Editor) OtherWindow() {
+	ws := e.Layout.Windows()
+	for i, w := range ws {
+		if w == e.Window {
+			e.SelectWindow(ws[(i+1)%len(ws)])
+			return
+		}
+	}
+}
+
+// InsertText inserts s at the cursor and moves the cursor after it.
+func (e *Editor) InsertText(s string) {
+	if e.Buffer.ReadOnly {
+		e.Error(ErrReadOnly)
+		return
+	}
+	if e.Buffer.HexMode {
+		e.Error(e.InsertHexDigits(s))
+		return
+	}
+	line, col := e.Screen.Position()
+	c := e.Buffer.Insert(line, col, s)
+	e.BufferChanged(e.Buffer, c)
+	e.Screen.SetPosition(c.NewEndLine, c.NewEndCol)
+}
+
+// DeleteBackward deletes the rune before the cursor, joining lines at the beginning of a line.
+func (e *Editor) DeleteBackward() {
+	if e.Buffer.ReadOnly {
+		e.Error(ErrReadOnly)
+		return
+	}
+	if e.Buffer.HexMode {
+		e.deleteByteBackward()
+		return
+	}
+	if e.deleteIndentBackward() {
+		return
+	}
+	line, col := e.Screen.Position()
+	var c Change
+	switch {
+	case col > 0:
+		_, c = e.Buffer.Delete(line, col-1, line, col)
+	case line > 0:
+		_, c = e.Buffer.Delete(line-1, e.Buffer.LineLen(line-1), line, 0)
+	default:
+		return
+	}
+	e.BufferChanged(e.Buffer, c)
+}
+
+// DeleteRange deletes the text from (line, col) up to (endLine, endCol) and returns it.
+func (e *Editor) DeleteRange(line, col, endLine, endCol int) string {
+	if e.Buffer.ReadOnly {
+		e.Error(ErrReadOnly)
+		return ""
+	}
+	text, c := e.Buffer.Delete(line, col, endLine, endCol)
+	e.BufferChanged(e.Buffer, c)
+	return text
+}
+
+// SetLineEnding converts the line endings of the current buffer to "LF" or "CRLF".
+// It also removes the CRs left at the end of the lines of a file with mixed line endings.
+func (e *Editor) SetLineEnding(eol string) {
+	b := e.Buffer
+	if b.ReadOnly {
+		e.Error(ErrReadOnly)
+		return
+	}
+	if b.HexMode {
+		e.Error(errNotText)
+		return
+	}
+	for i, l := range b.Lines {
+		if strings.HasSuffix(l, "\r") {
+			n := b.LineLen(i)
+			e.DeleteRange(i, n-1, i, n)
+		}
+	}
+	if b.LineEnding != eol {
+		b.LineEnding = eol
+		b.modified()
+	}
+	e.Infof("Line endings: %s", eol)
+}
+
+// SetWrap sets whether the lines of the current buffer are wrapped at the width of the windows.
+func (e *Editor) SetWrap(wrap bool) {
+	e.Buffer.NoWrap = !wrap
+	e.BufferReloaded(e.Buffer)
+	if wrap {
+		e.Infof("Wrap: on")
+	} else {
+		e.Infof("Wrap: off")
+	}
+}
+
+// wrapOptions returns the options of wrapping set in the [wrap] section of the config.
+func (e *Editor) wrapOptions() (WrapOptions, error) {
+	word, err := e.Config.GetBool("wrap", "word", false)
+	if err != nil {
+		return WrapOptions{}, err
+	}
+	indent, err := e.Config.GetBool("wrap", "indent", false)
+	if err != nil {
+		return WrapOptions{}, err
+	}
+	return WrapOptions{
+		Word:   word,
+		Indent: indent,
+		Marker: e.Config.GetDefault("wrap", "marker", ""),
+	}, nil
+}
+
+// SetWordWrap sets whether the lines of the current buffer are wrapped at word boundaries.
+func (e *Editor) SetWordWrap(word bool) {
+	e.Buffer.Wrap.Word = word
+	e.BufferReloaded(e.Buffer)
+	if word {
+		e.Infof("Word wrap: on")
+	} else {
+		e.Infof("Word wrap: off")
+	}
+}
+
+// SetEncoding converts the current buffer to the encoding enc, which it is saved in.
+func (e *Editor) SetEncoding(enc string) error {
+	b := e.Buffer
+	if b.ReadOnly {
+		return ErrReadOnly
+	}
+	if b.HexMode {
+		return errNotText
+	}
+	if _, err := Encode(enc, strings.Join(b.Lines, "\n")); err != nil {
+		return err
+	}
+	if b.Encoding != enc {
+		b.Encoding = enc
+		// UTF-16 files are only recognized by their byte order mark.
+		b.BOM = strings.HasPrefix(enc, "UTF-16")
+		b.modified()
+	}
+	e.Infof("Encoding: %s", enc)
 	return nil
 }
 
+// ReopenWithEncoding reads the file of the current buffer again in the encoding enc.
+func (e *Editor) ReopenWithEncoding(enc string) error {
+	b := e.Buffer
+	if b.Path == "" {
+		return errors.New("buffer has no file")
+	}
+	if b.Dirty {
+		return errors.New("buffer is modified")
+	}
+	if b.Large != nil || b.HexMode {
+		return errNotText
+	}
+	data, err := os.ReadFile(b.Path)
+	if err != nil {
+		return err
+	}
+	if err := b.Decode(data, enc); err != nil {
+		return err
+	}
+	e.BufferReloaded(b)
+	return nil
+}
+
+// BufferChanged updates the windows showing b after the change c.
+func (e *Editor) BufferChanged(b *Buffer, c Change) {
+	e.Jumps.Adjust(b, c)
+	adjustMarks(b, c)
+	for _, w := range e.Layout.Windows() {
+		if w.Buffer == b {
+			w.Refresh(c)
+		}
+	}
+}
+
+// BufferReloaded updates the windows showing b after its whole text is replaced.
+func (e *Editor) BufferReloaded(b *Buffer) {
+	for _, w := range e.Layout.Windows() {
+		if w.Buffer == b {
+			w.Reload()
+		}
+	}
+}
+
+// FollowRegion loads another region of a large file when the cursor comes close to an end of the
+// region loaded, keeping the cursor on the same text. Other windows showing the buffer keep their
+// positions in the file as far as they are in the new region.
+func (e *Editor) FollowRegion() {
+	b := e.Buffer
+	if b.Large == nil {
+		return
+	}
+	margin := 4 * e.Screen.Height
+	if margin < 64 {
+		margin = 64
+	}
+	if !b.RegionEdge(e.filePosition(e.Window), margin) {
+		return
+	}
+	e.Error(e.loadRegion(e.filePosition(e.Window)))
+}
+
+// loadRegion loads the region of the large file of the current buffer around pos, a line or a
+// byte offset in the hex mode. The windows showing the buffer keep their positions in the file
+// as far as they are in the new region.
+func (e *Editor) loadRegion(pos int64) error {
+	b := e.Buffer
+	type view struct {
+		pos     int64
+		col     int
+		cursorY int
+	}
+	views := map[*Window]view{}
+	for _, w := range e.Layout.Windows() {
+		if w.Buffer == b {
+			_, col := w.Screen.Position()
+			views[w] = view{e.filePosition(w), col, w.Screen.Cy - w.Screen.Vscroll}
+		}
+	}
+	if err := b.LoadRegion(pos); err != nil {
+		return err
+	}
+	for w, v := range views {
+		s := w.Screen
+		w.update()
+		if b.HexMode {
+			rel := v.pos - b.Offset
+			s.SetPosition(int(rel)/s.HexBytes, int(rel)%s.HexBytes)
+		} else {
+			s.SetPosition(int(v.pos)-b.FirstLine, v.col)
+		}
+		s.Vscroll = s.Cy - v.cursorY
+		if s.Vscroll < 0 {
+			s.Vscroll = 0
+		}
+	}
+	return nil
+}
+
+// filePosition returns the line of the cursor of w in the file, or its byte offset in the hex mode.
+func (e *Editor) filePosition(w *Window) int64 {
+	line, col := w.Screen.Position()
+	if w.Buffer.HexMode {
+		return w.Buffer.Offset + int64(line*w.Screen.HexBytes+col)
+	}
+	return int64(w.Buffer.FirstLine + line)
+}
+
+// uniqueName returns name for the buffer b, or name with a number such as "main.go<2>" if
+// another buffer has it.
+func (e *Editor) uniqueName(b *Buffer, name string) string {
+	taken := func(n string) bool {
+		for _, bb := range e.Buffers {
+			if bb != b && bb.Name == n {
+				return true
+			}
+		}
+		return false
+	}
+	unique := name
+	for i := 2; taken(unique); i++ {
+		unique = fmt.Sprintf("%s<%d>", name, i)
+	}
+	return unique
+}
+
+// setPath makes path the file of b, and names b after it.
+func (e *Editor) setPath(b *Buffer, path string) {
+	b.Path = path
+	b.Name = e.uniqueName(b, filepath.Base(path))
+}
+
+// FindBuffer returns the buffer named name, or nil.
+func (e *Editor) FindBuffer(name string) *Buffer {
+	for _, b := range e.Buffers {
+		if b.Name == name {
+			return b
+		}
+	}
+	return nil
+}
+
+// BufferNames returns the buffer names, the most recently used first, except the current buffer.
+func (e *Editor) BufferNames() []string {
+	var names []string
+	for i := len(e.Buffers) - 1; i >= 0; i-- {
+		if e.Buffers[i] != e.Buffer {
+			names = append(names, e.Buffers[i].Name)
+		}
+	}
+	return names
+}
+
+// HandleKey runs the command for k and updates the screen. Errors of commands are shown as messages.
 func (e *Editor) HandleKey(k Key, cancel func()) error {
+	e.Message = nil
+	e.typed = false
 	switch {
-	case k.IsControl():
-		switch k.Value {
-		case ToControl('P'):
-			e.MoveAbove()
-		case ToControl('N'):
-			e.MoveBelow()
-		case ToControl('F'):
-			e.MoveRight()
-		case ToControl('B'):
-			e.MoveLeft()
-		case ToControl('A'):
-			e.MoveBeginning()
-		case ToControl('E'):
-			e.MoveEnd()
-		case ToControl('U'):
-			e.MoveCursorRelative(0, -e.Rows/2)
-		case ToControl('D'):
-			e.MoveCursorRelative(0, e.Rows/2)
-		case ToControl('Q'):
-			e.ClearScreen()
-			cancel()
-		}
-	case k.IsEscaped():
-		switch k.EscapedSequence[0] {
-		case 'A':
-			e.MoveAbove()
-		case 'B':
-			e.MoveBelow()
-		case 'C':
-			e.MoveRight()
-		case 'D':
-			e.MoveLeft()
+	case e.Minibuffer != nil:
+		e.Minibuffer.HandleKey(k)
+	case e.Vi != nil && !e.Buffer.HexMode:
+		e.Vi.HandleKey(e, k)
+	default:
+		e.Error(e.ProcessKey(k))
+	}
+	// The edits of a key are undone together, and so are the runes typed one after another
+	// and the text typed in the insert mode of vi.
+	if !e.typed && !e.viInserting() {
+		e.endUndoGroup()
+	}
+	if e.quit {
+		e.ClearScreen()
+		cancel()
+		return nil
+	}
+	e.FollowRegion()
+	return e.RefreshScreen()
+}
+
+// ProcessKey runs the command bound to the key sequence ending with k, or inserts k if it is not bound.
+func (e *Editor) ProcessKey(k Key) error {
+	keys := append(e.pendingKeys, k.String())
+	name, prefix := e.Keymap.Lookup(strings.Join(keys, " "))
+	switch {
+	case prefix:
+		e.pendingKeys = keys
+	case name != "":
+		e.pendingKeys = nil
+		if !e.viInserting() {
+			e.endUndoGroup() // after runes typed
 		}
+		return e.Execute(name)
+	case len(e.pendingKeys) > 0 || k.IsControl() || k.IsEscaped() || k.Meta:
+		e.pendingKeys = nil
 	default:
-		fmt.Printf("%d (%c) ", k.Value, k.Value)
+		e.TypeRune(k.Value)
+		e.typed = true
 	}
 	return nil
 }
 
+// viInserting reports whether keys are handled in the insert mode of vi.
+func (e *Editor) viInserting() bool {
+	return e.Vi != nil && e.Vi.Mode == ViInsert
+}
+
 func (e *Editor) MoveAbove() {
 	e.MoveCursorRelative(0, -1)
 }
@@ -199,72 +927,94 @@ func (e *Editor) MoveLeft() {
 }
 
 func (e *Editor) MoveBeginning() {
-	e.MoveCursorAbsolute(0, -1)
+	line, _ := e.Screen.Position()
+	e.Screen.SetPosition(line, 0)
 }
 
 func (e *Editor) MoveEnd() {
-	e.MoveCursorAbsolute(e.Cols-1, -1)
+	line, _ := e.Screen.Position()
+	e.Screen.SetPosition(line, e.Screen.LineEnd(line))
 }
 
 func (e *Editor) Scroll(rows int) {
-	e.Vscroll += rows
-	maxVscroll := len(e.Buffer) - e.Rows*3/4
-	if e.Vscroll > maxVscroll {
-		e.Vscroll = maxVscroll
-	}
-	minVscroll := 0
-	if e.Vscroll < minVscroll {
-		e.Vscroll = minVscroll
-	}
+	e.Screen.Scroll(rows)
 	e.RefreshScreen()
 }
 
 func (e *Editor) HideCursor() {
-	fmt.Print("\x1b[?25l")
+	fmt.Fprint(e.Out, "\x1b[?25l")
 }
 
 func (e *Editor) ShowCursor() {
-	fmt.Print("\x1b[?25h")
+	fmt.Fprint(e.Out, "\x1b[?25h")
 }
 
-func (e *Editor) ReadRune(ctx context.Context) chan rune {
+func (e *Editor) ReadRunes(ctx context.Context) chan rune {
 	c := make(chan rune)
 	go func() {
 		<-ctx.Done()
 		close(c)
 	}()
 	go func() {
-		rd := bufio.NewReader(os.Stdin)
+		// Bytes are read one at a time, for none typed ahead to be kept from the programs the
+		// terminal is released to.
+		var p [utf8.UTFMax]byte
+		n := 0
 		for {
-			r, _, err := rd.ReadRune()
+			e.input.Lock()
+			_, err := e.In.Read(p[n : n+1])
+			e.input.Unlock()
 			if err != nil {
 				if err.Error() == "EOF" {
 					continue // timeout
 				}
-				panic(err)
+				// The terminal is gone: the editor exits as on a hangup, saving the changes.
+				syscall.Kill(os.Getpid(), syscall.SIGHUP)
+				return
+			}
+			n++
+			for n > 0 && utf8.FullRune(p[:n]) {
+				r, size := utf8.DecodeRune(p[:n])
+				c <- r
+				n = copy(p[:], p[size:n])
 			}
-			c <- r
 		}
 	}()
 	return c
 }
 
+// escapeTimeout is how long ReadKey waits for the rest of an escape sequence before taking ESC as a key.
+const escapeTimeout = 50 * time.Millisecond
+
 func (e *Editor) ReadKey(ctx context.Context) chan Key {
 	ks := make(chan Key)
 	go func() {
 		defer close(ks)
-		rs := e.ReadRune(ctx)
+		rs := e.ReadRunes(ctx)
 		for r := range rs {
 			switch {
 			case r == '\x1b':
-				a := <-rs
+				var a rune
+				select {
+				case a = <-rs:
+				case <-time.After(escapeTimeout):
+					ks <- Key{
+						Value: r,
+					}
+					continue
+				}
+				if a != '[' {
+					ks <- Key{
+						Value: a,
+						Meta:  true,
+					}
+					continue
+				}
 				b := <-rs
-				if a == '[' {
-					switch b {
-					case 'A', 'B', 'C', 'D':
-						ks <- Key{
-							EscapedSequence: []rune{b},
-						}
+				switch b {
+				case 'A', 'B', 'C', 'D', 'Z':
+					ks <- Key{
+						EscapedSequence: []rune{b},
 					}
 				}
 			default:
@@ -278,14 +1028,15 @@ func (e *Editor) ReadKey(ctx context.Context) chan Key {
 }
 
 func (e *Editor) UpdateWindowSize() error {
-	w, err := unix.IoctlGetWinsize(1, unix.TIOCGWINSZ)
+	w, err := unix.IoctlGetWinsize(int(e.Out.Fd()), unix.TIOCGWINSZ)
 	if err != nil {
 		return err
 	}
 	e.Cols = int(w.Col)
-	e.Rows = int(w.Row) - 1 // for status bar
-	e.Screen.Width = int(w.Col)
-	e.Screen.Height = int(w.Row) - 1 // for status bar
+	e.Rows = int(w.Row) - 1 // for minibuffer
+	if e.Layout != nil {
+		e.Layout.Arrange(0, 0, e.Cols, e.Rows)
+	}
 	return nil
 }
 
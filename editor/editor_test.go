@@ -1,7 +1,17 @@
 package editor_test
 
 import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"syscall"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/mizuochikeita/re/editor"
@@ -17,8 +27,300 @@ func TestEditor(t *testing.T) {
 			"hello world",
 			"bye world",
 		}
-		if diff := cmp.Diff(want, e.Buffer); diff != "" {
+		if diff := cmp.Diff(want, e.Buffer.Lines); diff != "" {
 			t.Error(diff)
 		}
 	})
+
+	t.Run("OpenFile() with multiple files", func(t *testing.T) {
+		e := editor.New()
+		for _, path := range []string{"sample.txt", "key.go", "sample.txt"} {
+			if err := e.OpenFile(path); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if diff := cmp.Diff(2, len(e.Buffers)); diff != "" {
+			t.Error(diff)
+		}
+		if diff := cmp.Diff("sample.txt", e.Buffer.Name); diff != "" {
+			t.Error(diff)
+		}
+		if diff := cmp.Diff([]string{"key.go"}, e.BufferNames()); diff != "" {
+			t.Error(diff)
+		}
+	})
+
+	t.Run("OpenFile() with files of the same name", func(t *testing.T) {
+		dir := t.TempDir()
+		var paths []string
+		for _, d := range []string{"a", "b", "c"} {
+			path := filepath.Join(dir, d, "main.go")
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path, []byte("package "+d+"\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			paths = append(paths, path)
+		}
+		e := editor.New()
+		for _, path := range paths {
+			if err := e.OpenFile(path); err != nil {
+				t.Fatal(err)
+			}
+		}
+		for i, name := range []string{"main.go", "main.go<2>", "main.go<3>"} {
+			b := e.FindBuffer(name)
+			if b == nil {
+				t.Errorf("no buffer %s", name)
+				continue
+			}
+			if diff := cmp.Diff(paths[i], b.Path); diff != "" {
+				t.Errorf("%s: %s", name, diff)
+			}
+		}
+		// The name is free again when its buffer is closed.
+		e.CloseBuffer(e.FindBuffer("main.go"))
+		if err := e.OpenReader("main.go", strings.NewReader("")); err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff("main.go", e.Buffer.Name); diff != "" {
+			t.Error(diff)
+		}
+	})
+	t.Run("CloseBuffer()", func(t *testing.T) {
+		e := editor.New()
+		if err := e.OpenFile("sample.txt"); err != nil {
+			t.Fatal(err)
+		}
+		if err := e.OpenFile("key.go"); err != nil {
+			t.Fatal(err)
+		}
+		e.CloseBuffer(e.Buffer)
+		if diff := cmp.Diff("sample.txt", e.Buffer.Name); diff != "" {
+			t.Error(diff)
+		}
+		e.CloseBuffer(e.Buffer)
+		if diff := cmp.Diff("*scratch*", e.Buffer.Name); diff != "" {
+			t.Error(diff)
+		}
+		if diff := cmp.Diff(1, len(e.Buffers)); diff != "" {
+			t.Error(diff)
+		}
+	})
+	t.Run("SplitWindow()", func(t *testing.T) {
+		e := editor.New()
+		if err := e.OpenFile("sample.txt"); err != nil {
+			t.Fatal(err)
+		}
+		e.Screen.SetPosition(1, 0)
+		e.SplitWindow(false)
+		e.OtherWindow()
+		if diff := cmp.Diff(2, len(e.Layout.Windows())); diff != "" {
+			t.Fatal(diff)
+		}
+		e.Screen.SetPosition(0, 0)
+		e.InsertText("a\n")
+		if diff := cmp.Diff([]string{"a", "hello world", "bye world"}, e.Buffer.Lines); diff != "" {
+			t.Error(diff)
+		}
+		e.OtherWindow()
+		line, col := e.Screen.Position()
+		if diff := cmp.Diff([2]int{2, 0}, [2]int{line, col}); diff != "" {
+			t.Error(diff)
+		}
+		e.CloseWindow()
+		if diff := cmp.Diff(1, len(e.Layout.Windows())); diff != "" {
+			t.Error(diff)
+		}
+	})
+	t.Run("OpenFile() with a new file", func(t *testing.T) {
+		e := editor.New()
+		path := filepath.Join(t.TempDir(), "new.txt")
+		if err := e.OpenFile(path); err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff([]string{""}, e.Buffer.Lines); diff != "" {
+			t.Error(diff)
+		}
+		e.InsertText("new")
+		e.SaveBuffer()
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff("new\n", string(data)); diff != "" {
+			t.Error(diff)
+		}
+	})
+
+	t.Run("OpenFileArg()", func(t *testing.T) {
+		tests := []struct {
+			desc    string
+			arg     editor.FileArg
+			wantPos [2]int
+		}{
+			{desc: "no position", arg: editor.FileArg{Path: "sample.txt"}, wantPos: [2]int{0, 0}},
+			{desc: "line", arg: editor.FileArg{Path: "sample.txt", Line: 2}, wantPos: [2]int{1, 0}},
+			{desc: "line and column", arg: editor.FileArg{Path: "sample.txt", Line: 2, Col: 5}, wantPos: [2]int{1, 4}},
+			{desc: "last line", arg: editor.FileArg{Path: "sample.txt", Line: -1}, wantPos: [2]int{1, 0}},
+		}
+		for _, tt := range tests {
+			e := editor.New()
+			if err := e.OpenFileArg(tt.arg); err != nil {
+				t.Fatal(err)
+			}
+			line, col := e.Screen.Position()
+			if diff := cmp.Diff(tt.wantPos, [2]int{line, col}); diff != "" {
+				t.Errorf("%s: %s", tt.desc, diff)
+			}
+		}
+	})
+	t.Run("OpenReader()", func(t *testing.T) {
+		e := editor.New()
+		if err := e.OpenReader("*stdin*", strings.NewReader("line 1\nline 2\n")); err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff("*stdin*", e.Buffer.Name); diff != "" {
+			t.Error(diff)
+		}
+		if diff := cmp.Diff("", e.Buffer.Path); diff != "" {
+			t.Error(diff)
+		}
+		if diff := cmp.Diff([]string{"line 1", "line 2"}, e.Buffer.Lines); diff != "" {
+			t.Error(diff)
+		}
+	})
+	t.Run("DrawWindow() with tabs", func(t *testing.T) {
+		e := editor.New()
+		if err := e.OpenReader("tabs", strings.NewReader("\tx\nabcdefghij\tk\n")); err != nil {
+			t.Fatal(err)
+		}
+		e.Layout.Arrange(0, 0, 12, 5)
+		e.Screen.SetPosition(0, 1)
+		out, err := os.Create(filepath.Join(t.TempDir(), "out"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		e.Out = out
+		e.DrawWindow(e.Window)
+		out.Close()
+		data, err := os.ReadFile(out.Name())
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, row := range regexp.MustCompile(`\x1b\[\d+;\d+H`).Split(string(data), -1)[2:] {
+			if strings.Contains(row, "\t") || editor.StringWidth(row) != 12 {
+				t.Errorf("%q does not fill the width of the window", row)
+			}
+			got = append(got, strings.TrimRight(row, " "))
+		}
+		if diff := cmp.Diff([]string{"        x", "abcdefghij", "k", "~"}, got); diff != "" {
+			t.Error(diff)
+		}
+		x, _ := e.Screen.CursorPosition()
+		if diff := cmp.Diff(8, x); diff != "" {
+			t.Error(diff)
+		}
+	})
+	t.Run("SetLineEnding()", func(t *testing.T) {
+		e := editor.New()
+		if err := e.OpenReader("mixed", strings.NewReader("a\r\nb\n")); err != nil {
+			t.Fatal(err)
+		}
+		e.Layout.Arrange(0, 0, 80, 24)
+		e.SetLineEnding("CRLF")
+		if diff := cmp.Diff([]string{"a", "b"}, e.Buffer.Lines); diff != "" {
+			t.Error(diff)
+		}
+		if diff := cmp.Diff("CRLF", e.Buffer.LineEnding); diff != "" {
+			t.Error(diff)
+		}
+		if diff := cmp.Diff(true, e.Buffer.Dirty); diff != "" {
+			t.Error(diff)
+		}
+		var buf bytes.Buffer
+		if _, err := e.Buffer.WriteTo(&buf); err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff("a\r\nb\r\n", buf.String()); diff != "" {
+			t.Error(diff)
+		}
+	})
+
+	t.Run("ReadRunes()", func(t *testing.T) {
+		r, w, err := os.Pipe()
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { r.Close(); w.Close() })
+		e := editor.New()
+		e.In = r
+		rs := e.ReadRunes(context.Background())
+		// A rune split across writes is read whole.
+		w.Write([]byte("a\xe3"))
+		time.Sleep(10 * time.Millisecond)
+		w.Write([]byte("\x81\x82bcd"))
+		var got []rune
+		for i := 0; i < 3; i++ {
+			got = append(got, <-rs)
+		}
+		if diff := cmp.Diff([]rune("aあb"), got); diff != "" {
+			t.Error(diff)
+		}
+		// The bytes after the rune waiting to be received are left to the programs using the terminal.
+		time.Sleep(10 * time.Millisecond)
+		r.SetReadDeadline(time.Now().Add(time.Second))
+		rest := make([]byte, 8)
+		n, err := r.Read(rest)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff("d", string(rest[:n])); diff != "" {
+			t.Error(diff)
+		}
+	})
+}
+
+// TestSuspend runs the test binary again as a job stopped by Suspend, and continues it.
+func TestSuspend(t *testing.T) {
+	if os.Getenv("RE_TEST_SUSPEND") != "" {
+		e := editor.New()
+		e.Suspend()
+		if e.Message != nil {
+			fmt.Print(e.Message.Text)
+			os.Exit(2)
+		}
+		fmt.Print("resumed")
+		os.Exit(0)
+	}
+	cmd := exec.Command(os.Args[0], "-test.run=^TestSuspend$")
+	cmd.Env = append(os.Environ(), "RE_TEST_SUSPEND=1")
+	// The job is a process group of its own, so that the tests do not stop with it.
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	var ws syscall.WaitStatus
+	if _, err := syscall.Wait4(cmd.Process.Pid, &ws, syscall.WUNTRACED, nil); err != nil {
+		t.Fatal(err)
+	}
+	if ws.Exited() && ws.ExitStatus() == 2 {
+		t.Skip("SIGTSTP is ignored without job control")
+	}
+	if !ws.Stopped() || ws.StopSignal() != syscall.SIGTSTP {
+		t.Fatalf("process not stopped: %v", ws)
+	}
+	if err := syscall.Kill(cmd.Process.Pid, syscall.SIGCONT); err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Wait(); err != nil {
+		t.Fatal(err)
+	}
+	if diff := cmp.Diff("resumed", out.String()); diff != "" {
+		t.Error(diff)
+	}
 }
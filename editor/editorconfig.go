@@ -0,0 +1,473 @@
+package editor
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// editorConfigName is the name of the files setting the properties of the files in their
+// directory and below, as specified at https://editorconfig.org.
+const editorConfigName = ".editorconfig"
+
+// EditorConfigSection is a section of an .editorconfig file: the properties of the files
+// matching Glob.
+type EditorConfigSection struct {
+	Glob       string
+	Properties map[string]string
+}
+
+// EditorConfigFile is a parsed .editorconfig file.
+type EditorConfigFile struct {
+	// Root stops the search for .editorconfig files in the directories above.
+	Root     bool
+	Sections []EditorConfigSection
+}
+
+// ParseEditorConfig parses an .editorconfig file. Keys, and values of the properties where case
+// does not matter, are lowercased.
+func ParseEditorConfig(r io.Reader) (*EditorConfigFile, error) {
+	f := &EditorConfigFile{}
+	var sec *EditorConfigSection
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		switch {
+		case line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";"):
+		case strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]"):
+			f.Sections = append(f.Sections, EditorConfigSection{Glob: line[1 : len(line)-1], Properties: map[string]string{}})
+			sec = &f.Sections[len(f.Sections)-1]
+		case strings.Contains(line, "="):
+			i := strings.Index(line, "=")
+			key, value := strings.ToLower(strings.TrimSpace(line[:i])), strings.TrimSpace(line[i+1:])
+			if key != "max_line_length" && key != "indent_size" && key != "tab_width" || value == "unset" {
+				value = strings.ToLower(value)
+			}
+			if sec == nil {
+				if key == "root" {
+					f.Root = strings.ToLower(value) == "true"
+				}
+				continue
+			}
+			sec.Properties[key] = value
+		default:
+			return nil, fmt.Errorf("line %d: invalid entry: %s", n, line)
+		}
+	}
+	return f, sc.Err()
+}
+
+// Match reports whether the glob of s matches the file at path, relative to the directory of
+// the .editorconfig file in slash-separated form.
+func (s *EditorConfigSection) Match(path string) bool {
+	glob := s.Glob
+	if !strings.Contains(glob, "/") {
+		// A glob without a slash matches files by name in any directory.
+		glob = "**/" + glob
+	}
+	glob = strings.TrimPrefix(glob, "/")
+	re, ranges, err := editorConfigRegexp(glob)
+	if err != nil {
+		return false
+	}
+	m := re.FindStringSubmatch(path)
+	if m == nil {
+		return false
+	}
+	for i, r := range ranges {
+		n, err := strconv.Atoi(m[i+1])
+		if err != nil || n < r[0] || n > r[1] {
+			return false
+		}
+	}
+	return true
+}
+
+var numberRange = regexp.MustCompile(`^([+-]?\d+)\.\.([+-]?\d+)$`)
+
+// editorConfigRegexp translates the glob of a section into a regexp. The ranges of the numbers
+// matched by its groups in order, from {n1..n2} in the glob, are returned with it.
+func editorConfigRegexp(glob string) (*regexp.Regexp, [][2]int, error) {
+	var ranges [][2]int
+	s := translateGlob([]rune(glob), &ranges)
+	re, err := regexp.Compile("^" + s + "$")
+	return re, ranges, err
+}
+
+func translateGlob(rs []rune, ranges *[][2]int) string {
+	var sb strings.Builder
+	for i := 0; i < len(rs); i++ {
+		switch r := rs[i]; r {
+		case '\\':
+			if i+1 < len(rs) {
+				i++
+			}
+			sb.WriteString(regexp.QuoteMeta(string(rs[i])))
+		case '*':
+			if i+1 < len(rs) && rs[i+1] == '*' {
+				i++
+				if i+1 < len(rs) && rs[i+1] == '/' {
+					// "**/" also matches no directory.
+					i++
+					sb.WriteString("(?:.*/)?")
+				} else {
+					sb.WriteString(".*")
+				}
+			} else {
+				sb.WriteString("[^/]*")
+			}
+		case '?':
+			sb.WriteString("[^/]")
+		case '[':
+			end := classEnd(rs, i)
+			if end < 0 {
+				sb.WriteString(`\[`)
+				continue
+			}
+			sb.WriteString("[")
+			j := i + 1
+			if rs[j] == '!' || rs[j] == '^' {
+				sb.WriteString("^")
+				j++
+			}
+			for ; j < end; j++ {
+				if rs[j] == '\\' && j+1 < end {
+					j++
+				}
+				if rs[j] == '-' && j > i+1 && j+1 < end {
+					sb.WriteRune('-')
+					continue
+				}
+				sb.WriteString(regexp.QuoteMeta(string(rs[j])))
+			}
+			sb.WriteString("]")
+			i = end
+		case '{':
+			end := braceEnd(rs, i)
+			if end < 0 {
+				sb.WriteString(`\{`)
+				continue
+			}
+			inner := rs[i+1 : end]
+			if m := numberRange.FindStringSubmatch(string(inner)); m != nil {
+				lo, _ := strconv.Atoi(m[1])
+				hi, _ := strconv.Atoi(m[2])
+				if lo > hi {
+					lo, hi = hi, lo
+				}
+				*ranges = append(*ranges, [2]int{lo, hi})
+				sb.WriteString(`([+-]?\d+)`)
+				i = end
+				continue
+			}
+			alts := splitAlternatives(inner)
+			if len(alts) == 1 {
+				// A brace without alternatives is taken literally.
+				sb.WriteString(`\{` + translateGlob(inner, ranges) + `\}`)
+				i = end
+				continue
+			}
+			sb.WriteString("(?:")
+			for k, alt := range alts {
+				if k > 0 {
+					sb.WriteString("|")
+				}
+				sb.WriteString(translateGlob(alt, ranges))
+			}
+			sb.WriteString(")")
+			i = end
+		default:
+			sb.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	return sb.String()
+}
+
+// classEnd returns the index of the ']' closing the bracket expression at i, or -1 if there is
+// none or the expression contains a slash, which it cannot match.
+func classEnd(rs []rune, i int) int {
+	j := i + 1
+	if j < len(rs) && (rs[j] == '!' || rs[j] == '^') {
+		j++
+	}
+	if j < len(rs) && rs[j] == ']' {
+		j++ // a ']' first is literal
+	}
+	for ; j < len(rs); j++ {
+		switch rs[j] {
+		case '\\':
+			j++
+		case '/':
+			return -1
+		case ']':
+			return j
+		}
+	}
+	return -1
+}
+
+// braceEnd returns the index of the '}' closing the brace at i, or -1.
+func braceEnd(rs []rune, i int) int {
+	depth := 0
+	for j := i; j < len(rs); j++ {
+		switch rs[j] {
+		case '\\':
+			j++
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				return j
+			}
+		}
+	}
+	return -1
+}
+
+// splitAlternatives splits the inside of braces at the commas outside nested braces.
+func splitAlternatives(rs []rune) [][]rune {
+	var alts [][]rune
+	depth, start := 0, 0
+	for j := 0; j < len(rs); j++ {
+		switch rs[j] {
+		case '\\':
+			j++
+		case '{':
+			depth++
+		case '}':
+			depth--
+		case ',':
+			if depth == 0 {
+				alts = append(alts, rs[start:j])
+				start = j + 1
+			}
+		}
+	}
+	return append(alts, rs[start:])
+}
+
+// LoadEditorConfig returns the properties of the file at path set by the .editorconfig files in
+// its directory and the directories above, up to the one with root set. Properties set closer to
+// the file, and later in a file, take precedence. Properties set to "unset" are removed.
+func LoadEditorConfig(path string) (map[string]string, error) {
+	path, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	type found struct {
+		dir  string
+		file *EditorConfigFile
+	}
+	var files []found
+	for dir := filepath.Dir(path); ; {
+		f, err := os.Open(filepath.Join(dir, editorConfigName))
+		if err == nil {
+			ec, err := ParseEditorConfig(f)
+			f.Close()
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", filepath.Join(dir, editorConfigName), err)
+			}
+			files = append(files, found{dir, ec})
+			if ec.Root {
+				break
+			}
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			break
+		}
+		dir = parent
+	}
+	props := map[string]string{}
+	for i := len(files) - 1; i >= 0; i-- {
+		rel, err := filepath.Rel(files[i].dir, path)
+		if err != nil {
+			return nil, err
+		}
+		rel = filepath.ToSlash(rel)
+		for _, sec := range files[i].file.Sections {
+			if !sec.Match(rel) {
+				continue
+			}
+			for k, v := range sec.Properties {
+				if v == "unset" {
+					delete(props, k)
+				} else {
+					props[k] = v
+				}
+			}
+		}
+	}
+	if props["indent_style"] == "tab" && props["indent_size"] == "" {
+		props["indent_size"] = "tab"
+	}
+	if props["indent_size"] == "tab" && props["tab_width"] != "" {
+		props["indent_size"] = props["tab_width"]
+	}
+	if _, err := strconv.Atoi(props["indent_size"]); err == nil && props["tab_width"] == "" {
+		props["tab_width"] = props["indent_size"]
+	}
+	return props, nil
+}
+
+// editorConfigCharsets are the encodings of the charsets of EditorConfig, and whether UTF-8
+// files start with a byte order mark. UTF-16 files keep theirs.
+var editorConfigCharsets = map[string]struct {
+	enc string
+	bom bool
+}{
+	"latin1":    {"Latin-1", false},
+	"utf-8":     {"UTF-8", false},
+	"utf-8-bom": {"UTF-8", true},
+	"utf-16be":  {"UTF-16BE", false},
+	"utf-16le":  {"UTF-16LE", false},
+}
+
+// applyEditorConfig sets the options of b from the .editorconfig files for its file. Invalid
+// properties are reported after the valid ones are applied.
+func (e *Editor) applyEditorConfig(b *Buffer) error {
+	if b.Path == "" {
+		return nil
+	}
+	props, err := LoadEditorConfig(b.Path)
+	if err != nil || len(props) == 0 {
+		return err
+	}
+	var errs []string
+	invalid := func(key string) {
+		errs = append(errs, fmt.Sprintf("%s = %s", key, props[key]))
+	}
+	number := func(key string) (int, bool) {
+		v, ok := props[key]
+		if !ok {
+			return 0, false
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			invalid(key)
+			return 0, false
+		}
+		return n, true
+	}
+
+	switch props["indent_style"] {
+	case "":
+	case "tab":
+		b.Indent.Tabs = true
+	case "space":
+		b.Indent.Tabs = false
+	default:
+		invalid("indent_style")
+	}
+	tabWidth := b.Indent.tabWidth()
+	if n, ok := number("tab_width"); ok {
+		b.Indent.TabWidth = n
+	}
+	if props["indent_size"] == "tab" {
+		b.Indent.Width = b.Indent.tabWidth()
+	} else if n, ok := number("indent_size"); ok {
+		b.Indent.Width = n
+	}
+	if b.Indent.tabWidth() != tabWidth {
+		// Tabs are laid out to the new tab stops.
+		e.BufferReloaded(b)
+	}
+	switch v := props["max_line_length"]; v {
+	case "", "off":
+	default:
+		if n, ok := number("max_line_length"); ok {
+			b.Ruler = n
+		}
+	}
+	switch v := props["trim_trailing_whitespace"]; v {
+	case "true", "false":
+		b.TrimTrailingWhitespace = v == "true"
+	case "":
+	default:
+		invalid("trim_trailing_whitespace")
+	}
+
+	// The properties below change the bytes of the file, which large and binary files keep.
+	if b.Large != nil || b.HexMode {
+		return editorConfigError(errs)
+	}
+	switch v := props["end_of_line"]; v {
+	case "lf":
+		b.LineEnding = "LF"
+	case "crlf":
+		b.LineEnding = "CRLF"
+	case "":
+	default:
+		invalid("end_of_line")
+	}
+	switch v := props["insert_final_newline"]; v {
+	case "true", "false":
+		b.FinalNewline = v == "true"
+	case "":
+	default:
+		invalid("insert_final_newline")
+	}
+	if v := props["charset"]; v != "" {
+		cs, ok := editorConfigCharsets[v]
+		if !ok {
+			invalid("charset")
+		} else if cs.enc != b.Encoding {
+			if err := e.redecode(b, cs.enc); err != nil {
+				errs = append(errs, err.Error())
+			}
+		}
+		if ok && cs.enc == "UTF-8" {
+			b.BOM = cs.bom
+		}
+	}
+	return editorConfigError(errs)
+}
+
+// redecode reads the file of b again in the encoding enc, keeping the options given by
+// .editorconfig. A new file only takes the encoding.
+func (e *Editor) redecode(b *Buffer, enc string) error {
+	data, err := os.ReadFile(b.Path)
+	if errors.Is(err, os.ErrNotExist) {
+		b.Encoding = enc
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	eol, final := b.LineEnding, b.FinalNewline
+	if err := b.Decode(data, enc); err != nil {
+		return err
+	}
+	b.LineEnding, b.FinalNewline = eol, final
+	e.BufferReloaded(b)
+	return nil
+}
+
+func editorConfigError(errs []string) error {
+	if len(errs) == 0 {
+		return nil
+	}
+	return fmt.Errorf("invalid .editorconfig properties: %s", strings.Join(errs, ", "))
+}
+
+// trimTrailingWhitespace removes the spaces and tabs at the end of the lines of b.
+func (e *Editor) trimTrailingWhitespace(b *Buffer) {
+	for l, text := range b.Lines {
+		trimmed := strings.TrimRight(text, " \t")
+		if len(trimmed) == len(text) {
+			continue
+		}
+		n := len([]rune(trimmed))
+		_, c := b.Delete(l, n, l, b.LineLen(l))
+		e.BufferChanged(b, c)
+	}
+}
@@ -0,0 +1,202 @@
+package editor_test
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/mizuochikeita/re/editor"
+)
+
+func TestEditorConfig(t *testing.T) {
+	writeFiles := func(t *testing.T, files map[string]string) string {
+		t.Helper()
+		dir := t.TempDir()
+		for name, content := range files {
+			path := filepath.Join(dir, filepath.FromSlash(name))
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return dir
+	}
+
+	t.Run("Match()", func(t *testing.T) {
+		tests := []struct {
+			glob string
+			path string
+			want bool
+		}{
+			{glob: "*", path: "a.go", want: true},
+			{glob: "*", path: "dir/a.go", want: true},
+			{glob: "*.go", path: "dir/a.go", want: true},
+			{glob: "*.go", path: "a.txt", want: false},
+			{glob: "Makefile", path: "dir/Makefile", want: true},
+			{glob: "lib/*.js", path: "lib/a.js", want: true},
+			{glob: "lib/*.js", path: "lib/x/a.js", want: false},
+			{glob: "lib/*.js", path: "src/lib/a.js", want: false},
+			{glob: "lib/**.js", path: "lib/x/a.js", want: true},
+			{glob: "/a.txt", path: "a.txt", want: true},
+			{glob: "/a.txt", path: "dir/a.txt", want: false},
+			{glob: "src/**/a.go", path: "src/a.go", want: true},
+			{glob: "src/**/a.go", path: "src/x/y/a.go", want: true},
+			{glob: "a?c", path: "abc", want: true},
+			{glob: "a?c", path: "a/c", want: false},
+			{glob: "*.{js,py}", path: "a.py", want: true},
+			{glob: "*.{js,py}", path: "a.go", want: false},
+			{glob: "{a,b{c,d}}.txt", path: "bd.txt", want: true},
+			{glob: "{single}.txt", path: "{single}.txt", want: true},
+			{glob: "file[0-9].txt", path: "file5.txt", want: true},
+			{glob: "file[!0-9].txt", path: "file5.txt", want: false},
+			{glob: "file[!0-9].txt", path: "filex.txt", want: true},
+			{glob: "f{1..10}.txt", path: "f10.txt", want: true},
+			{glob: "f{1..10}.txt", path: "f11.txt", want: false},
+			{glob: "f{-3..3}.txt", path: "f-2.txt", want: true},
+			{glob: `\*.txt`, path: "*.txt", want: true},
+			{glob: `\*.txt`, path: "a.txt", want: false},
+			{glob: "[a.txt", path: "[a.txt", want: true},
+		}
+		for _, tt := range tests {
+			s := editor.EditorConfigSection{Glob: tt.glob}
+			if diff := cmp.Diff(tt.want, s.Match(tt.path)); diff != "" {
+				t.Errorf("%s %s: %s", tt.glob, tt.path, diff)
+			}
+		}
+	})
+
+	t.Run("LoadEditorConfig()", func(t *testing.T) {
+		dir := writeFiles(t, map[string]string{
+			".editorconfig": "[*]\ncharset = latin1\n",
+			"root/.editorconfig": "root = true\n\n[*]\nindent_style = space\nindent_size = 4\nend_of_line = LF\n" +
+				"\n[*.go]\nindent_style = tab\n",
+			"root/sub/.editorconfig": "# comment\n[*.go]\nindent_size = 8\n[Makefile]\nindent_style = unset\n",
+		})
+		tests := []struct {
+			desc string
+			path string
+			want map[string]string
+		}{
+			{desc: "root", path: "root/a.txt",
+				want: map[string]string{"indent_style": "space", "indent_size": "4", "tab_width": "4", "end_of_line": "lf"}},
+			{desc: "later section", path: "root/a.go",
+				want: map[string]string{"indent_style": "tab", "indent_size": "4", "tab_width": "4", "end_of_line": "lf"}},
+			{desc: "closer file", path: "root/sub/a.go",
+				want: map[string]string{"indent_style": "tab", "indent_size": "8", "tab_width": "8", "end_of_line": "lf"}},
+			{desc: "unset", path: "root/sub/Makefile",
+				want: map[string]string{"indent_size": "4", "tab_width": "4", "end_of_line": "lf"}},
+			{desc: "above the root", path: "a.txt", want: map[string]string{"charset": "latin1"}},
+		}
+		for _, tt := range tests {
+			got, err := editor.LoadEditorConfig(filepath.Join(dir, filepath.FromSlash(tt.path)))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("%s: %s", tt.desc, diff)
+			}
+		}
+	})
+
+	t.Run("OpenFile()", func(t *testing.T) {
+		dir := writeFiles(t, map[string]string{
+			".editorconfig": "root = true\n[*]\nindent_style = space\nindent_size = 2\nend_of_line = crlf\n" +
+				"insert_final_newline = true\ntrim_trailing_whitespace = true\nmax_line_length = 10\n" +
+				"[*.txt]\ncharset = latin1\n[bad.c]\nindent_size = wide\n",
+			"a.txt": "caf\xc3\xa9  \n\tx",
+		})
+		e := editor.New()
+		path := filepath.Join(dir, "a.txt")
+		if err := e.OpenFile(path); err != nil {
+			t.Fatal(err)
+		}
+		e.Layout.Arrange(0, 0, 80, 24)
+		b := e.Buffer
+		if diff := cmp.Diff([]string{"cafÃ©  ", "\tx"}, b.Lines); diff != "" {
+			t.Error(diff)
+		}
+		if diff := cmp.Diff(editor.IndentOptions{Width: 2, TabWidth: 2}, b.Indent); diff != "" {
+			t.Error(diff)
+		}
+		got := []interface{}{b.Encoding, b.LineEnding, b.FinalNewline, b.TrimTrailingWhitespace, b.Ruler, b.Dirty}
+		if diff := cmp.Diff([]interface{}{"Latin-1", "CRLF", true, true, 10, false}, got); diff != "" {
+			t.Error(diff)
+		}
+
+		e.SaveBuffer()
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff("caf\xc3\xa9\r\n\tx\r\n", string(data)); diff != "" {
+			t.Error(diff)
+		}
+
+		if err := e.OpenFile(filepath.Join(dir, "bad.c")); err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff("invalid .editorconfig properties: indent_size = wide", e.Message.Text); diff != "" {
+			t.Error(diff)
+		}
+		if diff := cmp.Diff(10, e.Buffer.Ruler); diff != "" {
+			t.Error(diff)
+		}
+	})
+
+	t.Run("tab_width", func(t *testing.T) {
+		dir := writeFiles(t, map[string]string{
+			".editorconfig": "root = true\n[*]\nindent_style = tab\ntab_width = 4\n",
+			"a.txt":         "\tx\n",
+		})
+		e := editor.New()
+		if err := e.OpenReader("scratch", strings.NewReader("")); err != nil {
+			t.Fatal(err)
+		}
+		e.Layout.Arrange(0, 0, 80, 24)
+		if err := e.OpenFile(filepath.Join(dir, "a.txt")); err != nil {
+			t.Fatal(err)
+		}
+		// The window shows the tabs at the stops of the file.
+		if diff := cmp.Diff([]int{0, 4, 5}, e.Screen.Rows[0].ScreenXs); diff != "" {
+			t.Error(diff)
+		}
+		e.Screen.SetPosition(0, 1)
+		x, _ := e.Screen.CursorPosition()
+		if diff := cmp.Diff(4, x); diff != "" {
+			t.Error(diff)
+		}
+	})
+
+	t.Run("ruler", func(t *testing.T) {
+		e := editor.New()
+		if err := e.OpenReader("lines", strings.NewReader("short\nlonger line\n")); err != nil {
+			t.Fatal(err)
+		}
+		e.Buffer.Ruler = 8
+		e.Layout.Arrange(0, 0, 15, 5)
+		out, err := os.Create(filepath.Join(t.TempDir(), "out"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		e.Out = out
+		e.DrawWindow(e.Window)
+		out.Close()
+		data, err := os.ReadFile(out.Name())
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, row := range regexp.MustCompile(`\x1b\[\d+;\d+H`).Split(string(data), -1)[2:] {
+			got = append(got, strings.TrimRight(row, " "))
+		}
+		want := []string{"short   \x1b[2m|\x1b[0m", "longer line", "~", "~"}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Error(diff)
+		}
+	})
+}
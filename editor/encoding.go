@@ -0,0 +1,324 @@
+package editor
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Encodings are the character encodings files are read and written in.
+var Encodings = []string{"UTF-8", "UTF-16LE", "UTF-16BE", "Shift_JIS", "EUC-JP", "Latin-1"}
+
+// utf8BOM is the byte order mark some editors put at the start of UTF-8 files.
+const utf8BOM = "\xef\xbb\xbf"
+
+// escapeBase is the first of the 256 runes standing for bytes which are invalid in the encoding
+// of a file. They are in a private use area, show as \xNN and are written back as the bytes.
+const escapeBase = 0x10FF00
+
+// EscapeRune returns the rune standing for the invalid byte c.
+func EscapeRune(c byte) rune {
+	return escapeBase + rune(c)
+}
+
+// EscapedByte returns the byte r stands for if r is an escape rune.
+func EscapedByte(r rune) (byte, bool) {
+	if r < escapeBase || r > escapeBase+0xff {
+		return 0, false
+	}
+	return byte(r - escapeBase), true
+}
+
+// jis0208 is jis0208Rows indexed by pointer, (row-1)*94 + (col-1).
+var jis0208 [len(jis0208Rows) * 94]rune
+
+// sjisPointers and eucPointers map characters to their pointers in jis0208 for encoding.
+// Shift_JIS prefers the IBM extensions to their NEC selected duplicates, as WHATWG does.
+var sjisPointers, eucPointers = map[rune]int{}, map[rune]int{}
+
+func init() {
+	for i, row := range jis0208Rows {
+		if row == "" {
+			continue
+		}
+		for j, r := range []rune(row) {
+			if r == 0 {
+				continue
+			}
+			p := i*94 + j
+			jis0208[p] = r
+			if _, ok := eucPointers[r]; !ok {
+				eucPointers[r] = p
+			}
+			if _, ok := sjisPointers[r]; !ok && (p < 8272 || p > 8835) {
+				sjisPointers[r] = p
+			}
+		}
+	}
+	for r, p := range eucPointers {
+		if _, ok := sjisPointers[r]; !ok {
+			sjisPointers[r] = p
+		}
+	}
+}
+
+// encodingBOM returns the byte order mark of enc.
+func encodingBOM(enc string) string {
+	switch enc {
+	case "UTF-8":
+		return utf8BOM
+	case "UTF-16LE":
+		return "\xff\xfe"
+	case "UTF-16BE":
+		return "\xfe\xff"
+	}
+	return ""
+}
+
+// DetectEncoding guesses the encoding of data and returns it with the length of its byte order mark.
+// UTF-16 is only detected by its byte order mark. Data which is not mostly valid UTF-8 is taken as
+// Shift_JIS or EUC-JP if either decodes it mostly to multibyte characters, and Latin-1 otherwise.
+func DetectEncoding(data []byte) (string, int) {
+	for _, enc := range []string{"UTF-8", "UTF-16LE", "UTF-16BE"} {
+		if bom := encodingBOM(enc); bytes.HasPrefix(data, []byte(bom)) {
+			return enc, len(bom)
+		}
+	}
+	best := ""
+	var bestInvalid, bestMulti, bestHalfWidth int
+	for _, enc := range []string{"UTF-8", "Shift_JIS", "EUC-JP"} {
+		s, invalid, multi := decode(enc, data)
+		// Valid multibyte UTF-8 hardly appears by chance in other encodings.
+		if enc == "UTF-8" && (invalid == 0 || multi >= invalid) {
+			return enc, 0
+		}
+		halfWidth := 0
+		for _, r := range s {
+			if r >= 0xff61 && r <= 0xff9f {
+				halfWidth++
+			}
+		}
+		// EUC-JP often decodes as Shift_JIS half-width katakana, which are single bytes.
+		if best == "" || invalid < bestInvalid || invalid == bestInvalid && multi > bestMulti {
+			best, bestInvalid, bestMulti, bestHalfWidth = enc, invalid, multi, halfWidth
+		}
+	}
+	// Accented Latin-1 letters decode as Shift_JIS half-width katakana and scattered kanji.
+	if bestMulti > 4*bestInvalid && bestMulti > bestHalfWidth {
+		return best, 0
+	}
+	return "Latin-1", 0
+}
+
+// Decode converts data in the encoding enc to a string. Invalid bytes become escape runes.
+func Decode(enc string, data []byte) (string, error) {
+	if !knownEncoding(enc) {
+		return "", fmt.Errorf("unknown encoding: %s", enc)
+	}
+	s, _, _ := decode(enc, data)
+	return s, nil
+}
+
+func knownEncoding(enc string) bool {
+	for _, e := range Encodings {
+		if e == enc {
+			return true
+		}
+	}
+	return false
+}
+
+// decode returns data decoded in enc with the numbers of invalid sequences and of multibyte characters.
+func decode(enc string, data []byte) (string, int, int) {
+	var sb strings.Builder
+	invalid, multi := 0, 0
+	escape := func(bs []byte) {
+		for _, c := range bs {
+			sb.WriteRune(EscapeRune(c))
+		}
+	}
+	for len(data) > 0 {
+		r, n := decodeRune(enc, data)
+		switch {
+		case r < 0:
+			escape(data[:n])
+			invalid++
+		case r >= escapeBase:
+			// A valid character in the range of the escape runes is kept as its bytes.
+			escape(data[:n])
+		default:
+			sb.WriteRune(r)
+			if n > 1 {
+				multi++
+			}
+		}
+		data = data[n:]
+	}
+	return sb.String(), invalid, multi
+}
+
+// decodeRune decodes the first character of data and returns it with its length in bytes.
+// The rune is -1 if the bytes are invalid.
+func decodeRune(enc string, data []byte) (rune, int) {
+	c := data[0]
+	switch enc {
+	case "UTF-8":
+		r, n := utf8.DecodeRune(data)
+		if r == utf8.RuneError && n == 1 {
+			return -1, 1
+		}
+		return r, n
+	case "UTF-16LE", "UTF-16BE":
+		if len(data) < 2 {
+			return -1, 1
+		}
+		u := utf16Unit(enc, data)
+		if !utf16.IsSurrogate(u) {
+			return u, 2
+		}
+		if len(data) >= 4 {
+			if r := utf16.DecodeRune(u, utf16Unit(enc, data[2:])); r != utf8.RuneError {
+				return r, 4
+			}
+		}
+		return -1, 2
+	case "Shift_JIS":
+		switch {
+		case c < 0x80:
+			return rune(c), 1
+		case c >= 0xa1 && c <= 0xdf:
+			return 0xff61 + rune(c-0xa1), 1
+		case c >= 0x81 && c <= 0x9f || c >= 0xe0 && c <= 0xfc:
+			if len(data) < 2 {
+				return -1, 1
+			}
+			t := data[1]
+			if t < 0x40 || t == 0x7f || t > 0xfc {
+				return -1, 1
+			}
+			lead, trail := int(c)-0x81, int(t)-0x40
+			if c >= 0xa0 {
+				lead = int(c) - 0xc1
+			}
+			if t >= 0x80 {
+				trail = int(t) - 0x41
+			}
+			p := lead*188 + trail
+			if p >= 8836 && p <= 10715 {
+				return 0xe000 + rune(p-8836), 2
+			}
+			if r := jis0208[p]; r != 0 {
+				return r, 2
+			}
+		}
+		return -1, 1
+	case "EUC-JP":
+		switch {
+		case c < 0x80:
+			return rune(c), 1
+		case c == 0x8e && len(data) >= 2 && data[1] >= 0xa1 && data[1] <= 0xdf:
+			return 0xff61 + rune(data[1]-0xa1), 2
+		case c >= 0xa1 && c <= 0xfe && len(data) >= 2 && data[1] >= 0xa1 && data[1] <= 0xfe:
+			if r := jis0208[(int(c)-0xa1)*94+int(data[1])-0xa1]; r != 0 {
+				return r, 2
+			}
+		}
+		// JIS X 0212 after 0x8f is not supported and kept as escapes.
+		return -1, 1
+	default: // Latin-1
+		// The C1 control characters would be interpreted by the terminal.
+		if c >= 0x80 && c < 0xa0 {
+			return -1, 1
+		}
+		return rune(c), 1
+	}
+}
+
+func utf16Unit(enc string, data []byte) rune {
+	if enc == "UTF-16LE" {
+		return rune(data[0]) | rune(data[1])<<8
+	}
+	return rune(data[0])<<8 | rune(data[1])
+}
+
+// Encode converts s to the encoding enc. Escape runes are written as the bytes they stand for.
+func Encode(enc string, s string) ([]byte, error) {
+	if !knownEncoding(enc) {
+		return nil, fmt.Errorf("unknown encoding: %s", enc)
+	}
+	buf := make([]byte, 0, len(s))
+	for _, r := range s {
+		if c, ok := EscapedByte(r); ok {
+			buf = append(buf, c)
+			continue
+		}
+		bs, ok := encodeRune(enc, r)
+		if !ok {
+			return nil, fmt.Errorf("cannot encode %q (U+%04X) in %s", r, r, enc)
+		}
+		buf = append(buf, bs...)
+	}
+	return buf, nil
+}
+
+func encodeRune(enc string, r rune) ([]byte, bool) {
+	switch enc {
+	case "UTF-8":
+		return []byte(string(r)), true
+	case "UTF-16LE", "UTF-16BE":
+		var bs []byte
+		for _, u := range utf16.Encode([]rune{r}) {
+			if enc == "UTF-16LE" {
+				bs = append(bs, byte(u), byte(u>>8))
+			} else {
+				bs = append(bs, byte(u>>8), byte(u))
+			}
+		}
+		return bs, true
+	case "Shift_JIS":
+		switch {
+		case r < 0x80:
+			return []byte{byte(r)}, true
+		case r >= 0xff61 && r <= 0xff9f:
+			return []byte{byte(r - 0xff61 + 0xa1)}, true
+		}
+		p, ok := sjisPointers[r]
+		if r >= 0xe000 && r <= 0xe757 {
+			p, ok = int(r-0xe000)+8836, true
+		}
+		if !ok {
+			return nil, false
+		}
+		lead, trail := p/188, p%188
+		if lead < 0x1f {
+			lead += 0x81
+		} else {
+			lead += 0xc1
+		}
+		if trail < 0x3f {
+			trail += 0x40
+		} else {
+			trail += 0x41
+		}
+		return []byte{byte(lead), byte(trail)}, true
+	case "EUC-JP":
+		switch {
+		case r < 0x80:
+			return []byte{byte(r)}, true
+		case r >= 0xff61 && r <= 0xff9f:
+			return []byte{0x8e, byte(r - 0xff61 + 0xa1)}, true
+		}
+		p, ok := eucPointers[r]
+		if !ok || p >= 94*94 {
+			return nil, false
+		}
+		return []byte{byte(0xa1 + p/94), byte(0xa1 + p%94)}, true
+	default: // Latin-1
+		if r > 0xff {
+			return nil, false
+		}
+		return []byte{byte(r)}, true
+	}
+}
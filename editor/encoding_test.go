@@ -0,0 +1,114 @@
+package editor_test
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/mizuochikeita/re/editor"
+)
+
+const (
+	sampleText      = "こんにちは、世界。ｶﾀｶﾅ①"
+	sampleShiftJIS  = "\x82\xb1\x82\xf1\x82\xc9\x82\xbf\x82\xcd\x81\x41\x90\xa2\x8a\x45\x81\x42\xb6\xc0\xb6\xc5\x87\x40"
+	sampleEUCJP     = "\xa4\xb3\xa4\xf3\xa4\xcb\xa4\xc1\xa4\xcf\xa1\xa2\xc0\xa4\xb3\xa6\xa1\xa3\x8e\xb6\x8e\xc0\x8e\xb6\x8e\xc5\xad\xa1"
+	sampleUTF16LE   = "\x53\x30\x93\x30\x6b\x30\x61\x30\x6f\x30\x01\x30\x16\x4e\x4c\x75\x02\x30\x76\xff\x80\xff\x76\xff\x85\xff\x60\x24"
+	sampleUTF16BE   = "\x30\x53\x30\x93\x30\x6b\x30\x61\x30\x6f\x30\x01\x4e\x16\x75\x4c\x30\x02\xff\x76\xff\x80\xff\x76\xff\x85\x24\x60"
+	sampleLatin1    = "caf\xe9"
+	sampleLatin1Str = "café"
+)
+
+func TestDetectEncoding(t *testing.T) {
+	tests := []struct {
+		desc    string
+		data    string
+		want    string
+		wantBOM int
+	}{
+		{desc: "ASCII", data: "hello\n", want: "UTF-8"},
+		{desc: "UTF-8", data: sampleText, want: "UTF-8"},
+		{desc: "UTF-8 with BOM", data: "\xef\xbb\xbf" + sampleText, want: "UTF-8", wantBOM: 3},
+		{desc: "UTF-8 with an invalid byte", data: sampleText + "\xff" + sampleText, want: "UTF-8"},
+		{desc: "UTF-16LE", data: "\xff\xfe" + sampleUTF16LE, want: "UTF-16LE", wantBOM: 2},
+		{desc: "UTF-16BE", data: "\xfe\xff" + sampleUTF16BE, want: "UTF-16BE", wantBOM: 2},
+		{desc: "Shift_JIS", data: sampleShiftJIS, want: "Shift_JIS"},
+		{desc: "EUC-JP", data: sampleEUCJP, want: "EUC-JP"},
+		{desc: "Latin-1", data: sampleLatin1, want: "Latin-1"},
+		{desc: "short UTF-8 with an invalid byte", data: "\xe3\x81\x93\xff", want: "UTF-8"},
+		{desc: "Latin-1 sentence", data: "\xe9t\xe9 \xe0 la for\xeat, gar\xe7on d\xe9\xe7u", want: "Latin-1"},
+	}
+	for _, tt := range tests {
+		enc, bom := editor.DetectEncoding([]byte(tt.data))
+		if diff := cmp.Diff([]interface{}{tt.want, tt.wantBOM}, []interface{}{enc, bom}); diff != "" {
+			t.Errorf("%s: %s", tt.desc, diff)
+		}
+	}
+}
+
+func TestDecode(t *testing.T) {
+	esc := func(c byte) string {
+		return string(editor.EscapeRune(c))
+	}
+	tests := []struct {
+		desc string
+		enc  string
+		data string
+		want string
+	}{
+		{desc: "UTF-8", enc: "UTF-8", data: sampleText, want: sampleText},
+		{desc: "UTF-16LE", enc: "UTF-16LE", data: sampleUTF16LE, want: sampleText},
+		{desc: "UTF-16BE", enc: "UTF-16BE", data: sampleUTF16BE, want: sampleText},
+		{desc: "Shift_JIS", enc: "Shift_JIS", data: sampleShiftJIS, want: sampleText},
+		{desc: "EUC-JP", enc: "EUC-JP", data: sampleEUCJP, want: sampleText},
+		{desc: "Latin-1", enc: "Latin-1", data: sampleLatin1, want: sampleLatin1Str},
+		{desc: "invalid UTF-8", enc: "UTF-8", data: "a\xffb\xe3\x81", want: "a" + esc(0xff) + "b" + esc(0xe3) + esc(0x81)},
+		{desc: "unpaired surrogate", enc: "UTF-16LE", data: "a\x00\x00\xd8b\x00\x01", want: "a" + esc(0x00) + esc(0xd8) + "b" + esc(0x01)},
+		{desc: "invalid Shift_JIS trail byte", enc: "Shift_JIS", data: "\x82 \xa0", want: esc(0x82) + " " + esc(0xa0)},
+		{desc: "escape range in UTF-8", enc: "UTF-8", data: "\xf4\x8f\xbc\x80", want: esc(0xf4) + esc(0x8f) + esc(0xbc) + esc(0x80)},
+	}
+	for _, tt := range tests {
+		got, err := editor.Decode(tt.enc, []byte(tt.data))
+		if err != nil {
+			t.Fatalf("%s: %v", tt.desc, err)
+		}
+		if diff := cmp.Diff(tt.want, got); diff != "" {
+			t.Errorf("%s: %s", tt.desc, diff)
+		}
+		data, err := editor.Encode(tt.enc, got)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.desc, err)
+		}
+		if diff := cmp.Diff(tt.data, string(data)); diff != "" {
+			t.Errorf("%s: round-trip: %s", tt.desc, diff)
+		}
+	}
+}
+
+func TestEncode(t *testing.T) {
+	tests := []struct {
+		desc    string
+		enc     string
+		s       string
+		want    string
+		wantErr bool
+	}{
+		{desc: "Shift_JIS", enc: "Shift_JIS", s: sampleText, want: sampleShiftJIS},
+		{desc: "user-defined area", enc: "Shift_JIS", s: "", want: "\xf0\x40"},
+		{desc: "not in Latin-1", enc: "Latin-1", s: "あ", wantErr: true},
+		{desc: "unknown encoding", enc: "KOI8-R", s: "a", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := editor.Encode(tt.enc, tt.s)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: no error", tt.desc)
+			}
+			continue
+		}
+		if err != nil {
+			t.Fatalf("%s: %v", tt.desc, err)
+		}
+		if diff := cmp.Diff(tt.want, string(got)); diff != "" {
+			t.Errorf("%s: %s", tt.desc, diff)
+		}
+	}
+}
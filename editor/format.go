@@ -0,0 +1,186 @@
+package editor
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// formatTimeout is how long a formatter may run before it is killed.
+const formatTimeout = 10 * time.Second
+
+// formatCommand returns the formatter of the file type of b, the command in the [format.<file
+// type>] section of the config.
+func (e *Editor) formatCommand(b *Buffer) (string, bool) {
+	if b.Path == "" {
+		return "", false
+	}
+	cmd, ok := e.Config.Get("format."+FileType(b.Path), "command")
+	return cmd, ok && cmd != ""
+}
+
+// FormatBuffer pipes the current buffer through the formatter for its file type, and shows what
+// the formatter wrote to stderr.
+func (e *Editor) FormatBuffer() {
+	if _, ok := e.formatCommand(e.Buffer); !ok {
+		e.Error(errors.New("no formatter for the file type"))
+		return
+	}
+	stderr, err := e.format(e.Buffer)
+	switch {
+	case err != nil:
+		e.Error(err)
+	case stderr != "":
+		e.Warnf("%s", stderr)
+	default:
+		e.Infof("Formatted %s", e.Buffer.Name)
+	}
+}
+
+// format replaces the text of b with the output of its formatter if the formatter succeeds.
+// It returns what the formatter wrote to stderr in a line. Buffers without a formatter, and
+// buffers which are not text, are left as they are.
+func (e *Editor) format(b *Buffer) (string, error) {
+	command, ok := e.formatCommand(b)
+	if !ok || b.ReadOnly || b.HexMode || b.Large != nil {
+		return "", nil
+	}
+	text := strings.Join(b.Lines, "\n")
+	if b.FinalNewline {
+		text += "\n"
+	}
+	out, stderr, err := pipeCommand(filepath.Dir(b.Path), command, text, formatTimeout)
+	if err != nil {
+		if stderr == "" {
+			return "", fmt.Errorf("%s: %w", command, err)
+		}
+		return "", fmt.Errorf("%s: %s", command, stderr)
+	}
+	e.replaceText(b, strings.Split(strings.TrimSuffix(out, "\n"), "\n"))
+	return stderr, nil
+}
+
+// pipeCommand runs command with the shell in dir, writing input to its stdin. It returns its
+// stdout, and its stderr in a line.
+func pipeCommand(dir, command, input string, timeout time.Duration) (string, string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Dir = dir
+	cmd.Stdin = strings.NewReader(input)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	if ctx.Err() != nil {
+		err = ctx.Err()
+	}
+	return stdout.String(), oneLine(stderr.String()), err
+}
+
+// oneLine joins the lines of s, for a message.
+func oneLine(s string) string {
+	var lines []string
+	for _, l := range strings.Split(s, "\n") {
+		if l = strings.TrimSpace(l); l != "" {
+			lines = append(lines, l)
+		}
+	}
+	return strings.Join(lines, " ")
+}
+
+// replaceText replaces the lines of b with lines in a single change, which is undone at once,
+// from the first line which differs to the last one. The cursors on the lines replaced stay on
+// the same text as far as only spaces change, counting the other runes from the first line replaced.
+func (e *Editor) replaceText(b *Buffer, lines []string) {
+	old := b.Lines
+	first := 0
+	for first < len(old)-1 && first < len(lines)-1 && old[first] == lines[first] {
+		first++
+	}
+	n := 0
+	for n < len(old)-first-1 && n < len(lines)-first-1 && old[len(old)-1-n] == lines[len(lines)-1-n] {
+		n++
+	}
+	oldEnd, newEnd := len(old)-1-n, len(lines)-1-n
+	if oldEnd == newEnd && old[first] == lines[first] {
+		return
+	}
+	replaced := old[first : oldEnd+1]
+	text := lines[first : newEnd+1]
+
+	var windows []*Window
+	var positions [][2]int
+	for _, w := range e.Layout.Windows() {
+		if l, c := w.Screen.Position(); w.Buffer == b && l >= first && l <= oldEnd {
+			l, c = mapPosition(replaced, text, l-first, c)
+			windows = append(windows, w)
+			positions = append(positions, [2]int{first + l, c})
+		}
+	}
+	// The cursor of the buffer when it is not shown.
+	saved := b.Line >= first && b.Line <= oldEnd
+	line, col := 0, 0
+	if saved {
+		line, col = mapPosition(replaced, text, b.Line-first, b.Col)
+	}
+
+	c := b.Replace(first, 0, oldEnd, b.LineLen(oldEnd), strings.Join(text, "\n"))
+	e.BufferChanged(b, c)
+	for i, w := range windows {
+		w.Screen.SetPosition(positions[i][0], positions[i][1])
+	}
+	if saved {
+		b.Line, b.Col = first+line, col
+	}
+}
+
+// mapPosition returns the position in to of the text at (line, col) in from, which differ only
+// in spaces: before the same rune other than a space, or after it if a space is at the position.
+func mapPosition(from, to []string, line, col int) (int, int) {
+	n := 0
+	for l := 0; l < line; l++ {
+		n += countNonSpace([]rune(from[l]))
+	}
+	rs := []rune(from[line])
+	if col > len(rs) {
+		col = len(rs)
+	}
+	n += countNonSpace(rs[:col])
+	after := col < len(rs) && !unicode.IsSpace(rs[col])
+	if !after && n == 0 {
+		return 0, 0
+	}
+	for l, s := range to {
+		for c, r := range []rune(s) {
+			if unicode.IsSpace(r) {
+				continue
+			}
+			if after && n == 0 {
+				return l, c
+			}
+			n--
+			if !after && n == 0 {
+				return l, c + 1
+			}
+		}
+	}
+	last := len(to) - 1
+	return last, len([]rune(to[last]))
+}
+
+func countNonSpace(rs []rune) int {
+	n := 0
+	for _, r := range rs {
+		if !unicode.IsSpace(r) {
+			n++
+		}
+	}
+	return n
+}
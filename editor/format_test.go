@@ -0,0 +1,91 @@
+package editor_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/mizuochikeita/re/editor"
+)
+
+// fakeFormatter squeezes the spaces in its input, and fails on a line with "syntax". It warns
+// about lines with "warn".
+const fakeFormatter = `#!/bin/sh
+input=$(cat)
+case "$input" in
+*syntax*)
+	echo "<standard input>:1:1: syntax error" >&2
+	echo "exit status 2" >&2
+	exit 2;;
+*warn*)
+	echo "warning" >&2;;
+esac
+printf '%s\n' "$input" | tr -s ' '
+`
+
+func TestFormat(t *testing.T) {
+	script := writeTempFile(t, "fmt.sh", fakeFormatter)
+	if err := os.Chmod(script, 0755); err != nil {
+		t.Fatal(err)
+	}
+	config, err := editor.ParseConfig(strings.NewReader("[format.fmt]\ncommand = " + script + "\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		desc      string
+		text      string
+		pos       [2]int
+		want      string
+		wantPos   [2]int
+		wantLevel editor.MessageLevel
+		wantMsg   string
+	}{
+		{desc: "cursor on the text", text: "one\nx  =   1\ny  =  2\nend\n", pos: [2]int{2, 6},
+			want: "one\nx = 1\ny = 2\nend\n", wantPos: [2]int{2, 4}, wantMsg: "Wrote "},
+		{desc: "cursor on spaces", text: "x  =   1\n", pos: [2]int{0, 5},
+			want: "x = 1\n", wantPos: [2]int{0, 3}, wantMsg: "Wrote "},
+		{desc: "cursor after the change", text: "x  =   1\ny\n", pos: [2]int{1, 1},
+			want: "x = 1\ny\n", wantPos: [2]int{1, 1}, wantMsg: "Wrote "},
+		{desc: "warning", text: "warn  me\n", pos: [2]int{0, 6},
+			want: "warn me\n", wantPos: [2]int{0, 5}, wantLevel: editor.MessageWarning, wantMsg: "warning"},
+		{desc: "failure", text: "a  syntax\n", pos: [2]int{0, 3},
+			want: "a  syntax\n", wantPos: [2]int{0, 3}, wantLevel: editor.MessageError,
+			wantMsg: script + ": <standard input>:1:1: syntax error exit status 2"},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), "a.fmt")
+		if err := os.WriteFile(path, []byte(tt.text), 0644); err != nil {
+			t.Fatal(err)
+		}
+		e := editor.New()
+		e.Config = config
+		if err := e.OpenFile(path); err != nil {
+			t.Fatal(err)
+		}
+		e.Layout.Arrange(0, 0, 80, 24)
+		e.Screen.SetPosition(tt.pos[0], tt.pos[1])
+		e.SaveBuffer()
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(tt.want, string(data)); diff != "" {
+			t.Errorf("%s: %s", tt.desc, diff)
+		}
+		line, col := e.Screen.Position()
+		if diff := cmp.Diff(tt.wantPos, [2]int{line, col}); diff != "" {
+			t.Errorf("%s: %s", tt.desc, diff)
+		}
+		if diff := cmp.Diff(tt.wantLevel, e.Message.Level); diff != "" {
+			t.Errorf("%s: %s", tt.desc, diff)
+		}
+		if !strings.HasPrefix(e.Message.Text, tt.wantMsg) {
+			t.Errorf("%s: message %q, want %q", tt.desc, e.Message.Text, tt.wantMsg)
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package editor
+
+import (
+	"sort"
+	"unicode"
+)
+
+// FuzzyMatch reports whether the runes of pattern appear in s in order, ignoring case.
+// A higher score means a better match: consecutive runes and matches at word starts count more.
+func FuzzyMatch(pattern, s string) (int, bool) {
+	ps := []rune(pattern)
+	if len(ps) == 0 {
+		return 0, true
+	}
+	score := 0
+	i := 0
+	prev := -2
+	var last rune
+	for j, r := range []rune(s) {
+		if i < len(ps) && unicode.ToLower(r) == unicode.ToLower(ps[i]) {
+			score++
+			if prev == j-1 {
+				score += 2
+			}
+			if j == 0 || !unicode.IsLetter(last) && !unicode.IsDigit(last) {
+				score += 3
+			}
+			prev = j
+			i++
+		}
+		last = r
+	}
+	if i < len(ps) {
+		return 0, false
+	}
+	return score, true
+}
+
+// FuzzyFilter returns the candidates matching pattern, best matches first.
+func FuzzyFilter(pattern string, candidates []string) []string {
+	type match struct {
+		s     string
+		score int
+	}
+	var ms []match
+	for _, c := range candidates {
+		if score, ok := FuzzyMatch(pattern, c); ok {
+			ms = append(ms, match{s: c, score: score})
+		}
+	}
+	sort.SliceStable(ms, func(i, j int) bool {
+		return ms[i].score > ms[j].score
+	})
+	res := make([]string, 0, len(ms))
+	for _, m := range ms {
+		res = append(res, m.s)
+	}
+	return res
+}
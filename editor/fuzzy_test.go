@@ -0,0 +1,58 @@
+package editor_test
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/mizuochikeita/re/editor"
+)
+
+func TestFuzzy(t *testing.T) {
+	t.Run("FuzzyMatch()", func(t *testing.T) {
+		tests := []struct {
+			desc    string
+			pattern string
+			s       string
+			want    bool
+		}{
+			{desc: "empty pattern", pattern: "", s: "main.go", want: true},
+			{desc: "subsequence", pattern: "mgo", s: "main.go", want: true},
+			{desc: "ignore case", pattern: "MAIN", s: "main.go", want: true},
+			{desc: "out of order", pattern: "ogm", s: "main.go", want: false},
+		}
+		for _, tt := range tests {
+			_, got := editor.FuzzyMatch(tt.pattern, tt.s)
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("%s: %s", tt.desc, diff)
+			}
+		}
+	})
+
+	t.Run("FuzzyFilter()", func(t *testing.T) {
+		tests := []struct {
+			desc       string
+			pattern    string
+			candidates []string
+			want       []string
+		}{
+			{
+				desc:       "drops unmatched",
+				pattern:    "ed",
+				candidates: []string{"editor.go", "main.go", "screen.go"},
+				want:       []string{"editor.go"},
+			},
+			{
+				desc:       "consecutive match first",
+				pattern:    "sc",
+				candidates: []string{"basic.go", "screen.go"},
+				want:       []string{"screen.go", "basic.go"},
+			},
+		}
+		for _, tt := range tests {
+			got := editor.FuzzyFilter(tt.pattern, tt.candidates)
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("%s: %s", tt.desc, diff)
+			}
+		}
+	})
+}
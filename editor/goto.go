@@ -0,0 +1,343 @@
+package editor
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// GotoTarget is a position to go to, parsed from the goto prompt.
+type GotoTarget struct {
+	// Path is the file to open first, or empty for the current buffer.
+	Path string
+	// Line and Col count from 1. Line 0 keeps the cursor, and Col 0 is the beginning of the line.
+	Line int
+	Col  int
+	// Offset is a byte offset in the file as saved, or -1.
+	Offset int64
+}
+
+// ParseGotoTarget parses "line", "line:col", "#offset" or "0xoffset" for a byte offset. A file
+// name may come first as in "file:line:col: message" printed by compilers, and the rest is ignored.
+func ParseGotoTarget(s string) (GotoTarget, error) {
+	s = strings.TrimSpace(s)
+	t := GotoTarget{Offset: -1}
+	switch {
+	case s == "":
+		return t, errors.New("no position given")
+	case strings.HasPrefix(s, "#"):
+		n, err := strconv.ParseInt(s[1:], 10, 64)
+		if err != nil || n < 0 {
+			return t, fmt.Errorf("invalid offset: %s", s)
+		}
+		t.Offset = n
+		return t, nil
+	case strings.HasPrefix(s, "0x") || strings.HasPrefix(s, "0X"):
+		n, err := strconv.ParseInt(s[2:], 16, 64)
+		if err != nil || n < 0 {
+			return t, fmt.Errorf("invalid offset: %s", s)
+		}
+		t.Offset = n
+		return t, nil
+	}
+	fields := strings.Split(s, ":")
+	if _, err := strconv.Atoi(fields[0]); err != nil {
+		t.Path, fields = fields[0], fields[1:]
+	}
+	for i, f := range fields {
+		n, err := strconv.Atoi(strings.TrimSpace(f))
+		if err != nil || n <= 0 {
+			if i == 0 && t.Path == "" {
+				return t, fmt.Errorf("invalid line: %s", s)
+			}
+			break
+		}
+		if i == 0 {
+			t.Line = n
+		} else {
+			t.Col = n
+			break
+		}
+	}
+	return t, nil
+}
+
+// Goto moves the cursor to the target s parsed by ParseGotoTarget and centers it in the window,
+// remembering the position before in the jump list. The column after a file name counts bytes
+// as in compiler messages, and runes otherwise.
+func (e *Editor) Goto(s string) error {
+	t, err := ParseGotoTarget(s)
+	if err != nil {
+		return err
+	}
+	from := e.currentJump()
+	if t.Path != "" {
+		if err := e.OpenFile(t.Path); err != nil {
+			return err
+		}
+	}
+	col := t.Col - 1
+	if col < 0 {
+		col = 0
+	}
+	switch {
+	case t.Offset >= 0:
+		err = e.gotoOffset(t.Offset)
+	case t.Line > 0 && t.Path != "":
+		err = e.gotoLineByte(t.Line-1, col)
+	case t.Line > 0:
+		err = e.gotoLine(t.Line-1, col)
+	}
+	// The position before is remembered once the cursor has moved, if only to another file.
+	if err == nil || t.Path != "" {
+		e.Jumps.Push(from)
+	}
+	return err
+}
+
+func (e *Editor) PromptGoto() {
+	e.Prompt("goto", "Go to line[:col], #offset or 0xoffset: ", nil, func(s string, ok bool) {
+		if !ok || s == "" {
+			return
+		}
+		e.Error(e.Goto(s))
+	})
+}
+
+// gotoLine moves the cursor to the rune offset col in the line of the file, loading the region
+// around it in a large file, and centers it in the window.
+func (e *Editor) gotoLine(line, col int) error {
+	b := e.Buffer
+	if b.HexMode {
+		return errNotText
+	}
+	if b.Large != nil && (line < b.FirstLine || line >= b.FirstLine+len(b.Lines)) {
+		if err := e.loadRegion(int64(line)); err != nil {
+			return err
+		}
+	}
+	e.Screen.SetPosition(line-b.FirstLine, col)
+	e.Screen.CenterCursor()
+	return nil
+}
+
+// gotoLineByte moves the cursor to the rune at the byte offset col in the line of the file,
+// like gotoLine.
+func (e *Editor) gotoLineByte(line, col int) error {
+	if err := e.gotoLine(line, 0); err != nil {
+		return err
+	}
+	b := e.Buffer
+	l, _ := e.Screen.Position()
+	return e.gotoLine(line, runeOffset(b.Lines[l], col))
+}
+
+// runeOffset returns the rune offset in s of the rune at the byte index i, or the length of s
+// in runes if i is beyond it.
+func runeOffset(s string, i int) int {
+	if i > len(s) {
+		i = len(s)
+	}
+	for i > 0 && i < len(s) && !utf8.RuneStart(s[i]) {
+		i--
+	}
+	return utf8.RuneCountInString(s[:i])
+}
+
+// gotoOffset moves the cursor to the byte offset off in the file and centers it in the window.
+func (e *Editor) gotoOffset(off int64) error {
+	b, s := e.Buffer, e.Screen
+	if !b.HexMode {
+		if b.Large != nil {
+			return errors.New("cannot go to a byte offset in a large file except in the hex mode")
+		}
+		var buf bytes.Buffer
+		if _, err := b.WriteTo(&buf); err != nil {
+			return err
+		}
+		data := buf.Bytes()
+		if off > int64(len(data)) {
+			off = int64(len(data))
+		}
+		line, col := textPosition(b, data, int(off))
+		s.SetPosition(line, col)
+		s.CenterCursor()
+		return nil
+	}
+	if b.Large != nil && (off < b.Offset || off >= b.Offset+int64(len(b.Data))) {
+		if err := e.loadRegion(off); err != nil {
+			return err
+		}
+	}
+	rel := off - b.Offset
+	if rel > int64(len(b.Data)) {
+		rel = int64(len(b.Data))
+	}
+	s.SetPosition(int(rel)/s.HexBytes, int(rel)%s.HexBytes)
+	s.CenterCursor()
+	return nil
+}
+
+// MoveBufferStart moves the cursor to the beginning of the buffer, remembering the position before.
+func (e *Editor) MoveBufferStart() {
+	e.PushJump()
+	if e.Buffer.HexMode {
+		e.Error(e.gotoOffset(0))
+		return
+	}
+	e.Error(e.gotoLine(0, 0))
+}
+
+// MoveBufferEnd moves the cursor to the end of the buffer, remembering the position before.
+func (e *Editor) MoveBufferEnd() {
+	b := e.Buffer
+	if b.Large != nil && !b.HexMode {
+		n, done := b.Large.Lines()
+		if !done {
+			e.Error(errors.New("the lines of the file are still being counted"))
+			return
+		}
+		e.PushJump()
+		e.Error(e.gotoLine(n-1, 0))
+		return
+	}
+	e.PushJump()
+	if b.HexMode {
+		size := int64(len(b.Data))
+		if b.Large != nil {
+			size = b.Large.Size
+		}
+		e.Error(e.gotoOffset(size))
+		return
+	}
+	e.Error(e.gotoLine(len(b.Lines)-1, b.LineLen(len(b.Lines)-1)))
+}
+
+// Jump is a position remembered in the jump list. Line counts from the beginning of the file in a
+// large file. In the hex mode, Line is 0 and Col is the byte offset.
+type Jump struct {
+	Buffer *Buffer
+	Line   int
+	Col    int
+}
+
+// maxJumps is the number of positions the jump list keeps.
+const maxJumps = 100
+
+// JumpList is the history of the positions before jumps, to go back and forth between them like
+// the history of a browser.
+type JumpList struct {
+	jumps []Jump
+	// index is where the list is browsed, len(jumps) when it is not.
+	index int
+}
+
+// Push remembers j, dropping the positions gone back from.
+func (l *JumpList) Push(j Jump) {
+	l.jumps = l.jumps[:l.index]
+	if n := len(l.jumps); n == 0 || l.jumps[n-1] != j {
+		l.jumps = append(l.jumps, j)
+	}
+	if len(l.jumps) > maxJumps {
+		l.jumps = l.jumps[len(l.jumps)-maxJumps:]
+	}
+	l.index = len(l.jumps)
+}
+
+// Back returns the position before the current one cur, which is remembered to go forward to.
+func (l *JumpList) Back(cur Jump) (Jump, bool) {
+	i := l.index - 1
+	for i >= 0 && l.jumps[i] == cur {
+		i--
+	}
+	if i < 0 {
+		return Jump{}, false
+	}
+	if l.index == len(l.jumps) {
+		l.jumps = append(l.jumps, cur)
+	} else {
+		l.jumps[l.index] = cur
+	}
+	l.index = i
+	return l.jumps[i], true
+}
+
+// Forward returns the position the current one cur was gone back to from.
+func (l *JumpList) Forward(cur Jump) (Jump, bool) {
+	if l.index+1 >= len(l.jumps) {
+		return Jump{}, false
+	}
+	l.jumps[l.index] = cur
+	l.index++
+	return l.jumps[l.index], true
+}
+
+// Adjust moves the positions in b after the change c.
+func (l *JumpList) Adjust(b *Buffer, c Change) {
+	for i, j := range l.jumps {
+		if j.Buffer == b {
+			l.jumps[i].Line, l.jumps[i].Col = c.Adjust(j.Line, j.Col)
+		}
+	}
+}
+
+// Remove forgets the positions in b.
+func (l *JumpList) Remove(b *Buffer) {
+	jumps := l.jumps[:0]
+	index := l.index
+	for i, j := range l.jumps {
+		if j.Buffer != b {
+			jumps = append(jumps, j)
+		} else if i < l.index {
+			index--
+		}
+	}
+	l.jumps, l.index = jumps, index
+}
+
+// currentJump returns the position of the cursor as a Jump.
+func (e *Editor) currentJump() Jump {
+	if e.Buffer.HexMode {
+		return Jump{e.Buffer, 0, int(e.filePosition(e.Window))}
+	}
+	line, col := e.Screen.Position()
+	return Jump{e.Buffer, e.Buffer.FirstLine + line, col}
+}
+
+// PushJump remembers the cursor position before a jump.
+func (e *Editor) PushJump() {
+	e.Jumps.Push(e.currentJump())
+}
+
+// JumpBack moves the cursor to the position before the last jump.
+func (e *Editor) JumpBack() {
+	j, ok := e.Jumps.Back(e.currentJump())
+	if !ok {
+		e.Infof("No older position")
+		return
+	}
+	e.Error(e.jumpTo(j))
+}
+
+// JumpForward moves the cursor to the position JumpBack went back from.
+func (e *Editor) JumpForward() {
+	j, ok := e.Jumps.Forward(e.currentJump())
+	if !ok {
+		e.Infof("No newer position")
+		return
+	}
+	e.Error(e.jumpTo(j))
+}
+
+func (e *Editor) jumpTo(j Jump) error {
+	if j.Buffer != e.Buffer {
+		e.SwitchBuffer(j.Buffer)
+	}
+	if e.Buffer.HexMode {
+		return e.gotoOffset(int64(j.Col))
+	}
+	return e.gotoLine(j.Line, j.Col)
+}
@@ -0,0 +1,230 @@
+package editor_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/mizuochikeita/re/editor"
+)
+
+func TestGoto(t *testing.T) {
+	t.Run("ParseGotoTarget()", func(t *testing.T) {
+		tests := []struct {
+			s       string
+			want    editor.GotoTarget
+			wantErr bool
+		}{
+			{s: "42", want: editor.GotoTarget{Line: 42, Offset: -1}},
+			{s: " 42:7 ", want: editor.GotoTarget{Line: 42, Col: 7, Offset: -1}},
+			{s: "#123", want: editor.GotoTarget{Offset: 123}},
+			{s: "0x7B", want: editor.GotoTarget{Offset: 123}},
+			{s: "main.go:12:5: undefined: x", want: editor.GotoTarget{Path: "main.go", Line: 12, Col: 5, Offset: -1}},
+			{s: "main.go:12: syntax error", want: editor.GotoTarget{Path: "main.go", Line: 12, Offset: -1}},
+			{s: "main.go", want: editor.GotoTarget{Path: "main.go", Offset: -1}},
+			{s: "", want: editor.GotoTarget{Offset: -1}, wantErr: true},
+			{s: "0", want: editor.GotoTarget{Offset: -1}, wantErr: true},
+			{s: "#x", want: editor.GotoTarget{Offset: -1}, wantErr: true},
+		}
+		for _, tt := range tests {
+			got, err := editor.ParseGotoTarget(tt.s)
+			if diff := cmp.Diff(tt.wantErr, err != nil); diff != "" {
+				t.Errorf("%q: %s", tt.s, diff)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("%q: %s", tt.s, diff)
+			}
+		}
+	})
+
+	t.Run("Goto()", func(t *testing.T) {
+		var sb strings.Builder
+		for i := 0; i < 100; i++ {
+			fmt.Fprintf(&sb, "あ%d\r\n", i)
+		}
+		tests := []struct {
+			desc        string
+			s           string
+			hex         bool
+			wantLine    int
+			wantCol     int
+			wantVscroll int
+		}{
+			{desc: "line", s: "50", wantLine: 49, wantCol: 0, wantVscroll: 49 - 5},
+			{desc: "line and column", s: "50:3", wantLine: 49, wantCol: 2, wantVscroll: 49 - 5},
+			{desc: "column beyond the line", s: "3:10", wantLine: 2, wantCol: 2, wantVscroll: 0},
+			{desc: "last line", s: "1000", wantLine: 99, wantCol: 0, wantVscroll: 100 - 10},
+			{desc: "byte offset", s: "#9", wantLine: 1, wantCol: 1, wantVscroll: 0},
+			{desc: "byte offset in the hex mode", s: "0x40", hex: true, wantLine: 4, wantCol: 0, wantVscroll: 0},
+		}
+		for _, tt := range tests {
+			e := editor.New()
+			if err := e.OpenReader("text", strings.NewReader(sb.String())); err != nil {
+				t.Fatal(err)
+			}
+			e.Layout.Arrange(0, 0, 80, 11)
+			if tt.hex {
+				if err := e.ToggleHexMode(); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := e.Goto(tt.s); err != nil {
+				t.Fatalf("%s: %v", tt.desc, err)
+			}
+			line, col := e.Screen.Position()
+			if diff := cmp.Diff([3]int{tt.wantLine, tt.wantCol, tt.wantVscroll}, [3]int{line, col, e.Screen.Vscroll}); diff != "" {
+				t.Errorf("%s: %s", tt.desc, diff)
+			}
+		}
+	})
+
+	t.Run("Goto() with a file name", func(t *testing.T) {
+		path := writeTempFile(t, "a.txt", "x\nあいう := 1\n")
+		tests := []struct {
+			desc     string
+			s        string
+			wantLine int
+			wantCol  int
+		}{
+			{desc: "column in bytes", s: path + ":2:7", wantLine: 1, wantCol: 2},
+			{desc: "column in the middle of a rune", s: path + ":2:5", wantLine: 1, wantCol: 1},
+			{desc: "column after the runes", s: path + ":2:11", wantLine: 1, wantCol: 4},
+			{desc: "column beyond the line", s: path + ":2:100", wantLine: 1, wantCol: 8},
+		}
+		for _, tt := range tests {
+			e := editor.New()
+			e.AddBuffer(editor.NewBuffer("*scratch*"))
+			e.Layout.Arrange(0, 0, 80, 24)
+			if err := e.Goto(tt.s); err != nil {
+				t.Fatalf("%s: %v", tt.desc, err)
+			}
+			line, col := e.Screen.Position()
+			if diff := cmp.Diff([2]int{tt.wantLine, tt.wantCol}, [2]int{line, col}); diff != "" {
+				t.Errorf("%s: %s", tt.desc, diff)
+			}
+		}
+	})
+
+	t.Run("Goto() a file which cannot be opened", func(t *testing.T) {
+		e := editor.New()
+		if err := e.OpenReader("text", strings.NewReader(strings.Repeat("line\n", 10))); err != nil {
+			t.Fatal(err)
+		}
+		e.Layout.Arrange(0, 0, 80, 24)
+		e.Screen.SetPosition(5, 0)
+		if err := e.Goto(t.TempDir() + ":2"); err == nil {
+			t.Error("no error")
+		}
+		e.Screen.SetPosition(7, 0)
+		e.JumpBack()
+		if diff := cmp.Diff("No older position", e.Message.Text); diff != "" {
+			t.Error(diff)
+		}
+		line, _ := e.Screen.Position()
+		if diff := cmp.Diff(7, line); diff != "" {
+			t.Error(diff)
+		}
+	})
+
+	t.Run("Goto() in a large file", func(t *testing.T) {
+		var sb strings.Builder
+		for i := 0; i < 20000; i++ {
+			fmt.Fprintf(&sb, "line %d\n", i)
+		}
+		e := editor.New()
+		c, err := editor.ParseConfig(strings.NewReader("[file]\nlarge_file_size = 1K\n"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		e.Config = c
+		if err := e.OpenFile(writeTempFile(t, "large.txt", sb.String())); err != nil {
+			t.Fatal(err)
+		}
+		e.Layout.Arrange(0, 0, 80, 24)
+		<-e.Buffer.Large.Done()
+		if err := e.Goto("15000:2"); err != nil {
+			t.Fatal(err)
+		}
+		line, col := e.Screen.Position()
+		if diff := cmp.Diff("line 14999", e.Buffer.Lines[line]); diff != "" {
+			t.Error(diff)
+		}
+		if diff := cmp.Diff(1, col); diff != "" {
+			t.Error(diff)
+		}
+		e.MoveBufferEnd()
+		line, _ = e.Screen.Position()
+		if diff := cmp.Diff("line 19999", e.Buffer.Lines[line]); diff != "" {
+			t.Error(diff)
+		}
+		e.JumpBack()
+		line, _ = e.Screen.Position()
+		if diff := cmp.Diff("line 14999", e.Buffer.Lines[line]); diff != "" {
+			t.Error(diff)
+		}
+	})
+
+	t.Run("JumpBack() and JumpForward()", func(t *testing.T) {
+		e := editor.New()
+		if err := e.OpenReader("text", strings.NewReader(strings.Repeat("line\n", 100))); err != nil {
+			t.Fatal(err)
+		}
+		e.Layout.Arrange(0, 0, 80, 24)
+		position := func() [2]int {
+			line, col := e.Screen.Position()
+			return [2]int{line, col}
+		}
+		steps := []struct {
+			desc string
+			run  func()
+			want [2]int
+		}{
+			{desc: "goto", run: func() { e.Goto("10:2") }, want: [2]int{9, 1}},
+			{desc: "goto again", run: func() { e.Goto("50") }, want: [2]int{49, 0}},
+			{desc: "end", run: e.MoveBufferEnd, want: [2]int{99, 4}},
+			{desc: "back", run: e.JumpBack, want: [2]int{49, 0}},
+			{desc: "back again", run: e.JumpBack, want: [2]int{9, 1}},
+			{desc: "back to the start", run: e.JumpBack, want: [2]int{0, 0}},
+			{desc: "no older position", run: e.JumpBack, want: [2]int{0, 0}},
+			{desc: "forward", run: e.JumpForward, want: [2]int{9, 1}},
+			{desc: "edit above", run: func() { e.Screen.SetPosition(0, 0); e.InsertText("new\n") }, want: [2]int{1, 0}},
+			{desc: "forward after the edit", run: e.JumpForward, want: [2]int{50, 0}},
+			{desc: "goto drops newer positions", run: func() { e.Goto("5") }, want: [2]int{4, 0}},
+			{desc: "no newer position", run: e.JumpForward, want: [2]int{4, 0}},
+			{desc: "back after goto", run: e.JumpBack, want: [2]int{50, 0}},
+		}
+		for _, s := range steps {
+			s.run()
+			if diff := cmp.Diff(s.want, position()); diff != "" {
+				t.Errorf("%s: %s", s.desc, diff)
+			}
+		}
+	})
+
+	t.Run("jumps to other buffers", func(t *testing.T) {
+		e := editor.New()
+		if err := e.OpenFile(writeTempFile(t, "a.txt", "a\nb\nc\n")); err != nil {
+			t.Fatal(err)
+		}
+		e.Layout.Arrange(0, 0, 80, 24)
+		a := e.Buffer
+		e.Screen.SetPosition(2, 0)
+		if err := e.Goto(writeTempFile(t, "b.txt", "x\ny\n") + ":2"); err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff("b.txt", e.Buffer.Name); diff != "" {
+			t.Error(diff)
+		}
+		e.JumpBack()
+		line, _ := e.Screen.Position()
+		if diff := cmp.Diff([2]interface{}{true, 2}, [2]interface{}{e.Buffer == a, line}); diff != "" {
+			t.Error(diff)
+		}
+		e.CloseBuffer(e.FindBuffer("b.txt"))
+		e.JumpForward()
+		if diff := cmp.Diff("a.txt", e.Buffer.Name); diff != "" {
+			t.Error(diff)
+		}
+	})
+}
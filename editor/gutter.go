@@ -0,0 +1,81 @@
+package editor
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// LineNumbers is how the gutter left of the text shows line numbers.
+type LineNumbers int
+
+const (
+	LineNumbersOff LineNumbers = iota
+	LineNumbersAbsolute
+	// LineNumbersRelative shows the distances to the cursor line, and the number of the cursor line.
+	LineNumbersRelative
+)
+
+var lineNumbersNames = []string{"off", "absolute", "relative"}
+
+func (n LineNumbers) String() string {
+	return lineNumbersNames[n]
+}
+
+// ParseLineNumbers returns the LineNumbers named s.
+func ParseLineNumbers(s string) (LineNumbers, error) {
+	for i, name := range lineNumbersNames {
+		if s == name {
+			return LineNumbers(i), nil
+		}
+	}
+	return LineNumbersOff, fmt.Errorf("invalid line numbers: %s", s)
+}
+
+// minGutterDigits is the least number of digits the gutter has room for.
+const minGutterDigits = 3
+
+// gutterWidth returns the width of the gutter of b: the digits of its last line number and a space.
+func gutterWidth(b *Buffer) int {
+	if b.LineNumbers == LineNumbersOff || b.HexMode {
+		return 0
+	}
+	n := len(strconv.Itoa(b.FirstLine + len(b.Lines)))
+	if n < minGutterDigits {
+		n = minGutterDigits
+	}
+	return n + 1
+}
+
+// gutterText returns the gutter of the row r of w: the number of its line on the first row
+// of the line, in faint unless it is the cursor line, and blanks on the rows continuing it.
+func gutterText(w *Window, r int) string {
+	s := w.Screen
+	if s.Gutter == 0 {
+		return ""
+	}
+	line, first := s.RowLine(r)
+	if !first {
+		return strings.Repeat(" ", s.Gutter)
+	}
+	cursorLine, _ := s.RowLine(s.Cy)
+	n := w.Buffer.FirstLine + line + 1
+	if w.Buffer.LineNumbers == LineNumbersRelative && line != cursorLine {
+		n = line - cursorLine
+		if n < 0 {
+			n = -n
+		}
+	}
+	text := fmt.Sprintf("%*d ", s.Gutter-1, n)
+	if line == cursorLine {
+		return text
+	}
+	return "\x1b[2m" + text + "\x1b[0m" // faint
+}
+
+// SetLineNumbers sets how the gutter of the current buffer shows line numbers.
+func (e *Editor) SetLineNumbers(n LineNumbers) {
+	e.Buffer.LineNumbers = n
+	e.BufferReloaded(e.Buffer)
+	e.Infof("Line numbers: %s", n)
+}
@@ -0,0 +1,116 @@
+package editor_test
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/mizuochikeita/re/editor"
+)
+
+func TestGutter(t *testing.T) {
+	t.Run("ParseLineNumbers()", func(t *testing.T) {
+		tests := []struct {
+			in      string
+			want    editor.LineNumbers
+			wantErr bool
+		}{
+			{in: "off", want: editor.LineNumbersOff},
+			{in: "absolute", want: editor.LineNumbersAbsolute},
+			{in: "relative", want: editor.LineNumbersRelative},
+			{in: "on", wantErr: true},
+		}
+		for _, tt := range tests {
+			got, err := editor.ParseLineNumbers(tt.in)
+			if diff := cmp.Diff(tt.wantErr, err != nil); diff != "" {
+				t.Errorf("%s: %s", tt.in, diff)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("%s: %s", tt.in, diff)
+			}
+		}
+	})
+
+	t.Run("SetLineNumbers()", func(t *testing.T) {
+		e := editor.New()
+		text := strings.Repeat("line\n", 998) + strings.Repeat("x", 20)
+		if err := e.OpenReader("lines", strings.NewReader(text)); err != nil {
+			t.Fatal(err)
+		}
+		e.Layout.Arrange(0, 0, 20, 10)
+		e.SetLineNumbers(editor.LineNumbersAbsolute)
+		e.Screen.SetPosition(998, 3)
+		if diff := cmp.Diff(4, e.Screen.Gutter); diff != "" {
+			t.Error(diff)
+		}
+		// The last line is wrapped in the width left of the gutter.
+		if diff := cmp.Diff(16, e.Screen.Rows[998].Len); diff != "" {
+			t.Error(diff)
+		}
+		x, _ := e.Screen.CursorPosition()
+		if diff := cmp.Diff(4+3, x); diff != "" {
+			t.Error(diff)
+		}
+
+		e.InsertText("\n")
+		if diff := cmp.Diff(5, e.Screen.Gutter); diff != "" {
+			t.Error(diff)
+		}
+		x, _ = e.Screen.CursorPosition()
+		if diff := cmp.Diff(5, x); diff != "" {
+			t.Error(diff)
+		}
+	})
+
+	t.Run("DrawWindow()", func(t *testing.T) {
+		tests := []struct {
+			desc string
+			n    editor.LineNumbers
+			want []string
+		}{
+			{
+				desc: "absolute",
+				n:    editor.LineNumbersAbsolute,
+				want: []string{"\x1b[2m  1 \x1b[0mfirst", "  2 second", "\x1b[2m  3 \x1b[0mthird line", "    wrapped"},
+			},
+			{
+				desc: "relative",
+				n:    editor.LineNumbersRelative,
+				want: []string{"\x1b[2m  1 \x1b[0mfirst", "  2 second", "\x1b[2m  1 \x1b[0mthird line", "    wrapped"},
+			},
+		}
+		for _, tt := range tests {
+			e := editor.New()
+			if err := e.OpenReader("lines", strings.NewReader("first\nsecond\nthird line wrapped\n")); err != nil {
+				t.Fatal(err)
+			}
+			e.Layout.Arrange(0, 0, 15, 6)
+			e.SetLineNumbers(tt.n)
+			e.Screen.SetPosition(1, 0)
+			out, err := os.Create(filepath.Join(t.TempDir(), "out"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			e.Out = out
+			e.DrawWindow(e.Window)
+			out.Close()
+			data, err := os.ReadFile(out.Name())
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			// Each row starts with moving the cursor to it, after the status line.
+			for _, row := range regexp.MustCompile(`\x1b\[\d+;\d+H`).Split(string(data), -1)[2:] {
+				if row = strings.TrimRight(row, " "); row != "~" {
+					got = append(got, row)
+				}
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("%s: %s", tt.desc, diff)
+			}
+		}
+	})
+}
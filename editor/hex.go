@@ -0,0 +1,128 @@
+package editor
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+var errNotText = errors.New("buffer is not a text file")
+
+// ToggleHexMode switches the current buffer between its text and its bytes shown as a hex dump,
+// keeping the cursor on the same byte.
+func (e *Editor) ToggleHexMode() error {
+	b := e.Buffer
+	if b.Large != nil {
+		return errors.New("cannot switch the mode of a large file")
+	}
+	if b.HexMode {
+		line, col := textPosition(b, b.Data, e.hexOffset())
+		if err := b.LeaveHexMode(); err != nil {
+			return err
+		}
+		e.BufferReloaded(b)
+		e.Screen.SetPosition(line, col)
+		e.Infof("Hex mode: off")
+		return nil
+	}
+	off, err := byteOffset(b, e.Screen)
+	if err != nil {
+		return err
+	}
+	if err := b.EnterHexMode(); err != nil {
+		return err
+	}
+	e.BufferReloaded(b)
+	e.Screen.SetPosition(off/e.Screen.HexBytes, off%e.Screen.HexBytes)
+	e.Infof("Hex mode: on")
+	return nil
+}
+
+// byteOffset returns the offset of the cursor of s in the bytes of b as they are saved.
+func byteOffset(b *Buffer, s *Screen) (int, error) {
+	line, col := s.Position()
+	eol := b.Terminator()
+	var sb strings.Builder
+	for _, l := range b.Lines[:line] {
+		sb.WriteString(l)
+		sb.WriteString(eol)
+	}
+	sb.WriteString(b.Lines[line][:runeIndex(b.Lines[line], col)])
+	data, err := Encode(b.Encoding, sb.String())
+	if err != nil {
+		return 0, err
+	}
+	if b.BOM {
+		return len(encodingBOM(b.Encoding)) + len(data), nil
+	}
+	return len(data), nil
+}
+
+// textPosition returns the line and the rune offset in it of the byte offset off in data,
+// the bytes of b as they are saved.
+func textPosition(b *Buffer, data []byte, off int) (int, int) {
+	data = data[:off]
+	data = bytes.TrimPrefix(data, []byte(encodingBOM(b.Encoding)))
+	s, _ := Decode(b.Encoding, data)
+	line := strings.Count(s, "\n")
+	return line, utf8.RuneCountInString(s[strings.LastIndex(s, "\n")+1:])
+}
+
+// hexOffset returns the offset of the cursor in Data in the hex mode.
+func (e *Editor) hexOffset() int {
+	line, col := e.Screen.Position()
+	return line*e.Screen.HexBytes + col
+}
+
+// InsertHexDigits overwrites the bytes at the cursor with the hex digits s, a digit at a time
+// from the high one. Digits at the end of the data append bytes.
+func (e *Editor) InsertHexDigits(s string) error {
+	b, sc := e.Buffer, e.Screen
+	for _, r := range s {
+		v, ok := hexDigit(r)
+		if !ok {
+			return fmt.Errorf("not a hex digit: %q", r)
+		}
+		off, nibble := e.hexOffset(), sc.Nibble
+		var old byte
+		if off < len(b.Data) {
+			old = b.Data[off]
+		}
+		if nibble == 0 {
+			v = v<<4 | old&0x0f
+		} else {
+			v = old&0xf0 | v
+		}
+		e.BufferChanged(b, b.SetByte(off, v))
+		if nibble == 0 {
+			sc.Nibble = 1
+		} else {
+			sc.MoveCursorHorizontally(1)
+			sc.ScrollToCursor()
+		}
+	}
+	return nil
+}
+
+// deleteByteBackward deletes the byte before the cursor in the hex mode.
+func (e *Editor) deleteByteBackward() {
+	off := e.hexOffset()
+	if off == 0 {
+		return
+	}
+	e.BufferChanged(e.Buffer, e.Buffer.DeleteBytes(off-1, off))
+}
+
+func hexDigit(r rune) (byte, bool) {
+	switch {
+	case '0' <= r && r <= '9':
+		return byte(r - '0'), true
+	case 'a' <= r && r <= 'f':
+		return byte(r - 'a' + 10), true
+	case 'A' <= r && r <= 'F':
+		return byte(r - 'A' + 10), true
+	}
+	return 0, false
+}
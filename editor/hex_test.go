@@ -0,0 +1,137 @@
+package editor_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/mizuochikeita/re/editor"
+)
+
+func TestHex(t *testing.T) {
+	t.Run("InsertHexDigits()", func(t *testing.T) {
+		tests := []struct {
+			desc       string
+			data       string
+			off        int
+			digits     string
+			want       string
+			wantOff    int
+			wantErr    bool
+			wantNibble int
+		}{
+			{desc: "overwrite a byte", data: "abc", off: 1, digits: "7a", want: "azc", wantOff: 2},
+			{desc: "high digit", data: "abc", off: 0, digits: "3", want: "1bc", wantOff: 0, wantNibble: 1},
+			{desc: "overwrite bytes across rows", data: "0123456789abcdefg", off: 15, digits: "4142", want: "0123456789abcdeAB", wantOff: 17},
+			{desc: "append bytes", data: "ab", off: 2, digits: "0A0d", want: "ab\n\r", wantOff: 4},
+			{desc: "not a digit", data: "ab", off: 0, digits: "x", want: "ab", wantErr: true},
+		}
+		for _, tt := range tests {
+			e := editor.New()
+			if err := e.OpenReader("bin", strings.NewReader(tt.data)); err != nil {
+				t.Fatal(err)
+			}
+			e.Layout.Arrange(0, 0, 80, 24)
+			if err := e.ToggleHexMode(); err != nil {
+				t.Fatal(err)
+			}
+			e.Screen.SetPosition(tt.off/16, tt.off%16)
+			err := e.InsertHexDigits(tt.digits)
+			if diff := cmp.Diff(tt.wantErr, err != nil); diff != "" {
+				t.Errorf("%s: %s", tt.desc, diff)
+			}
+			if diff := cmp.Diff(tt.want, string(e.Buffer.Data)); diff != "" {
+				t.Errorf("%s: %s", tt.desc, diff)
+			}
+			line, col := e.Screen.Position()
+			if diff := cmp.Diff([2]int{tt.wantOff, tt.wantNibble}, [2]int{line*16 + col, e.Screen.Nibble}); diff != "" {
+				t.Errorf("%s: %s", tt.desc, diff)
+			}
+			want := &editor.Screen{Width: 80}
+			want.UpdateHex(e.Buffer.Data, 0)
+			if diff := cmp.Diff(want.Rows, e.Screen.Rows); diff != "" {
+				t.Errorf("%s: %s", tt.desc, diff)
+			}
+		}
+	})
+
+	t.Run("ToggleHexMode()", func(t *testing.T) {
+		e := editor.New()
+		if err := e.OpenReader("text", strings.NewReader("\ufeffab\r\nあい\r\n")); err != nil {
+			t.Fatal(err)
+		}
+		e.Layout.Arrange(0, 0, 80, 24)
+		e.Screen.SetPosition(1, 1)
+		if err := e.ToggleHexMode(); err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff("\ufeffab\r\nあい\r\n", string(e.Buffer.Data)); diff != "" {
+			t.Error(diff)
+		}
+		_, col := e.Screen.Position()
+		if diff := cmp.Diff(3+4+3, col); diff != "" {
+			t.Error(diff)
+		}
+
+		e.Screen.SetPosition(0, 3)
+		if err := e.InsertHexDigits("41"); err != nil {
+			t.Fatal(err)
+		}
+		if err := e.ToggleHexMode(); err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff([]string{"Ab", "あい"}, e.Buffer.Lines); diff != "" {
+			t.Error(diff)
+		}
+		line, col := e.Screen.Position()
+		if diff := cmp.Diff([2]int{0, 1}, [2]int{line, col}); diff != "" {
+			t.Error(diff)
+		}
+		if diff := cmp.Diff(true, e.Buffer.Dirty); diff != "" {
+			t.Error(diff)
+		}
+		var buf bytes.Buffer
+		if _, err := e.Buffer.WriteTo(&buf); err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff("\ufeffAb\r\nあい\r\n", buf.String()); diff != "" {
+			t.Error(diff)
+		}
+	})
+
+	t.Run("DrawWindow()", func(t *testing.T) {
+		e := editor.New()
+		if err := e.OpenReader("data", strings.NewReader("hello\x00world, binary data\n")); err != nil {
+			t.Fatal(err)
+		}
+		e.Layout.Arrange(0, 0, 80, 5)
+		out, err := os.Create(filepath.Join(t.TempDir(), "out"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		e.Out = out
+		e.DrawWindow(e.Window)
+		out.Close()
+		data, err := os.ReadFile(out.Name())
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, row := range regexp.MustCompile(`\x1b\[\d+;\d+H`).Split(string(data), -1)[2:] {
+			got = append(got, strings.TrimRight(row, " "))
+		}
+		want := []string{
+			"00000000  68 65 6c 6c 6f 00 77 6f 72 6c 64 2c 20 62 69 6e  hello.world, bin",
+			"00000010  61 72 79 20 64 61 74 61 0a                       ary data.",
+			"~",
+			"~",
+		}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Error(diff)
+		}
+	})
+}
@@ -0,0 +1,391 @@
+package editor
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// IndentOptions is how the lines of a buffer are indented.
+type IndentOptions struct {
+	// Tabs indents with tab characters instead of spaces.
+	Tabs bool
+	// Width is the number of columns of an indentation level.
+	Width int
+	// TabWidth is the number of columns between tab stops, or 0 for Width.
+	TabWidth int
+	// Electric indents the lines after an opening brace, and dedents closing braces typed at
+	// the beginning of a line.
+	Electric bool
+}
+
+func (o IndentOptions) width() int {
+	if o.Width <= 0 {
+		return defaultIndentWidth
+	}
+	return o.Width
+}
+
+func (o IndentOptions) tabWidth() int {
+	if o.TabWidth <= 0 {
+		return o.width()
+	}
+	return o.TabWidth
+}
+
+// text returns the indentation up to the column col: tabs up to the last tab stop and spaces
+// after it with Tabs, or only spaces.
+func (o IndentOptions) text(col int) string {
+	if !o.Tabs {
+		return strings.Repeat(" ", col)
+	}
+	return strings.Repeat("\t", col/o.tabWidth()) + strings.Repeat(" ", col%o.tabWidth())
+}
+
+func (o IndentOptions) String() string {
+	if o.Tabs {
+		return "tabs"
+	}
+	return fmt.Sprintf("%d spaces", o.width())
+}
+
+const (
+	defaultIndentWidth = 8
+	// maxDetectLines is the number of lines DetectIndent looks at.
+	maxDetectLines = 10000
+)
+
+// braceFileTypes are the file types whose blocks are delimited by braces, where closing braces
+// are electric by default.
+var braceFileTypes = map[string]bool{
+	"c": true, "h": true, "cc": true, "cpp": true, "hpp": true, "cs": true, "css": true, "go": true,
+	"java": true, "js": true, "jsx": true, "json": true, "kt": true, "php": true, "rs": true,
+	"scala": true, "scss": true, "swift": true, "ts": true, "tsx": true, "zig": true,
+}
+
+// FileType returns the type of the file at path used to select the options for it: its
+// extension without the dot, or its name if it has none, in lowercase.
+func FileType(path string) string {
+	name := filepath.Base(path)
+	if ext := filepath.Ext(name); ext != "" && ext != name {
+		name = ext[1:]
+	}
+	return strings.ToLower(name)
+}
+
+// DetectIndent guesses from its lines whether a text is indented with tabs or spaces, and how
+// many spaces make a level: the most frequent increase of the indentation between lines.
+// It returns false if the lines have no indentation to tell.
+func DetectIndent(lines []string) (IndentOptions, bool) {
+	if len(lines) > maxDetectLines {
+		lines = lines[:maxDetectLines]
+	}
+	tabs, spaces := 0, 0
+	increases := map[int]int{}
+	prev := 0
+	for _, l := range lines {
+		rest := strings.TrimLeft(l, " ")
+		if strings.TrimSpace(rest) == "" {
+			continue
+		}
+		n := len(l) - len(rest)
+		switch {
+		case strings.HasPrefix(rest, "\t"):
+			if n == 0 {
+				tabs++
+			}
+			continue
+		case strings.HasPrefix(rest, "*"):
+			// The continuation of a block comment is aligned with its beginning.
+			continue
+		}
+		if n > 1 {
+			spaces++
+		}
+		if d := n - prev; d >= 2 && d <= 8 {
+			increases[d]++
+		}
+		prev = n
+	}
+	if tabs == 0 && spaces == 0 {
+		return IndentOptions{}, false
+	}
+	if tabs >= spaces {
+		return IndentOptions{Tabs: true}, true
+	}
+	width, max := 0, 0
+	for d := 2; d <= 8; d++ {
+		if increases[d] > max {
+			width, max = d, increases[d]
+		}
+	}
+	if width == 0 {
+		return IndentOptions{}, false
+	}
+	return IndentOptions{Width: width}, true
+}
+
+// indentConfig returns the value of key in the [indent.<file type>] section of the config, or
+// in the [indent] section.
+func (e *Editor) indentConfig(fileType, key string) (string, bool) {
+	if v, ok := e.Config.Get("indent."+fileType, key); ok {
+		return v, true
+	}
+	return e.Config.Get("indent", key)
+}
+
+// indentOptions returns the indentation of b from the config for its file type, and from its
+// text unless detection is disabled.
+func (e *Editor) indentOptions(b *Buffer) (IndentOptions, error) {
+	ft := FileType(b.Name)
+	if b.Path != "" {
+		ft = FileType(b.Path)
+	}
+	o := IndentOptions{Tabs: true, Width: defaultIndentWidth, Electric: braceFileTypes[ft]}
+	if v, ok := e.indentConfig(ft, "style"); ok {
+		switch v {
+		case "tab":
+			o.Tabs = true
+		case "space":
+			o.Tabs = false
+		default:
+			return o, fmt.Errorf("invalid indent style: %s", v)
+		}
+	}
+	if v, ok := e.indentConfig(ft, "width"); ok {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			return o, fmt.Errorf("invalid indent width: %s", v)
+		}
+		o.Width = n
+	}
+	for _, key := range []string{"electric", "detect"} {
+		v, ok := e.indentConfig(ft, key)
+		if !ok {
+			continue
+		}
+		enabled, err := strconv.ParseBool(v)
+		if err != nil {
+			return o, fmt.Errorf("invalid value of %s in [indent]: %s", key, v)
+		}
+		if key == "electric" {
+			o.Electric = enabled
+		} else if !enabled {
+			return o, nil
+		}
+	}
+	if d, ok := DetectIndent(b.Lines); ok {
+		o.Tabs = d.Tabs
+		if !d.Tabs {
+			o.Width = d.Width
+		}
+	}
+	return o, nil
+}
+
+// leadingSpace returns the tabs and spaces at the beginning of s.
+func leadingSpace(s string) string {
+	return s[:len(s)-len(strings.TrimLeft(s, " \t"))]
+}
+
+// indentColumn returns the column after s, the beginning of a line, with tabs advancing to the
+// next tab stop.
+func indentColumn(s string, tabWidth int) int {
+	n := 0
+	for _, r := range s {
+		if r == '\t' {
+			n += tabWidth - n%tabWidth
+		} else {
+			n++
+		}
+	}
+	return n
+}
+
+var closingBraces = map[rune]rune{'}': '{', ')': '(', ']': '['}
+
+// Newline breaks the line at the cursor and indents the new line like the line broken. With
+// Electric, the line after an opening brace is indented one more level, and a closing brace
+// right after the cursor moves to a line of its own. Spaces around the break are removed.
+func (e *Editor) Newline() {
+	b := e.Buffer
+	if b.ReadOnly || b.HexMode {
+		e.InsertText("\n")
+		return
+	}
+	line, col := e.Screen.Position()
+	rs := []rune(b.Lines[line])
+	before, after := string(rs[:col]), string(rs[col:])
+	indent := leadingSpace(before)
+	trimmed := strings.TrimRight(before, " \t")
+	if trimmed == "" {
+		// Breaking the indentation moves the line down as it is.
+		e.Screen.SetPosition(line, 0)
+		e.InsertText("\n")
+		e.Screen.SetPosition(line+1, col)
+		return
+	}
+	rest := strings.TrimLeft(after, " \t")
+	from := utf8.RuneCountInString(trimmed)
+	if to := len(rs) - utf8.RuneCountInString(rest); from < to {
+		e.DeleteRange(line, from, line, to)
+	}
+	e.Screen.SetPosition(line, from)
+
+	text := "\n" + indent
+	last, _ := utf8.DecodeLastRuneInString(trimmed)
+	opened := b.Indent.Electric && strings.ContainsRune("{([", last)
+	if opened {
+		text = "\n" + b.Indent.text(indentColumn(indent, b.Indent.tabWidth())+b.Indent.width())
+	}
+	e.InsertText(text)
+	if next, _ := utf8.DecodeRuneInString(rest); opened && closingBraces[next] == last {
+		l, c := e.Screen.Position()
+		e.InsertText("\n" + indent)
+		e.Screen.SetPosition(l, c)
+	}
+}
+
+// InsertIndent inserts a tab, or spaces up to the next indentation level in a buffer indented
+// with spaces.
+func (e *Editor) InsertIndent() {
+	b := e.Buffer
+	if b.HexMode || b.Indent.Tabs {
+		e.InsertText("\t")
+		return
+	}
+	line, col := e.Screen.Position()
+	width := b.Indent.width()
+	n := indentColumn(string([]rune(b.Lines[line])[:col]), b.Indent.tabWidth())
+	e.InsertText(strings.Repeat(" ", width-n%width))
+}
+
+// IndentLines indents the lines from line up to endLine by n levels, or dedents them for
+// a negative n. Indentations between levels are rounded to them, and blank lines are left
+// as they are.
+func (e *Editor) IndentLines(line, endLine, n int) {
+	b := e.Buffer
+	if b.ReadOnly {
+		e.Error(ErrReadOnly)
+		return
+	}
+	if b.HexMode {
+		e.Error(errNotText)
+		return
+	}
+	width := b.Indent.width()
+	for l := line; l <= endLine && l < len(b.Lines); l++ {
+		if strings.TrimSpace(b.Lines[l]) == "" {
+			continue
+		}
+		indent := leadingSpace(b.Lines[l])
+		col := indentColumn(indent, b.Indent.tabWidth())
+		level := col/width + n
+		if n < 0 && col%width != 0 {
+			level++
+		}
+		if level < 0 {
+			level = 0
+		}
+		text := b.Indent.text(level * width)
+		// Only the part of the indentation which changes is replaced, for the cursors to stay
+		// on the same text.
+		switch {
+		case text == indent:
+		case strings.HasSuffix(text, indent):
+			e.BufferChanged(b, b.Insert(l, 0, text[:len(text)-len(indent)]))
+		case strings.HasSuffix(indent, text):
+			_, c := b.Delete(l, 0, l, utf8.RuneCountInString(indent[:len(indent)-len(text)]))
+			e.BufferChanged(b, c)
+		default:
+			_, c := b.Delete(l, 0, l, utf8.RuneCountInString(indent))
+			e.BufferChanged(b, c)
+			e.BufferChanged(b, b.Insert(l, 0, text))
+		}
+	}
+}
+
+// IndentLine indents the line of the cursor by a level.
+func (e *Editor) IndentLine() {
+	line, _ := e.Screen.Position()
+	e.IndentLines(line, line, 1)
+}
+
+// DedentLine dedents the line of the cursor by a level.
+func (e *Editor) DedentLine() {
+	line, _ := e.Screen.Position()
+	e.IndentLines(line, line, -1)
+}
+
+// deleteIndentBackward deletes the spaces before the cursor back to the previous indentation
+// level when only spaces precede it on the line, and reports whether it did.
+func (e *Editor) deleteIndentBackward() bool {
+	b := e.Buffer
+	width := b.Indent.width()
+	if b.Indent.Tabs || width <= 1 {
+		return false
+	}
+	line, col := e.Screen.Position()
+	before := string([]rune(b.Lines[line])[:col])
+	if col == 0 || strings.Trim(before, " ") != "" {
+		return false
+	}
+	n := col % width
+	if n == 0 {
+		n = width
+	}
+	e.DeleteRange(line, col-n, line, col)
+	return true
+}
+
+// TypeRune inserts r typed at the cursor. With Electric, a closing brace typed at the
+// beginning of a line is indented like the line of its opening brace.
+func (e *Editor) TypeRune(r rune) {
+	b := e.Buffer
+	open, ok := closingBraces[r]
+	if !ok || !b.Indent.Electric || b.ReadOnly || b.HexMode {
+		e.InsertText(string(r))
+		return
+	}
+	line, col := e.Screen.Position()
+	before := string([]rune(b.Lines[line])[:col])
+	if strings.TrimSpace(before) != "" {
+		e.InsertText(string(r))
+		return
+	}
+	if l, ok := findOpeningBrace(b, line, col, open, r); ok {
+		indent := leadingSpace(b.Lines[l])
+		if indent != before {
+			e.DeleteRange(line, 0, line, col)
+			e.Screen.SetPosition(line, 0)
+			e.InsertText(indent)
+		}
+	}
+	e.InsertText(string(r))
+}
+
+// findOpeningBrace returns the line of the brace open not closed before the rune offset col in
+// line, searching backward.
+func findOpeningBrace(b *Buffer, line, col int, open, close rune) (int, bool) {
+	depth := 0
+	for l := line; l >= 0; l-- {
+		rs := []rune(b.Lines[l])
+		if l == line {
+			rs = rs[:col]
+		}
+		for i := len(rs) - 1; i >= 0; i-- {
+			switch rs[i] {
+			case close:
+				depth++
+			case open:
+				if depth == 0 {
+					return l, true
+				}
+				depth--
+			}
+		}
+	}
+	return 0, false
+}
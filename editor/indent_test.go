@@ -0,0 +1,168 @@
+package editor_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/mizuochikeita/re/editor"
+)
+
+func TestIndent(t *testing.T) {
+	newEditor := func(t *testing.T, name, text, config string, line, col int) *editor.Editor {
+		t.Helper()
+		e := editor.New()
+		c, err := editor.ParseConfig(strings.NewReader(config))
+		if err != nil {
+			t.Fatal(err)
+		}
+		e.Config = c
+		if err := e.OpenReader(name, strings.NewReader(text)); err != nil {
+			t.Fatal(err)
+		}
+		e.Layout.Arrange(0, 0, 80, 24)
+		e.Screen.SetPosition(line, col)
+		return e
+	}
+
+	t.Run("DetectIndent()", func(t *testing.T) {
+		tests := []struct {
+			desc   string
+			text   string
+			want   editor.IndentOptions
+			wantOK bool
+		}{
+			{desc: "tabs", text: "func f() {\n\tif x {\n\t\ty()\n\t}\n}\n", want: editor.IndentOptions{Tabs: true}, wantOK: true},
+			{desc: "4 spaces", text: "def f():\n    if x:\n        y()\n    z()\n", want: editor.IndentOptions{Width: 4}, wantOK: true},
+			{desc: "2 spaces with alignment", text: "a:\n  b:\n    c: [1,\n        2]\n  d: 3\n", want: editor.IndentOptions{Width: 2}, wantOK: true},
+			{desc: "block comment", text: "/*\n * a\n * b\n */\nint x;\n", wantOK: false},
+			{desc: "no indentation", text: "a\nb\n", wantOK: false},
+		}
+		for _, tt := range tests {
+			got, ok := editor.DetectIndent(strings.Split(tt.text, "\n"))
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("%s: %s", tt.desc, diff)
+			}
+			if diff := cmp.Diff(tt.wantOK, ok); diff != "" {
+				t.Errorf("%s: %s", tt.desc, diff)
+			}
+		}
+	})
+
+	t.Run("String()", func(t *testing.T) {
+		tests := []struct {
+			o    editor.IndentOptions
+			want string
+		}{
+			{o: editor.IndentOptions{Tabs: true}, want: "tabs"},
+			{o: editor.IndentOptions{Width: 4}, want: "4 spaces"},
+			{o: editor.IndentOptions{}, want: "8 spaces"},
+		}
+		for _, tt := range tests {
+			if diff := cmp.Diff(tt.want, tt.o.String()); diff != "" {
+				t.Errorf("%+v: %s", tt.o, diff)
+			}
+		}
+	})
+
+	t.Run("indentation options", func(t *testing.T) {
+		tests := []struct {
+			desc   string
+			name   string
+			text   string
+			config string
+			want   editor.IndentOptions
+		}{
+			{desc: "default", name: "a.txt", text: "a\n", want: editor.IndentOptions{Tabs: true, Width: 8}},
+			{desc: "brace language", name: "a.go", text: "a\n", want: editor.IndentOptions{Tabs: true, Width: 8, Electric: true}},
+			{desc: "config", name: "a.txt", text: "a\n", config: "[indent]\nstyle = space\nwidth = 2\n", want: editor.IndentOptions{Width: 2}},
+			{desc: "file type config", name: "a.py", text: "a\n", config: "[indent]\nwidth = 2\n[indent.py]\nstyle = space\nwidth = 4\n",
+				want: editor.IndentOptions{Width: 4}},
+			{desc: "detected", name: "a.py", text: "if a:\n  b\n", config: "[indent.py]\nstyle = space\nwidth = 4\n", want: editor.IndentOptions{Width: 2}},
+			{desc: "detection disabled", name: "a.py", text: "if a:\n  b\n", config: "[indent.py]\nstyle = space\nwidth = 4\ndetect = false\n",
+				want: editor.IndentOptions{Width: 4}},
+			{desc: "electric disabled", name: "a.c", text: "a\n", config: "[indent.c]\nelectric = false\n", want: editor.IndentOptions{Tabs: true, Width: 8}},
+		}
+		for _, tt := range tests {
+			e := newEditor(t, tt.name, tt.text, tt.config, 0, 0)
+			if diff := cmp.Diff(tt.want, e.Buffer.Indent); diff != "" {
+				t.Errorf("%s: %s", tt.desc, diff)
+			}
+		}
+	})
+
+	t.Run("Newline()", func(t *testing.T) {
+		tests := []struct {
+			desc    string
+			name    string
+			text    string
+			line    int
+			col     int
+			want    string
+			wantPos [2]int
+		}{
+			{desc: "keep the indentation", name: "a.txt", text: "\tfoo bar", col: 4, want: "\tfoo\n\tbar", wantPos: [2]int{1, 1}},
+			{desc: "remove spaces around the break", name: "a.txt", text: "  foo  bar", col: 6, want: "  foo\n  bar", wantPos: [2]int{1, 2}},
+			{desc: "break the indentation", name: "a.txt", text: "  foo", col: 1, want: "\n  foo", wantPos: [2]int{1, 1}},
+			{desc: "after an opening brace", name: "a.go", text: "\tif x {", col: 7, want: "\tif x {\n\t\t", wantPos: [2]int{1, 2}},
+			{desc: "between braces", name: "a.go", text: "f(){}", col: 4, want: "f(){\n\t\n}", wantPos: [2]int{1, 1}},
+			{desc: "between braces with spaces", name: "a.go", text: "    x := []int{}", col: 15,
+				want: "    x := []int{\n        \n    }", wantPos: [2]int{1, 8}},
+			{desc: "brace in a text file", name: "a.txt", text: "{}", col: 1, want: "{\n}", wantPos: [2]int{1, 0}},
+		}
+		for _, tt := range tests {
+			e := newEditor(t, tt.name, tt.text, "", tt.line, tt.col)
+			e.Newline()
+			if diff := cmp.Diff(tt.want, strings.Join(e.Buffer.Lines, "\n")); diff != "" {
+				t.Errorf("%s: %s", tt.desc, diff)
+			}
+			line, col := e.Screen.Position()
+			if diff := cmp.Diff(tt.wantPos, [2]int{line, col}); diff != "" {
+				t.Errorf("%s: %s", tt.desc, diff)
+			}
+		}
+	})
+
+	t.Run("editing the indentation", func(t *testing.T) {
+		const spaces = "[indent]\nstyle = space\nwidth = 4\ndetect = false\n"
+		tests := []struct {
+			desc    string
+			name    string
+			config  string
+			text    string
+			col     int
+			edit    func(e *editor.Editor)
+			want    string
+			wantCol int
+		}{
+			{desc: "tab", name: "a.txt", text: "ab", col: 1, edit: (*editor.Editor).InsertIndent, want: "a\tb", wantCol: 2},
+			{desc: "tab with spaces", name: "a.txt", config: spaces, text: "ab", col: 1, edit: (*editor.Editor).InsertIndent, want: "a   b", wantCol: 4},
+			{desc: "tab after a tab", name: "a.txt", config: spaces, text: "\tx", col: 1, edit: (*editor.Editor).InsertIndent, want: "\t    x", wantCol: 5},
+			{desc: "indent to a level", name: "a.txt", config: spaces, text: "  x", col: 2, edit: (*editor.Editor).IndentLine, want: "    x", wantCol: 4},
+			{desc: "dedent", name: "a.txt", config: spaces, text: "        x", col: 9, edit: (*editor.Editor).DedentLine, want: "    x", wantCol: 5},
+			{desc: "dedent to a level", name: "a.txt", config: spaces, text: "      x", col: 7, edit: (*editor.Editor).DedentLine, want: "    x", wantCol: 5},
+			{desc: "dedent tabs", name: "a.txt", text: "\t\tx", col: 2, edit: (*editor.Editor).DedentLine, want: "\tx", wantCol: 1},
+			{desc: "dedent converts the indentation", name: "a.txt", text: "\t        x", col: 0, edit: (*editor.Editor).DedentLine, want: "\tx", wantCol: 0},
+			{desc: "delete a level backward", name: "a.txt", config: spaces, text: "      x", col: 6, edit: (*editor.Editor).DeleteBackward, want: "    x", wantCol: 4},
+			{desc: "delete a space backward after text", name: "a.txt", config: spaces, text: "x    y", col: 5, edit: (*editor.Editor).DeleteBackward, want: "x   y", wantCol: 4},
+			{desc: "electric brace", name: "a.go", text: "\tif x {\n\t\ty()\n\t\t", col: 2,
+				edit: func(e *editor.Editor) { e.TypeRune('}') }, want: "\tif x {\n\t\ty()\n\t}", wantCol: 2},
+			{desc: "electric brace after a nested block", name: "a.go", text: "f() {\n\tg(func() {\n\t})\n\t", col: 1,
+				edit: func(e *editor.Editor) { e.TypeRune('}') }, want: "f() {\n\tg(func() {\n\t})\n}", wantCol: 1},
+			{desc: "brace after text", name: "a.go", text: "\tif x {\n\t\ty{", col: 4,
+				edit: func(e *editor.Editor) { e.TypeRune('}') }, want: "\tif x {\n\t\ty{}", wantCol: 5},
+		}
+		for _, tt := range tests {
+			lines := strings.Split(tt.text, "\n")
+			e := newEditor(t, tt.name, tt.text, tt.config, len(lines)-1, tt.col)
+			tt.edit(e)
+			if diff := cmp.Diff(tt.want, strings.Join(e.Buffer.Lines, "\n")); diff != "" {
+				t.Errorf("%s: %s", tt.desc, diff)
+			}
+			_, col := e.Screen.Position()
+			if diff := cmp.Diff(tt.wantCol, col); diff != "" {
+				t.Errorf("%s: %s", tt.desc, diff)
+			}
+		}
+	})
+}
@@ -5,6 +5,7 @@ import "unicode"
 type Key struct {
 	Value           rune
 	EscapedSequence []rune
+	Meta            bool
 }
 
 func (k Key) IsControl() bool {
@@ -14,3 +15,38 @@ func (k Key) IsControl() bool {
 func (k Key) IsEscaped() bool {
 	return len(k.EscapedSequence) > 0
 }
+
+var escapedKeyNames = map[rune]string{
+	'A': "Up",
+	'B': "Down",
+	'C': "Right",
+	'D': "Left",
+	'Z': "S-TAB",
+}
+
+var controlKeyNames = map[rune]string{
+	'\r':   "RET",
+	'\t':   "TAB",
+	'\x1b': "ESC",
+	'\x7f': "DEL",
+	' ':    "SPC",
+}
+
+// String returns the name of the key used in key bindings, such as "C-x", "M-f" or "Up".
+func (k Key) String() string {
+	var s string
+	switch {
+	case k.IsEscaped():
+		s = escapedKeyNames[k.EscapedSequence[0]]
+	case controlKeyNames[k.Value] != "":
+		s = controlKeyNames[k.Value]
+	case k.Value < ' ':
+		s = "C-" + string(unicode.ToLower(k.Value|0x40))
+	default:
+		s = string(k.Value)
+	}
+	if k.Meta {
+		s = "M-" + s
+	}
+	return s
+}
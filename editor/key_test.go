@@ -41,4 +41,23 @@ func TestKey(t *testing.T) {
 			}
 		}
 	})
+	t.Run("String()", func(t *testing.T) {
+		tests := []struct {
+			desc string
+			key  editor.Key
+			want string
+		}{
+			{desc: "printable", key: editor.Key{Value: 'a'}, want: "a"},
+			{desc: "control", key: editor.Key{Value: '\x18'}, want: "C-x"},
+			{desc: "control with symbol", key: editor.Key{Value: '\x1d'}, want: "C-]"},
+			{desc: "return", key: editor.Key{Value: '\r'}, want: "RET"},
+			{desc: "meta", key: editor.Key{Value: 'x', Meta: true}, want: "M-x"},
+			{desc: "CUU", key: editor.Key{EscapedSequence: []rune{'A'}}, want: "Up"},
+		}
+		for _, tt := range tests {
+			if diff := cmp.Diff(tt.want, tt.key.String()); diff != "" {
+				t.Errorf("%s: %s", tt.desc, diff)
+			}
+		}
+	})
 }
@@ -0,0 +1,105 @@
+package editor
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Keymap maps key sequences such as "C-x C-s" to command names.
+type Keymap struct {
+	Bindings map[string]string
+}
+
+func NewKeymap() *Keymap {
+	return &Keymap{
+		Bindings: map[string]string{},
+	}
+}
+
+func (km *Keymap) Bind(seq, command string) {
+	km.Bindings[normalizeKeys(seq)] = command
+}
+
+func (km *Keymap) Unbind(seq string) {
+	delete(km.Bindings, normalizeKeys(seq))
+}
+
+// Lookup returns the command bound to seq. prefix is true if seq begins a longer sequence.
+func (km *Keymap) Lookup(seq string) (command string, prefix bool) {
+	seq = normalizeKeys(seq)
+	if c, ok := km.Bindings[seq]; ok {
+		return c, false
+	}
+	for s := range km.Bindings {
+		if strings.HasPrefix(s, seq+" ") {
+			return "", true
+		}
+	}
+	return "", false
+}
+
+// Load reads bindings from r. Each line is one of
+//
+//	bind <keys>... <command>
+//	unbind <keys>...
+//	clear
+//
+// where clear removes every binding defined before. Empty lines and lines starting with # are ignored.
+func (km *Keymap) Load(r io.Reader) error {
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		fs := strings.Fields(sc.Text())
+		if len(fs) == 0 || strings.HasPrefix(fs[0], "#") {
+			continue
+		}
+		switch {
+		case fs[0] == "bind" && len(fs) >= 3:
+			km.Bind(strings.Join(fs[1:len(fs)-1], " "), fs[len(fs)-1])
+		case fs[0] == "unbind" && len(fs) >= 2:
+			km.Unbind(strings.Join(fs[1:], " "))
+		case fs[0] == "clear" && len(fs) == 1:
+			km.Bindings = map[string]string{}
+		default:
+			return fmt.Errorf("line %d: invalid keymap entry: %s", n, sc.Text())
+		}
+	}
+	return sc.Err()
+}
+
+// LoadFile reads bindings from the file at path if it exists.
+func (km *Keymap) LoadFile(path string) error {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := km.Load(f); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
+
+// ConfigDir returns the directory of the configuration files, $XDG_CONFIG_HOME/re.
+func ConfigDir() string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "re")
+}
+
+func normalizeKeys(seq string) string {
+	return strings.Join(strings.Fields(seq), " ")
+}
@@ -0,0 +1,105 @@
+package editor_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/mizuochikeita/re/editor"
+)
+
+func TestKeymap(t *testing.T) {
+	t.Run("Lookup()", func(t *testing.T) {
+		km := editor.NewKeymap()
+		km.Bind("C-x C-s", "save-buffer")
+		km.Bind("C-f", "move-right")
+		tests := []struct {
+			seq         string
+			wantCommand string
+			wantPrefix  bool
+		}{
+			{seq: "C-f", wantCommand: "move-right", wantPrefix: false},
+			{seq: "C-x", wantCommand: "", wantPrefix: true},
+			{seq: "C-x  C-s", wantCommand: "save-buffer", wantPrefix: false},
+			{seq: "C-x C-f", wantCommand: "", wantPrefix: false},
+		}
+		for _, tt := range tests {
+			command, prefix := km.Lookup(tt.seq)
+			if diff := cmp.Diff(tt.wantCommand, command); diff != "" {
+				t.Errorf("%s: %s", tt.seq, diff)
+			}
+			if diff := cmp.Diff(tt.wantPrefix, prefix); diff != "" {
+				t.Errorf("%s: %s", tt.seq, diff)
+			}
+		}
+	})
+
+	t.Run("Load()", func(t *testing.T) {
+		tests := []struct {
+			desc    string
+			config  string
+			want    map[string]string
+			wantErr bool
+		}{
+			{
+				desc: "bind and unbind",
+				config: `# my keys
+bind C-c C-c save-buffer
+unbind C-f
+`,
+				want: map[string]string{
+					"C-c C-c": "save-buffer",
+					"C-b":     "move-left",
+				},
+			},
+			{
+				desc:   "clear",
+				config: "clear\nbind C-v move-below\n",
+				want: map[string]string{
+					"C-v": "move-below",
+				},
+			},
+			{
+				desc:    "invalid entry",
+				config:  "bind C-v\n",
+				wantErr: true,
+			},
+		}
+		for _, tt := range tests {
+			km := editor.NewKeymap()
+			km.Bind("C-f", "move-right")
+			km.Bind("C-b", "move-left")
+			err := km.Load(strings.NewReader(tt.config))
+			if diff := cmp.Diff(tt.wantErr, err != nil); diff != "" {
+				t.Errorf("%s: %s", tt.desc, diff)
+			}
+			if tt.wantErr {
+				continue
+			}
+			if diff := cmp.Diff(tt.want, km.Bindings); diff != "" {
+				t.Errorf("%s: %s", tt.desc, diff)
+			}
+		}
+	})
+
+	t.Run("LoadKeymap()", func(t *testing.T) {
+		dir := t.TempDir()
+		t.Setenv("XDG_CONFIG_HOME", dir)
+		if err := os.Mkdir(filepath.Join(dir, "re"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "re", "keymap"), []byte("bind C-c C-s save-buffer\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		e := editor.New()
+		if err := e.LoadKeymap(); err != nil {
+			t.Fatal(err)
+		}
+		command, _ := e.Keymap.Lookup("C-c C-s")
+		if diff := cmp.Diff("save-buffer", command); diff != "" {
+			t.Error(diff)
+		}
+	})
+}
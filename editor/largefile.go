@@ -0,0 +1,245 @@
+package editor
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+const (
+	// defaultLargeFileSize is the size from which files are opened as large files.
+	defaultLargeFileSize = 32 << 20
+	// maxLineLength is the length in bytes from which the lines of a large file are split.
+	maxLineLength = 64 << 10
+	// indexStep is the number of lines between the offsets kept in the line index.
+	indexStep = 256
+	// binarySample is the length of the beginning of a file IsBinary looks at.
+	binarySample = 8 << 10
+	// regionLines and regionBytes are the sizes of the parts of large files loaded in buffers.
+	regionLines = 4096
+	regionBytes = 1 << 20
+)
+
+// LargeFile reads a file too large to be loaded in a buffer as a whole.
+// Its lines are indexed in the background by BuildIndex.
+type LargeFile struct {
+	f    *os.File
+	Size int64
+
+	mu    sync.Mutex
+	index []int64 // offsets of the lines 0, indexStep, 2*indexStep, ...
+	lines int
+	done  chan struct{}
+}
+
+func OpenLargeFile(path string) (*LargeFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &LargeFile{
+		f:     f,
+		Size:  fi.Size(),
+		index: []int64{0},
+		done:  make(chan struct{}),
+	}, nil
+}
+
+func (lf *LargeFile) Close() error {
+	return lf.f.Close()
+}
+
+// BuildIndex finds the lines of the file and calls notify when it is done.
+func (lf *LargeFile) BuildIndex(notify func()) {
+	defer func() {
+		close(lf.done)
+		notify()
+	}()
+	sc := newLineScanner(lf.f, 0, lf.Size)
+	for n := 1; ; n++ {
+		if _, err := sc.Next(); err != nil {
+			return
+		}
+		if n%indexStep == 0 {
+			lf.mu.Lock()
+			lf.index = append(lf.index, sc.off)
+			lf.lines = n
+			lf.mu.Unlock()
+		} else if sc.off == lf.Size {
+			lf.mu.Lock()
+			lf.lines = n
+			lf.mu.Unlock()
+		}
+	}
+}
+
+// Lines returns the number of lines indexed so far and whether the whole file is indexed.
+func (lf *LargeFile) Lines() (int, bool) {
+	lf.mu.Lock()
+	defer lf.mu.Unlock()
+	select {
+	case <-lf.done:
+		return lf.lines, true
+	default:
+		return lf.lines, false
+	}
+}
+
+// Done returns a channel closed when the index is built.
+func (lf *LargeFile) Done() <-chan struct{} {
+	return lf.done
+}
+
+// ReadLines returns up to n lines from the line first, and whether they reach the end of the file.
+// Lines beyond the index are found by reading from the last indexed line.
+func (lf *LargeFile) ReadLines(first, n int) ([][]byte, bool, error) {
+	lf.mu.Lock()
+	k := first / indexStep
+	if k >= len(lf.index) {
+		k = len(lf.index) - 1
+	}
+	off := lf.index[k]
+	lf.mu.Unlock()
+
+	sc := newLineScanner(lf.f, off, lf.Size)
+	for i := k * indexStep; i < first; i++ {
+		if _, err := sc.Next(); err != nil {
+			return nil, true, ignoreEOF(err)
+		}
+	}
+	var lines [][]byte
+	for len(lines) < n {
+		line, err := sc.Next()
+		if err != nil {
+			return lines, true, ignoreEOF(err)
+		}
+		lines = append(lines, append([]byte(nil), line...))
+	}
+	return lines, sc.off == lf.Size, nil
+}
+
+// ReadBytes returns up to n bytes from off.
+func (lf *LargeFile) ReadBytes(off int64, n int) ([]byte, error) {
+	buf := make([]byte, n)
+	m, err := lf.f.ReadAt(buf, off)
+	return buf[:m], ignoreEOF(err)
+}
+
+func ignoreEOF(err error) error {
+	if errors.Is(err, io.EOF) {
+		return nil
+	}
+	return err
+}
+
+// lineScanner reads the lines of a file from an offset.
+type lineScanner struct {
+	r          io.Reader
+	buf        []byte
+	start, end int
+	eof        bool
+	off        int64
+}
+
+func newLineScanner(f io.ReaderAt, off, size int64) *lineScanner {
+	return &lineScanner{
+		r:   io.NewSectionReader(f, off, size-off),
+		buf: make([]byte, 16*maxLineLength),
+		off: off,
+	}
+}
+
+// Next returns the next line without its newline. It is valid until the next call.
+// Lines longer than maxLineLength are split at a character boundary.
+func (s *lineScanner) Next() ([]byte, error) {
+	for {
+		data := s.buf[s.start:s.end]
+		head := data
+		if len(head) > maxLineLength {
+			head = head[:maxLineLength+1]
+		}
+		switch i := bytes.IndexByte(head, '\n'); {
+		case i >= 0:
+			return s.consume(data[:i], i+1), nil
+		case len(data) > maxLineLength:
+			n := maxLineLength
+			for n > maxLineLength-utf8.UTFMax && !utf8.RuneStart(data[n]) {
+				n--
+			}
+			return s.consume(data[:n], n), nil
+		case s.eof && len(data) > 0:
+			return s.consume(data, len(data)), nil
+		case s.eof:
+			return nil, io.EOF
+		}
+		s.end = copy(s.buf, data)
+		s.start = 0
+		n, err := s.r.Read(s.buf[s.end:])
+		s.end += n
+		if errors.Is(err, io.EOF) {
+			s.eof = true
+		} else if err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (s *lineScanner) consume(line []byte, n int) []byte {
+	s.start += n
+	s.off += int64(n)
+	return line
+}
+
+// IsBinary reports whether data looks like the beginning of a binary file: it has a NUL byte,
+// or more than a tenth of it is control characters or bytes invalid in the encoding enc.
+func IsBinary(data []byte, enc string) bool {
+	if strings.HasPrefix(enc, "UTF-16") {
+		return false
+	}
+	if bytes.IndexByte(data, 0) >= 0 {
+		return true
+	}
+	s, invalid, _ := decode(enc, data)
+	bad := invalid
+	for _, r := range s {
+		if r < 0x20 && !strings.ContainsRune("\t\n\r\f\v\b\x1b", r) {
+			bad++
+		}
+	}
+	return bad*10 > len(data)
+}
+
+// ParseSize parses a size in bytes with an optional suffix K, M or G.
+func ParseSize(size string) (int64, error) {
+	s := strings.TrimSpace(size)
+	mul := int64(1)
+	if s != "" {
+		switch strings.ToUpper(s[len(s)-1:]) {
+		case "K":
+			mul = 1 << 10
+		case "M":
+			mul = 1 << 20
+		case "G":
+			mul = 1 << 30
+		}
+		if mul > 1 {
+			s = s[:len(s)-1]
+		}
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size: %s", size)
+	}
+	return n * mul, nil
+}
@@ -0,0 +1,206 @@
+package editor_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/mizuochikeita/re/editor"
+)
+
+func writeTempFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLargeFile(t *testing.T) {
+	t.Run("ReadLines()", func(t *testing.T) {
+		var sb strings.Builder
+		for i := 0; i < 1000; i++ {
+			fmt.Fprintf(&sb, "line %d\n", i)
+		}
+		long := strings.Repeat("あ", 30000) // longer than the 64 KiB lines are split at
+		sb.WriteString(long)
+		lf, err := editor.OpenLargeFile(writeTempFile(t, "large.txt", sb.String()))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer lf.Close()
+		lf.BuildIndex(func() {})
+		n, done := lf.Lines()
+		if diff := cmp.Diff([2]interface{}{1002, true}, [2]interface{}{n, done}); diff != "" {
+			t.Error(diff)
+		}
+
+		tests := []struct {
+			desc    string
+			first   int
+			n       int
+			want    []string
+			wantEnd bool
+		}{
+			{desc: "first lines", first: 0, n: 2, want: []string{"line 0", "line 1"}},
+			{desc: "indexed lines", first: 600, n: 2, want: []string{"line 600", "line 601"}},
+			{desc: "split long line", first: 999, n: 5, want: []string{"line 999", long[:65535], long[65535:]}, wantEnd: true},
+		}
+		for _, tt := range tests {
+			lines, end, err := lf.ReadLines(tt.first, tt.n)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, l := range lines {
+				got = append(got, string(l))
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("%s: %s", tt.desc, diff)
+			}
+			if diff := cmp.Diff(tt.wantEnd, end); diff != "" {
+				t.Errorf("%s: %s", tt.desc, diff)
+			}
+		}
+	})
+
+	t.Run("IsBinary()", func(t *testing.T) {
+		tests := []struct {
+			desc string
+			data string
+			enc  string
+			want bool
+		}{
+			{desc: "text", data: "hello\tworld\r\n", enc: "UTF-8", want: false},
+			{desc: "NUL byte", data: "hello\x00world", enc: "UTF-8", want: true},
+			{desc: "control characters", data: "\x01\x02\x03abcdef", enc: "UTF-8", want: true},
+			{desc: "few invalid bytes", data: "hello world \xff", enc: "UTF-8", want: false},
+			{desc: "UTF-16", data: "h\x00i\x00", enc: "UTF-16LE", want: false},
+		}
+		for _, tt := range tests {
+			if diff := cmp.Diff(tt.want, editor.IsBinary([]byte(tt.data), tt.enc)); diff != "" {
+				t.Errorf("%s: %s", tt.desc, diff)
+			}
+		}
+	})
+
+	t.Run("ParseSize()", func(t *testing.T) {
+		tests := []struct {
+			size    string
+			want    int64
+			wantErr bool
+		}{
+			{size: "100", want: 100},
+			{size: "4K", want: 4 << 10},
+			{size: "32m", want: 32 << 20},
+			{size: " 1G ", want: 1 << 30},
+			{size: "K", wantErr: true},
+			{size: "-1", wantErr: true},
+		}
+		for _, tt := range tests {
+			got, err := editor.ParseSize(tt.size)
+			if diff := cmp.Diff(tt.wantErr, err != nil); diff != "" {
+				t.Errorf("%q: %s", tt.size, diff)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("%q: %s", tt.size, diff)
+			}
+		}
+	})
+
+	t.Run("OpenFile() with a large file", func(t *testing.T) {
+		var sb strings.Builder
+		for i := 0; i < 20000; i++ {
+			fmt.Fprintf(&sb, "line %d\r\n", i)
+		}
+		path := writeTempFile(t, "large.txt", sb.String())
+		e := editor.New()
+		c, err := editor.ParseConfig(strings.NewReader("[file]\nlarge_file_size = 1K\n"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		e.Config = c
+		if err := e.OpenFile(path); err != nil {
+			t.Fatal(err)
+		}
+		e.Layout.Arrange(0, 0, 80, 24)
+		b := e.Buffer
+		<-b.Large.Done()
+		if diff := cmp.Diff(true, b.ReadOnly); diff != "" {
+			t.Error(diff)
+		}
+		if diff := cmp.Diff("CRLF", b.LineEnding); diff != "" {
+			t.Error(diff)
+		}
+		if diff := cmp.Diff([]string{"line 0", "line 1"}, b.Lines[:2]); diff != "" {
+			t.Error(diff)
+		}
+		e.InsertText("x")
+		if diff := cmp.Diff(editor.ErrReadOnly.Error(), e.Message.Text); diff != "" {
+			t.Error(diff)
+		}
+
+		// Moving the cursor close to the end of the region loads the lines beyond it.
+		e.Screen.SetPosition(len(b.Lines)-10, 0)
+		e.FollowRegion()
+		if b.FirstLine == 0 {
+			t.Error("region was not moved")
+		}
+		line, _ := e.Screen.Position()
+		if diff := cmp.Diff(fmt.Sprintf("line %d", b.FirstLine+line), b.Lines[line]); diff != "" {
+			t.Error(diff)
+		}
+		if diff := cmp.Diff(4096-10, b.FirstLine+line); diff != "" {
+			t.Error(diff)
+		}
+	})
+
+	t.Run("OpenFileArg() with a large file", func(t *testing.T) {
+		var sb strings.Builder
+		for i := 0; i < 20000; i++ {
+			fmt.Fprintf(&sb, "line %d\n", i)
+		}
+		path := writeTempFile(t, "big.log", sb.String())
+		e := editor.New()
+		c, err := editor.ParseConfig(strings.NewReader("[file]\nlarge_file_size = 1K\n"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		e.Config = c
+		// The line is beyond the first region, and may be before the index is built.
+		if err := e.OpenFileArg(editor.FileArg{Path: path, Line: 10000, Col: 3}); err != nil {
+			t.Fatal(err)
+		}
+		line, col := e.Screen.Position()
+		if diff := cmp.Diff("line 9999", e.Buffer.Lines[line]); diff != "" {
+			t.Error(diff)
+		}
+		if diff := cmp.Diff(2, col); diff != "" {
+			t.Error(diff)
+		}
+		e.SetViMode(true)
+		e.Vi.ExecuteCommandLine(e, "15000")
+		line, _ = e.Screen.Position()
+		if diff := cmp.Diff("line 14999", e.Buffer.Lines[line]); diff != "" {
+			t.Error(diff)
+		}
+	})
+
+	t.Run("OpenFile() with a binary file", func(t *testing.T) {
+		e := editor.New()
+		if err := e.OpenFile(writeTempFile(t, "bin", "\x00\x01\x02abc")); err != nil {
+			t.Fatal(err)
+		}
+		e.Layout.Arrange(0, 0, 80, 24)
+		if diff := cmp.Diff([2]bool{true, true}, [2]bool{e.Buffer.HexMode, e.Buffer.ReadOnly}); diff != "" {
+			t.Error(diff)
+		}
+		if diff := cmp.Diff("00000000  00 01 02 61 62 63                                ...abc", e.Screen.Rows[0].Body); diff != "" {
+			t.Error(diff)
+		}
+	})
+}
@@ -0,0 +1,119 @@
+package editor
+
+// Layout is a tree dividing the terminal into windows.
+// A leaf holds a window and a node arranges its children side by side if Vertical, or stacked otherwise.
+type Layout struct {
+	Window   *Window
+	Vertical bool
+	Children []*Layout
+	parent   *Layout
+}
+
+func NewLayout(w *Window) *Layout {
+	return &Layout{Window: w}
+}
+
+// Windows returns the windows in the order they appear on the screen.
+func (l *Layout) Windows() []*Window {
+	if l.Window != nil {
+		return []*Window{l.Window}
+	}
+	var ws []*Window
+	for _, c := range l.Children {
+		ws = append(ws, c.Windows()...)
+	}
+	return ws
+}
+
+// Split divides the window w into two, placing nw after w.
+func (l *Layout) Split(w, nw *Window, vertical bool) {
+	leaf := l.find(w)
+	if leaf == nil {
+		return
+	}
+	p := leaf.parent
+	if p != nil && p.Vertical == vertical {
+		for i, c := range p.Children {
+			if c == leaf {
+				n := &Layout{Window: nw, parent: p}
+				p.Children = append(p.Children[:i+1], append([]*Layout{n}, p.Children[i+1:]...)...)
+				return
+			}
+		}
+	}
+	leaf.Children = []*Layout{
+		{Window: w, parent: leaf},
+		{Window: nw, parent: leaf},
+	}
+	leaf.Window = nil
+	leaf.Vertical = vertical
+}
+
+// Remove deletes the window w from the layout. The last window cannot be removed.
+func (l *Layout) Remove(w *Window) bool {
+	leaf := l.find(w)
+	if leaf == nil || leaf.parent == nil {
+		return false
+	}
+	p := leaf.parent
+	for i, c := range p.Children {
+		if c == leaf {
+			p.Children = append(p.Children[:i], p.Children[i+1:]...)
+			break
+		}
+	}
+	if len(p.Children) == 1 {
+		c := p.Children[0]
+		p.Window = c.Window
+		p.Vertical = c.Vertical
+		p.Children = c.Children
+		for _, cc := range p.Children {
+			cc.parent = p
+		}
+	}
+	return true
+}
+
+// Arrange assigns the rectangle to the windows. Windows side by side are separated by a column.
+func (l *Layout) Arrange(x, y, width, height int) {
+	if l.Window != nil {
+		l.Window.Resize(x, y, width, height)
+		return
+	}
+	n := len(l.Children)
+	if l.Vertical {
+		avail := width - (n - 1)
+		for i, c := range l.Children {
+			w := avail / n
+			if i < avail%n {
+				w++
+			}
+			c.Arrange(x, y, w, height)
+			x += w + 1
+		}
+		return
+	}
+	for i, c := range l.Children {
+		h := height / n
+		if i < height%n {
+			h++
+		}
+		c.Arrange(x, y, width, h)
+		y += h
+	}
+}
+
+func (l *Layout) find(w *Window) *Layout {
+	if l.Window != nil {
+		if l.Window == w {
+			return l
+		}
+		return nil
+	}
+	for _, c := range l.Children {
+		if f := c.find(w); f != nil {
+			return f
+		}
+	}
+	return nil
+}
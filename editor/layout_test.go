@@ -0,0 +1,69 @@
+package editor_test
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/mizuochikeita/re/editor"
+)
+
+func TestLayout(t *testing.T) {
+	rects := func(l *editor.Layout) [][4]int {
+		var rs [][4]int
+		for _, w := range l.Windows() {
+			rs = append(rs, [4]int{w.X, w.Y, w.Width, w.Height})
+		}
+		return rs
+	}
+
+	t.Run("Arrange()", func(t *testing.T) {
+		b := editor.NewBuffer("test")
+		a, c, d := editor.NewWindow(b), editor.NewWindow(b), editor.NewWindow(b)
+		l := editor.NewLayout(a)
+		l.Split(a, c, false)
+		l.Split(c, d, true)
+		l.Arrange(0, 0, 21, 11)
+		want := [][4]int{
+			{0, 0, 21, 6},
+			{0, 6, 10, 5},
+			{11, 6, 10, 5},
+		}
+		if diff := cmp.Diff(want, rects(l)); diff != "" {
+			t.Error(diff)
+		}
+	})
+
+	t.Run("Split() in the same direction", func(t *testing.T) {
+		b := editor.NewBuffer("test")
+		a, c, d := editor.NewWindow(b), editor.NewWindow(b), editor.NewWindow(b)
+		l := editor.NewLayout(a)
+		l.Split(a, c, false)
+		l.Split(a, d, false)
+		l.Arrange(0, 0, 10, 9)
+		if ws := l.Windows(); ws[0] != a || ws[1] != d || ws[2] != c {
+			t.Error("unexpected window order")
+		}
+		if diff := cmp.Diff([][4]int{{0, 0, 10, 3}, {0, 3, 10, 3}, {0, 6, 10, 3}}, rects(l)); diff != "" {
+			t.Error(diff)
+		}
+	})
+
+	t.Run("Remove()", func(t *testing.T) {
+		b := editor.NewBuffer("test")
+		a, c, d := editor.NewWindow(b), editor.NewWindow(b), editor.NewWindow(b)
+		l := editor.NewLayout(a)
+		l.Split(a, c, false)
+		l.Split(c, d, true)
+		if !l.Remove(a) {
+			t.Fatal("failed to remove")
+		}
+		l.Arrange(0, 0, 21, 10)
+		if diff := cmp.Diff([][4]int{{0, 0, 10, 10}, {11, 0, 10, 10}}, rects(l)); diff != "" {
+			t.Error(diff)
+		}
+		l.Remove(c)
+		if l.Remove(d) {
+			t.Error("removed the last window")
+		}
+	})
+}
@@ -0,0 +1,200 @@
+package editor
+
+import (
+	"strings"
+	"unicode"
+)
+
+// breakClass is a line breaking class of UAX #14, reduced to the classes which matter for
+// wrapping text on the screen.
+type breakClass int
+
+const (
+	breakAL  breakClass = iota // alphabetic and everything else
+	breakSP                    // space
+	breakZW                    // zero width space
+	breakZWJ                   // zero width joiner
+	breakWJ                    // word joiner
+	breakGL                    // non-breaking glue
+	breakCM                    // combining mark
+	breakOP                    // opening punctuation
+	breakCL                    // closing punctuation
+	breakCP                    // closing parenthesis
+	breakQU                    // ambiguous quotation
+	breakEX                    // exclamation and interrogation
+	breakIS                    // infix separator
+	breakNS                    // nonstarter, including the small kana of the Japanese kinsoku rules
+	breakHY                    // hyphen
+	breakBA                    // break after
+	breakB2                    // break opportunity before and after
+	breakPR                    // prefix numeric
+	breakPO                    // postfix numeric
+	breakNU                    // numeric
+	breakID                    // ideographic
+)
+
+const (
+	openPunctuation   = "([{‘“"
+	closeParentheses  = ")]"
+	closePunctuation  = "}’”、。，．｡､"
+	quotations        = "\"'«»‹›"
+	exclamations      = "!?！？"
+	infixSeparators   = ",.:;：；"
+	nonstarters       = "ー々〻ゝゞヽヾ・‥…〜゠ぁぃぅぇぉっゃゅょゎゕゖァィゥェォッャュョヮヵヶｧｨｩｪｫｬｭｮｯｰ"
+	breakAfterHyphens = "\u00ad\u2010\u2013|"
+	wordJoiners       = "\u2060\ufeff"
+	nonBreakingMarks  = "\u00a0\u2007\u2011\u202f"
+	numericPrefixes   = "+\\±№＋＼"
+	numericPostfixes  = "%¢°‰‱′″‴℃℉％￠"
+)
+
+func lineBreakClass(r rune) breakClass {
+	switch {
+	case r == ' ' || r == '\t':
+		return breakSP
+	case r == '\u200b':
+		return breakZW
+	case r == '\u200d':
+		return breakZWJ
+	case strings.ContainsRune(wordJoiners, r):
+		return breakWJ
+	case strings.ContainsRune(nonBreakingMarks, r):
+		return breakGL
+	case r == '\u200c' || unicode.In(r, unicode.Mn, unicode.Me, unicode.Mc):
+		return breakCM
+	case strings.ContainsRune(openPunctuation, r) || unicode.Is(unicode.Ps, r):
+		return breakOP
+	case strings.ContainsRune(closeParentheses, r):
+		return breakCP
+	case strings.ContainsRune(closePunctuation, r) || unicode.Is(unicode.Pe, r):
+		return breakCL
+	case strings.ContainsRune(quotations, r):
+		return breakQU
+	case strings.ContainsRune(exclamations, r):
+		return breakEX
+	case strings.ContainsRune(infixSeparators, r):
+		return breakIS
+	case strings.ContainsRune(nonstarters, r) || 'ㇰ' <= r && r <= 'ㇿ':
+		return breakNS
+	case r == '-':
+		return breakHY
+	case strings.ContainsRune(breakAfterHyphens, r):
+		return breakBA
+	case r == '\u2014':
+		return breakB2
+	case strings.ContainsRune(numericPostfixes, r):
+		return breakPO
+	case strings.ContainsRune(numericPrefixes, r) || unicode.Is(unicode.Sc, r):
+		return breakPR
+	case unicode.IsDigit(r):
+		return breakNU
+	case unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul),
+		'\u3000' <= r && r <= '\u303f',         // CJK symbols and punctuation
+		'！' <= r && r <= '｠',                   // fullwidth forms
+		'\U0001f000' <= r && r <= '\U0001faff': // pictographs
+		return breakID
+	}
+	return breakAL
+}
+
+// lineBreaker finds where a line can be broken, following UAX #14, from its runes one after
+// another. It remembers the class before the spaces, which the rules about punctuation look back
+// at. The curly quotes are opening and closing punctuation, as in Japanese text, and the classes
+// and rules for other scripts, such as Hebrew letters and regional indicators, are left out.
+type lineBreaker struct {
+	started bool
+	// prev is the class of the last rune, or of the rune its combining marks follow.
+	prev breakClass
+	// beforeSpaces is the class of the last rune which is not a space.
+	beforeSpaces breakClass
+	// joined is whether the last rune is a zero width joiner.
+	joined bool
+}
+
+// next reports whether the line can be broken before r, after the runes given before.
+func (lb *lineBreaker) next(r rune) bool {
+	c := lineBreakClass(r)
+	if !lb.started {
+		lb.started = true
+		lb.joined = c == breakZWJ
+		if c == breakCM || c == breakZWJ {
+			c = breakAL
+		}
+		lb.prev, lb.beforeSpaces = c, c
+		return false
+	}
+	brk := lb.canBreak(c)
+	lb.joined = c == breakZWJ
+	switch {
+	case c != breakCM && c != breakZWJ:
+	case lb.prev == breakSP || lb.prev == breakZW:
+		c = breakAL
+	default:
+		c = lb.prev
+	}
+	lb.prev = c
+	if c != breakSP {
+		lb.beforeSpaces = c
+	}
+	return brk
+}
+
+// canBreak reports whether the line can be broken before a rune of the class c.
+func (lb *lineBreaker) canBreak(c breakClass) bool {
+	a, s := lb.prev, lb.beforeSpaces
+	is := func(c breakClass, cs ...breakClass) bool {
+		for _, x := range cs {
+			if c == x {
+				return true
+			}
+		}
+		return false
+	}
+	switch {
+	case c == breakSP || c == breakZW:
+		return false
+	case s == breakZW:
+		return true
+	case lb.joined:
+		return false
+	case is(c, breakCM, breakZWJ):
+		if a != breakSP {
+			return false
+		}
+		c = breakAL
+	}
+	switch {
+	case c == breakWJ || a == breakWJ || a == breakGL:
+		return false
+	case c == breakGL:
+		return is(a, breakSP, breakBA, breakHY)
+	case is(c, breakCL, breakCP, breakEX, breakIS):
+		return false
+	// Punctuation keeps to what follows even across spaces.
+	case s == breakOP,
+		s == breakQU && c == breakOP,
+		is(s, breakCL, breakCP) && c == breakNS,
+		s == breakB2 && c == breakB2:
+		return false
+	case a == breakSP:
+		return true
+	case c == breakQU || a == breakQU:
+		return false
+	case is(c, breakBA, breakHY, breakNS):
+		return false
+	// Numbers keep to their signs, separators and surrounding punctuation.
+	case a == breakAL && c == breakNU, a == breakNU && c == breakAL,
+		a == breakPR && c == breakID, a == breakID && c == breakPO,
+		is(a, breakPR, breakPO) && c == breakAL, a == breakAL && is(c, breakPR, breakPO),
+		is(a, breakCL, breakCP, breakNU) && is(c, breakPR, breakPO),
+		is(a, breakPR, breakPO) && is(c, breakOP, breakNU),
+		is(a, breakHY, breakIS, breakNU) && c == breakNU:
+		return false
+	case a == breakAL && c == breakAL,
+		a == breakIS && c == breakAL,
+		is(a, breakAL, breakNU) && c == breakOP,
+		a == breakCP && is(c, breakAL, breakNU):
+		return false
+	}
+	return true
+}
@@ -0,0 +1,205 @@
+package editor
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Mark is a named position in a buffer, kept on its text as the buffer is edited. Line counts
+// from the beginning of the file in a large file.
+type Mark struct {
+	Line int
+	Col  int
+}
+
+func isMarkName(r rune) bool {
+	return 'a' <= r && r <= 'z'
+}
+
+// SetMark names the position of the cursor in the current buffer name, a lowercase letter.
+func (e *Editor) SetMark(name rune) error {
+	b := e.Buffer
+	if !isMarkName(name) {
+		return fmt.Errorf("invalid mark: %q", name)
+	}
+	if b.HexMode {
+		return errNotText
+	}
+	if b.Marks == nil {
+		b.Marks = map[rune]Mark{}
+	}
+	line, col := e.Screen.Position()
+	b.Marks[name] = Mark{b.FirstLine + line, col}
+	e.Infof("Set mark %c", name)
+	if b.Dirty {
+		// The marks are saved with the file, when they are on the text as it is saved.
+		return nil
+	}
+	return e.saveMarks(b)
+}
+
+// GetMark returns the mark name of the current buffer.
+func (e *Editor) GetMark(name rune) (Mark, error) {
+	if !isMarkName(name) {
+		return Mark{}, fmt.Errorf("invalid mark: %q", name)
+	}
+	m, ok := e.Buffer.Marks[name]
+	if !ok {
+		return Mark{}, fmt.Errorf("mark %c is not set", name)
+	}
+	return m, nil
+}
+
+// JumpToMark moves the cursor to the mark name, remembering the position before in the jump list.
+func (e *Editor) JumpToMark(name rune) error {
+	m, err := e.GetMark(name)
+	if err != nil {
+		return err
+	}
+	e.PushJump()
+	return e.gotoLine(m.Line, m.Col)
+}
+
+// adjustMarks moves the marks of b after the change c.
+func adjustMarks(b *Buffer, c Change) {
+	if b.HexMode {
+		return
+	}
+	for name, m := range b.Marks {
+		m.Line, m.Col = c.Adjust(m.Line, m.Col)
+		b.Marks[name] = m
+	}
+}
+
+// StateDir returns the directory where the editor keeps its state across sessions.
+func StateDir() string {
+	dir := os.Getenv("XDG_STATE_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".local", "state")
+	}
+	return filepath.Join(dir, "re")
+}
+
+// marksFile returns the file of the marks saved, or "" if marks are not saved.
+func (e *Editor) marksFile() (string, error) {
+	save, err := e.Config.GetBool("marks", "save", false)
+	if err != nil || !save {
+		return "", err
+	}
+	dir := StateDir()
+	if dir == "" {
+		return "", nil
+	}
+	return filepath.Join(dir, "marks"), nil
+}
+
+// restoreMarks sets the marks saved for the file of b.
+func (e *Editor) restoreMarks(b *Buffer) error {
+	file, err := e.marksFile()
+	if err != nil || file == "" || b.Path == "" {
+		return err
+	}
+	path, err := filepath.Abs(b.Path)
+	if err != nil {
+		return err
+	}
+	all, err := readMarks(file)
+	if err != nil {
+		return err
+	}
+	b.Marks = all[path]
+	return nil
+}
+
+// saveMarks saves the marks of b for its file.
+func (e *Editor) saveMarks(b *Buffer) error {
+	file, err := e.marksFile()
+	if err != nil || file == "" || b.Path == "" {
+		return err
+	}
+	path, err := filepath.Abs(b.Path)
+	if err != nil {
+		return err
+	}
+	all, err := readMarks(file)
+	if err != nil {
+		return err
+	}
+	if len(b.Marks) == 0 {
+		delete(all, path)
+	} else {
+		all[path] = b.Marks
+	}
+	return writeMarks(file, all)
+}
+
+// readMarks reads the marks of files by their absolute paths from file. Each line holds a mark
+// as "name line col path".
+func readMarks(file string) (map[string]map[rune]Mark, error) {
+	all := map[string]map[rune]Mark{}
+	f, err := os.Open(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return all, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		fields := strings.SplitN(sc.Text(), " ", 4)
+		if len(fields) < 4 {
+			continue
+		}
+		name := []rune(fields[0])
+		line, err1 := strconv.Atoi(fields[1])
+		col, err2 := strconv.Atoi(fields[2])
+		if len(name) != 1 || !isMarkName(name[0]) || err1 != nil || err2 != nil {
+			continue
+		}
+		path := fields[3]
+		if all[path] == nil {
+			all[path] = map[rune]Mark{}
+		}
+		all[path][name[0]] = Mark{line, col}
+	}
+	return all, sc.Err()
+}
+
+func writeMarks(file string, all map[string]map[rune]Mark) error {
+	var paths []string
+	for path := range all {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	var sb strings.Builder
+	for _, path := range paths {
+		var names []rune
+		for name := range all[path] {
+			names = append(names, name)
+		}
+		sort.Slice(names, func(i, j int) bool { return names[i] < names[j] })
+		for _, name := range names {
+			m := all[path][name]
+			fmt.Fprintf(&sb, "%c %d %d %s\n", name, m.Line, m.Col, path)
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+		return err
+	}
+	tmp := file + ".tmp"
+	if err := os.WriteFile(tmp, []byte(sb.String()), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, file)
+}
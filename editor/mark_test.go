@@ -0,0 +1,137 @@
+package editor_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/mizuochikeita/re/editor"
+)
+
+func TestMark(t *testing.T) {
+	t.Run("JumpToMark()", func(t *testing.T) {
+		tests := []struct {
+			desc    string
+			edit    func(e *editor.Editor)
+			want    [2]int
+			wantErr bool
+		}{
+			{desc: "no edit", edit: func(e *editor.Editor) {}, want: [2]int{2, 3}},
+			{desc: "lines inserted above", edit: func(e *editor.Editor) {
+				e.Screen.SetPosition(0, 0)
+				e.InsertText("x\ny\n")
+			}, want: [2]int{4, 3}},
+			{desc: "text inserted before on the line", edit: func(e *editor.Editor) {
+				e.Screen.SetPosition(2, 1)
+				e.InsertText("xy")
+			}, want: [2]int{2, 5}},
+			{desc: "text inserted after on the line", edit: func(e *editor.Editor) {
+				e.Screen.SetPosition(2, 4)
+				e.InsertText("xy")
+			}, want: [2]int{2, 3}},
+			{desc: "line above deleted", edit: func(e *editor.Editor) {
+				e.DeleteRange(0, 0, 1, 0)
+			}, want: [2]int{1, 3}},
+			{desc: "marked text deleted", edit: func(e *editor.Editor) {
+				e.DeleteRange(1, 2, 2, 5)
+			}, want: [2]int{1, 2}},
+		}
+		for _, tt := range tests {
+			e := editor.New()
+			if err := e.OpenReader("test", strings.NewReader("one\ntwo\nthree\nfour\n")); err != nil {
+				t.Fatal(err)
+			}
+			e.Layout.Arrange(0, 0, 80, 24)
+			e.Screen.SetPosition(2, 3)
+			if err := e.SetMark('a'); err != nil {
+				t.Fatal(err)
+			}
+			tt.edit(e)
+			e.Screen.SetPosition(0, 0)
+			err := e.JumpToMark('a')
+			if diff := cmp.Diff(tt.wantErr, err != nil); diff != "" {
+				t.Errorf("%s: %s", tt.desc, diff)
+			}
+			line, col := e.Screen.Position()
+			if diff := cmp.Diff(tt.want, [2]int{line, col}); diff != "" {
+				t.Errorf("%s: %s", tt.desc, diff)
+			}
+		}
+	})
+
+	t.Run("errors", func(t *testing.T) {
+		e := editor.New()
+		if err := e.OpenReader("test", strings.NewReader("text")); err != nil {
+			t.Fatal(err)
+		}
+		e.Layout.Arrange(0, 0, 80, 24)
+		if err := e.SetMark('A'); err == nil {
+			t.Error("uppercase mark was set")
+		}
+		if err := e.JumpToMark('b'); err == nil {
+			t.Error("jumped to a mark not set")
+		}
+	})
+
+	t.Run("saved marks", func(t *testing.T) {
+		state := t.TempDir()
+		t.Setenv("XDG_STATE_HOME", state)
+		c, err := editor.ParseConfig(strings.NewReader("[marks]\nsave = true\n"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		path := writeTempFile(t, "a.txt", "one\ntwo\nthree\n")
+
+		e := editor.New()
+		e.Config = c
+		if err := e.OpenFile(path); err != nil {
+			t.Fatal(err)
+		}
+		e.Layout.Arrange(0, 0, 80, 24)
+		e.Screen.SetPosition(1, 1)
+		if err := e.SetMark('a'); err != nil {
+			t.Fatal(err)
+		}
+		// Marks set in a modified buffer are saved with it.
+		e.Screen.SetPosition(0, 0)
+		e.InsertText("zero\n")
+		e.Screen.SetPosition(3, 2)
+		if err := e.SetMark('b'); err != nil {
+			t.Fatal(err)
+		}
+		e.SaveBuffer()
+		data, err := os.ReadFile(filepath.Join(state, "re", "marks"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff("a 2 1 "+path+"\nb 3 2 "+path+"\n", string(data)); diff != "" {
+			t.Error(diff)
+		}
+
+		e = editor.New()
+		e.Config = c
+		if err := e.OpenFile(path); err != nil {
+			t.Fatal(err)
+		}
+		e.Layout.Arrange(0, 0, 80, 24)
+		if err := e.JumpToMark('b'); err != nil {
+			t.Fatal(err)
+		}
+		line, col := e.Screen.Position()
+		if diff := cmp.Diff([2]int{3, 2}, [2]int{line, col}); diff != "" {
+			t.Error(diff)
+		}
+
+		// Without the option, marks are neither restored nor saved.
+		e = editor.New()
+		if err := e.OpenFile(path); err != nil {
+			t.Fatal(err)
+		}
+		e.Layout.Arrange(0, 0, 80, 24)
+		if err := e.JumpToMark('a'); err == nil {
+			t.Error("mark was restored")
+		}
+	})
+}
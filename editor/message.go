@@ -0,0 +1,86 @@
+package editor
+
+import (
+	"fmt"
+	"time"
+)
+
+type MessageLevel int
+
+const (
+	MessageInfo MessageLevel = iota
+	MessageWarning
+	MessageError
+)
+
+// messageTimeout is how long a message stays if no key is pressed.
+const messageTimeout = 5 * time.Second
+
+// Message is a notification shown in the minibuffer line until it expires or a key is pressed.
+type Message struct {
+	Text    string
+	Level   MessageLevel
+	Expires time.Time
+}
+
+func (e *Editor) Infof(format string, a ...interface{}) {
+	e.PostMessage(MessageInfo, fmt.Sprintf(format, a...))
+}
+
+func (e *Editor) Warnf(format string, a ...interface{}) {
+	e.PostMessage(MessageWarning, fmt.Sprintf(format, a...))
+}
+
+// Error shows err as an error message. It does nothing if err is nil.
+func (e *Editor) Error(err error) {
+	if err == nil {
+		return
+	}
+	e.PostMessage(MessageError, err.Error())
+}
+
+func (e *Editor) PostMessage(level MessageLevel, text string) {
+	e.Message = &Message{
+		Text:    text,
+		Level:   level,
+		Expires: time.Now().Add(messageTimeout),
+	}
+	time.AfterFunc(messageTimeout, e.wake)
+}
+
+// ExpireMessage removes the message if it has expired at now.
+func (e *Editor) ExpireMessage(now time.Time) {
+	if e.Message != nil && !now.Before(e.Message.Expires) {
+		e.Message = nil
+	}
+}
+
+// Wakeup returns a channel notified when the editor has to update the screen without any key pressed.
+func (e *Editor) Wakeup() <-chan struct{} {
+	return e.wakeup
+}
+
+// HandleWakeup updates the screen after a notification from Wakeup.
+func (e *Editor) HandleWakeup() error {
+	e.ExpireMessage(time.Now())
+	return e.RefreshScreen()
+}
+
+func (e *Editor) wake() {
+	select {
+	case e.wakeup <- struct{}{}:
+	default:
+	}
+}
+
+// Color returns the escape sequence to set the color of the message.
+func (m *Message) Color() string {
+	switch m.Level {
+	case MessageError:
+		return "\x1b[31m" // red
+	case MessageWarning:
+		return "\x1b[33m" // yellow
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,53 @@
+package editor_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/mizuochikeita/re/editor"
+)
+
+func TestMessage(t *testing.T) {
+	t.Run("ExpireMessage()", func(t *testing.T) {
+		e := editor.New()
+		e.Infof("saved %s", "a.txt")
+		if diff := cmp.Diff("saved a.txt", e.Message.Text); diff != "" {
+			t.Error(diff)
+		}
+		e.ExpireMessage(time.Now())
+		if e.Message == nil {
+			t.Fatal("message expired too early")
+		}
+		e.ExpireMessage(e.Message.Expires)
+		if e.Message != nil {
+			t.Error("message did not expire")
+		}
+	})
+
+	t.Run("Error()", func(t *testing.T) {
+		e := editor.New()
+		e.Error(nil)
+		if e.Message != nil {
+			t.Error("nil error posted a message")
+		}
+		e.Error(errors.New("failed"))
+		if diff := cmp.Diff(editor.MessageError, e.Message.Level); diff != "" {
+			t.Error(diff)
+		}
+	})
+
+	t.Run("command errors", func(t *testing.T) {
+		e := editor.New()
+		e.AddBuffer(editor.NewBuffer("test"))
+		e.Error(e.OpenFile(t.TempDir()))
+		if e.Message == nil || e.Message.Level != editor.MessageError {
+			t.Fatal("want error message")
+		}
+		e.HandleKey(editor.Key{Value: 'a'}, func() {})
+		if e.Message != nil {
+			t.Error("message was not cleared by key")
+		}
+	})
+}
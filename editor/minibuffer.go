@@ -0,0 +1,250 @@
+package editor
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Completer returns the candidates for an input of a minibuffer.
+type Completer func(input string) []string
+
+// FuzzyCompleter completes the input from candidates by fuzzy matching.
+func FuzzyCompleter(candidates []string) Completer {
+	return func(input string) []string {
+		if input == "" {
+			return nil
+		}
+		return FuzzyFilter(input, candidates)
+	}
+}
+
+// FileCompleter completes the input with the paths in its directory.
+func FileCompleter(input string) []string {
+	dir, base := filepath.Split(input)
+	d := dir
+	if d == "" {
+		d = "."
+	}
+	entries, err := os.ReadDir(d)
+	if err != nil {
+		return nil
+	}
+	var ps []string
+	for _, en := range entries {
+		if !strings.HasPrefix(en.Name(), base) {
+			continue
+		}
+		if strings.HasPrefix(en.Name(), ".") && !strings.HasPrefix(base, ".") {
+			continue
+		}
+		p := dir + en.Name()
+		if en.IsDir() {
+			p += string(filepath.Separator)
+		}
+		ps = append(ps, p)
+	}
+	sort.Strings(ps)
+	return ps
+}
+
+// History keeps the inputs of a kind of prompts, the oldest first.
+type History struct {
+	Entries []string
+}
+
+const maxHistory = 100
+
+func (h *History) Add(s string) {
+	if s == "" || len(h.Entries) > 0 && h.Entries[len(h.Entries)-1] == s {
+		return
+	}
+	h.Entries = append(h.Entries, s)
+	if len(h.Entries) > maxHistory {
+		h.Entries = h.Entries[len(h.Entries)-maxHistory:]
+	}
+}
+
+// Minibuffer is a one-line input area at the bottom of the screen.
+type Minibuffer struct {
+	Prompt   string
+	Input    []rune
+	Cursor   int
+	Complete Completer
+	Matches  []string
+	Selected int
+	// AcceptMatch makes the minibuffer return the selected match instead of the input.
+	AcceptMatch bool
+	// CancelOnEscape makes ESC cancel the input like C-g, as in vi.
+	CancelOnEscape bool
+	// Annotate optionally describes the selected match.
+	Annotate  func(match string) string
+	History   *History
+	histIndex int
+	done      func(string, bool)
+}
+
+func NewMinibuffer(prompt string, complete Completer, history *History, done func(string, bool)) *Minibuffer {
+	if history == nil {
+		history = &History{}
+	}
+	m := &Minibuffer{
+		Prompt:    prompt,
+		Complete:  complete,
+		History:   history,
+		histIndex: len(history.Entries),
+		done:      done,
+	}
+	m.updateMatches()
+	return m
+}
+
+// HandleKey edits the input and reports whether the minibuffer has finished.
+func (m *Minibuffer) HandleKey(k Key) bool {
+	switch {
+	case k.IsControl():
+		switch k.Value {
+		case '\r':
+			v := m.Value()
+			m.History.Add(v)
+			m.done(v, true)
+			return true
+		case ToControl('G'):
+			m.done("", false)
+			return true
+		case '\x1b':
+			if m.CancelOnEscape {
+				m.done("", false)
+				return true
+			}
+		case '\t':
+			if len(m.Matches) > 0 {
+				m.SetInput(m.Matches[m.Selected])
+			}
+		case ToControl('N'):
+			m.selectMatch(1)
+		case ToControl('P'):
+			m.selectMatch(-1)
+		case ToControl('A'):
+			m.Cursor = 0
+		case ToControl('E'):
+			m.Cursor = len(m.Input)
+		case ToControl('F'):
+			m.moveCursor(1)
+		case ToControl('B'):
+			m.moveCursor(-1)
+		case ToControl('D'):
+			m.delete(m.Cursor, m.Cursor+1)
+		case ToControl('K'):
+			m.delete(m.Cursor, len(m.Input))
+		case ToControl('U'):
+			m.delete(0, m.Cursor)
+		case '\x7f', ToControl('H'):
+			m.delete(m.Cursor-1, m.Cursor)
+		}
+	case k.IsEscaped():
+		switch k.EscapedSequence[0] {
+		case 'A':
+			m.moveHistory(-1)
+		case 'B':
+			m.moveHistory(1)
+		case 'C':
+			m.moveCursor(1)
+		case 'D':
+			m.moveCursor(-1)
+		}
+	case unicode.IsPrint(k.Value):
+		m.Input = append(m.Input[:m.Cursor], append([]rune{k.Value}, m.Input[m.Cursor:]...)...)
+		m.Cursor++
+		m.updateMatches()
+	}
+	return false
+}
+
+// Value returns the input, or the selected match if AcceptMatch is set.
+func (m *Minibuffer) Value() string {
+	if m.AcceptMatch && len(m.Matches) > 0 {
+		return m.Matches[m.Selected]
+	}
+	return string(m.Input)
+}
+
+// SetInput replaces the input and moves the cursor to its end.
+func (m *Minibuffer) SetInput(s string) {
+	m.Input = []rune(s)
+	m.Cursor = len(m.Input)
+	m.updateMatches()
+}
+
+func (m *Minibuffer) String() string {
+	s := m.Prompt + string(m.Input)
+	if len(m.Matches) > 0 {
+		if m.Annotate != nil {
+			s += " [" + m.Annotate(m.Matches[m.Selected]) + "]"
+		}
+		ms := append([]string{m.Matches[m.Selected]}, m.Matches[m.Selected+1:]...)
+		ms = append(ms, m.Matches[:m.Selected]...)
+		s += " {" + strings.Join(ms, " | ") + "}"
+	}
+	return s
+}
+
+// CursorX returns the screen column of the input cursor.
+func (m *Minibuffer) CursorX() int {
+	return StringWidth(m.Prompt + string(m.Input[:m.Cursor]))
+}
+
+func (m *Minibuffer) moveCursor(diff int) {
+	m.Cursor += diff
+	if m.Cursor < 0 {
+		m.Cursor = 0
+	}
+	if m.Cursor > len(m.Input) {
+		m.Cursor = len(m.Input)
+	}
+}
+
+func (m *Minibuffer) delete(from, to int) {
+	if from < 0 {
+		from = 0
+	}
+	if to > len(m.Input) {
+		to = len(m.Input)
+	}
+	if from >= to {
+		return
+	}
+	m.Input = append(m.Input[:from], m.Input[to:]...)
+	m.Cursor = from
+	m.updateMatches()
+}
+
+func (m *Minibuffer) moveHistory(diff int) {
+	i := m.histIndex + diff
+	if i < 0 || i > len(m.History.Entries) {
+		return
+	}
+	m.histIndex = i
+	if i == len(m.History.Entries) {
+		m.SetInput("")
+		return
+	}
+	m.SetInput(m.History.Entries[i])
+}
+
+func (m *Minibuffer) selectMatch(diff int) {
+	if len(m.Matches) == 0 {
+		return
+	}
+	m.Selected = (m.Selected + diff + len(m.Matches)) % len(m.Matches)
+}
+
+func (m *Minibuffer) updateMatches() {
+	m.Matches = nil
+	if m.Complete != nil {
+		m.Matches = m.Complete(string(m.Input))
+	}
+	m.Selected = 0
+}
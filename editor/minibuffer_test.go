@@ -0,0 +1,128 @@
+package editor_test
+
+import (
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/mizuochikeita/re/editor"
+)
+
+func TestMinibuffer(t *testing.T) {
+	keys := func(s string) []editor.Key {
+		var ks []editor.Key
+		for _, r := range s {
+			ks = append(ks, editor.Key{Value: r})
+		}
+		return ks
+	}
+	up := editor.Key{EscapedSequence: []rune{'A'}}
+	down := editor.Key{EscapedSequence: []rune{'B'}}
+
+	t.Run("HandleKey()", func(t *testing.T) {
+		tests := []struct {
+			desc       string
+			keys       []editor.Key
+			wantInput  string
+			wantCursor int
+		}{
+			{
+				desc:       "insert",
+				keys:       keys("abc"),
+				wantInput:  "abc",
+				wantCursor: 3,
+			},
+			{
+				desc:       "insert in the middle",
+				keys:       keys("ac\x02b"),
+				wantInput:  "abc",
+				wantCursor: 2,
+			},
+			{
+				desc:       "delete backward",
+				keys:       keys("abc\x7f"),
+				wantInput:  "ab",
+				wantCursor: 2,
+			},
+			{
+				desc:       "kill to end",
+				keys:       keys("abc\x01\x06\x0b"),
+				wantInput:  "a",
+				wantCursor: 1,
+			},
+			{
+				desc:       "kill to beginning",
+				keys:       keys("abc\x02\x15"),
+				wantInput:  "c",
+				wantCursor: 0,
+			},
+		}
+		for _, tt := range tests {
+			m := editor.NewMinibuffer("> ", nil, nil, func(string, bool) {})
+			for _, k := range tt.keys {
+				m.HandleKey(k)
+			}
+			if diff := cmp.Diff(tt.wantInput, string(m.Input)); diff != "" {
+				t.Errorf("%s: %s", tt.desc, diff)
+			}
+			if diff := cmp.Diff(tt.wantCursor, m.Cursor); diff != "" {
+				t.Errorf("%s: %s", tt.desc, diff)
+			}
+		}
+	})
+
+	t.Run("accept and cancel", func(t *testing.T) {
+		tests := []struct {
+			desc        string
+			acceptMatch bool
+			keys        []editor.Key
+			wantValue   string
+			wantOk      bool
+		}{
+			{desc: "accept input", keys: keys("ma\r"), wantValue: "ma", wantOk: true},
+			{desc: "accept match", acceptMatch: true, keys: keys("ma\r"), wantValue: "main.go", wantOk: true},
+			{desc: "select next match", acceptMatch: true, keys: keys("go\x0e\r"), wantValue: "key.go", wantOk: true},
+			{desc: "complete", keys: keys("ke\t\r"), wantValue: "key.go", wantOk: true},
+			{desc: "cancel", keys: keys("ma\x07"), wantValue: "", wantOk: false},
+		}
+		for _, tt := range tests {
+			var gotValue string
+			var gotOk bool
+			complete := editor.FuzzyCompleter([]string{"main.go", "key.go"})
+			m := editor.NewMinibuffer("> ", complete, nil, func(s string, ok bool) {
+				gotValue, gotOk = s, ok
+			})
+			m.AcceptMatch = tt.acceptMatch
+			for _, k := range tt.keys {
+				m.HandleKey(k)
+			}
+			if diff := cmp.Diff(tt.wantValue, gotValue); diff != "" {
+				t.Errorf("%s: %s", tt.desc, diff)
+			}
+			if diff := cmp.Diff(tt.wantOk, gotOk); diff != "" {
+				t.Errorf("%s: %s", tt.desc, diff)
+			}
+		}
+	})
+
+	t.Run("history", func(t *testing.T) {
+		h := &editor.History{}
+		for _, in := range []string{"a", "b", "b"} {
+			m := editor.NewMinibuffer("> ", nil, h, func(string, bool) {})
+			for _, k := range keys(in + "\r") {
+				m.HandleKey(k)
+			}
+		}
+		if diff := cmp.Diff([]string{"a", "b"}, h.Entries); diff != "" {
+			t.Error(diff)
+		}
+		m := editor.NewMinibuffer("> ", nil, h, func(string, bool) {})
+		var got []string
+		for _, k := range []editor.Key{up, up, up, down, down} {
+			m.HandleKey(k)
+			got = append(got, string(m.Input))
+		}
+		if diff := cmp.Diff([]string{"b", "a", "a", "b", ""}, got); diff != "" {
+			t.Error(diff)
+		}
+	})
+}
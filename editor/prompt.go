@@ -0,0 +1,113 @@
+package editor
+
+// Prompt reads a line in the minibuffer. done is called with ok false if the input is canceled.
+// Prompts with the same history name share their input history.
+func (e *Editor) Prompt(history, prompt string, complete Completer, done func(s string, ok bool)) *Minibuffer {
+	h, ok := e.Histories[history]
+	if !ok {
+		h = &History{}
+		e.Histories[history] = h
+	}
+	e.Minibuffer = NewMinibuffer(prompt, complete, h, func(s string, ok bool) {
+		e.Minibuffer = nil
+		done(s, ok)
+	})
+	e.Minibuffer.CancelOnEscape = e.Vi != nil
+	return e.Minibuffer
+}
+
+func (e *Editor) PromptOpenFile() {
+	e.Prompt("file", "Find file: ", FileCompleter, func(path string, ok bool) {
+		if !ok || path == "" {
+			return
+		}
+		e.Error(e.OpenFile(path))
+	})
+}
+
+func (e *Editor) PromptSaveAs() {
+	e.Prompt("file", "Write file: ", FileCompleter, func(path string, ok bool) {
+		if !ok || path == "" {
+			return
+		}
+		e.setPath(e.Buffer, path)
+		e.SaveBuffer()
+	})
+}
+
+// SaveBuffer saves the current buffer, asking for a file name if it has none. The buffer is
+// formatted first if its file type has a formatter, and saved as it is if the formatter fails.
+func (e *Editor) SaveBuffer() {
+	if e.Buffer.Path == "" {
+		e.PromptSaveAs()
+		return
+	}
+	if e.Buffer.TrimTrailingWhitespace && !e.Buffer.ReadOnly && !e.Buffer.HexMode {
+		e.trimTrailingWhitespace(e.Buffer)
+	}
+	stderr, formatErr := e.format(e.Buffer)
+	if err := e.Buffer.Save(); err != nil {
+		e.Error(err)
+		return
+	}
+	e.Infof("Wrote %s", e.Buffer.Path)
+	e.Error(e.saveMarks(e.Buffer))
+	// The output of the formatter is shown rather than the file written.
+	if stderr != "" {
+		e.Warnf("%s", stderr)
+	}
+	e.Error(formatErr)
+}
+
+func (e *Editor) PromptSwitchBuffer() {
+	m := e.Prompt("buffer", "Switch to buffer: ", FuzzyCompleter(e.BufferNames()), func(name string, ok bool) {
+		if !ok {
+			return
+		}
+		if b := e.FindBuffer(name); b != nil {
+			e.SwitchBuffer(b)
+		}
+	})
+	m.AcceptMatch = true
+}
+
+func (e *Editor) PromptCloseBuffer() {
+	names := append([]string{e.Buffer.Name}, e.BufferNames()...)
+	m := e.Prompt("buffer", "Kill buffer: ", FuzzyCompleter(names), func(name string, ok bool) {
+		if !ok {
+			return
+		}
+		if name == "" {
+			name = e.Buffer.Name
+		}
+		b := e.FindBuffer(name)
+		switch {
+		case b == nil:
+		case b.Dirty && b.Large == nil && b != e.Output:
+			e.confirmDiscard(b, "killing it", func() { e.CloseBuffer(b) })
+		default:
+			e.CloseBuffer(b)
+		}
+	})
+	m.AcceptMatch = true
+}
+
+func (e *Editor) PromptSetEncoding() {
+	m := e.Prompt("encoding", "Set file encoding: ", FuzzyCompleter(Encodings), func(enc string, ok bool) {
+		if !ok || enc == "" {
+			return
+		}
+		e.Error(e.SetEncoding(enc))
+	})
+	m.AcceptMatch = true
+}
+
+func (e *Editor) PromptReopenWithEncoding() {
+	m := e.Prompt("encoding", "Reopen with encoding: ", FuzzyCompleter(Encodings), func(enc string, ok bool) {
+		if !ok || enc == "" {
+			return
+		}
+		e.Error(e.ReopenWithEncoding(enc))
+	})
+	m.AcceptMatch = true
+}
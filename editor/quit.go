@@ -0,0 +1,139 @@
+package editor
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// ModifiedBuffers returns the buffers with changes not saved.
+func (e *Editor) ModifiedBuffers() []*Buffer {
+	var bs []*Buffer
+	for _, b := range e.Buffers {
+		if b.Dirty && b.Large == nil {
+			bs = append(bs, b)
+		}
+	}
+	return bs
+}
+
+// Quit exits the editor, first asking for each modified buffer except Output whether to save it,
+// discard its changes or cancel the exit.
+func (e *Editor) Quit() {
+	var bs []*Buffer
+	for _, b := range e.ModifiedBuffers() {
+		if b != e.Output {
+			bs = append(bs, b)
+		}
+	}
+	e.confirmQuit(bs)
+}
+
+func (e *Editor) confirmQuit(bs []*Buffer) {
+	if len(bs) == 0 {
+		e.quit = true
+		return
+	}
+	e.confirmDiscard(bs[0], "quitting", func() { e.confirmQuit(bs[1:]) })
+}
+
+// confirmDiscard asks whether to save the modified buffer b before doing, discard its changes or
+// cancel, and runs next unless it is cancelled or b cannot be saved.
+func (e *Editor) confirmDiscard(b *Buffer, doing string, next func()) {
+	// The buffer is shown to tell what is saved or discarded.
+	e.SwitchBuffer(b)
+	e.Prompt("confirm", fmt.Sprintf("Save %s before %s? (save, discard, cancel) ", b.Name, doing), nil, func(s string, ok bool) {
+		answer := strings.ToLower(strings.TrimSpace(s))
+		switch {
+		case !ok || answer == "" || strings.HasPrefix("cancel", answer):
+		case strings.HasPrefix("save", answer):
+			if b.Path != "" {
+				e.saveAndRun(b, next)
+				return
+			}
+			e.Prompt("file", "Write file: ", FileCompleter, func(path string, ok bool) {
+				if !ok || path == "" {
+					return
+				}
+				e.setPath(b, path)
+				e.saveAndRun(b, next)
+			})
+		case strings.HasPrefix("discard", answer):
+			next()
+		default:
+			e.confirmDiscard(b, doing, next)
+		}
+	})
+}
+
+// saveAndRun saves b and runs next, or stops if b cannot be saved.
+func (e *Editor) saveAndRun(b *Buffer, next func()) {
+	e.SaveBuffer()
+	if !b.Dirty {
+		next()
+	}
+}
+
+// emergencySuffix is added to the names of the files the modified buffers are written to when the
+// editor is killed.
+const emergencySuffix = ".save"
+
+// SaveEmergencyCopies writes the modified buffers to new files, for their changes not to be lost
+// when the editor is killed, and returns their paths. A copy is written next to the file of the
+// buffer, or in the state directory for a buffer without a file or a directory which cannot be
+// written. The files of the buffers are left as they are.
+func (e *Editor) SaveEmergencyCopies() ([]string, error) {
+	var paths []string
+	var errs []string
+	for _, b := range e.ModifiedBuffers() {
+		var path string
+		err := errors.New("buffer has no file")
+		if b.Path != "" {
+			path, err = writeEmergencyCopy(b, b.Path)
+		}
+		if err != nil && StateDir() != "" {
+			name := strings.Trim(b.Name, "*")
+			if name == "" {
+				name = "buffer"
+			}
+			if err = os.MkdirAll(StateDir(), 0700); err == nil {
+				path, err = writeEmergencyCopy(b, filepath.Join(StateDir(), filepath.Base(name)))
+			}
+		}
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", b.Name, err))
+			continue
+		}
+		paths = append(paths, path)
+	}
+	if len(errs) > 0 {
+		return paths, fmt.Errorf("cannot save copies of %s", strings.Join(errs, ", "))
+	}
+	return paths, nil
+}
+
+// writeEmergencyCopy writes b to a new file named after path with emergencySuffix, and a number
+// if the file exists, and returns its path.
+func writeEmergencyCopy(b *Buffer, path string) (string, error) {
+	for i := 0; ; i++ {
+		p := path + emergencySuffix
+		if i > 0 {
+			p += "." + strconv.Itoa(i)
+		}
+		f, err := os.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if errors.Is(err, os.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		if _, err := b.WriteTo(f); err != nil {
+			f.Close()
+			return "", err
+		}
+		return p, f.Close()
+	}
+}
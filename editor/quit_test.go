@@ -0,0 +1,162 @@
+package editor_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/mizuochikeita/re/editor"
+)
+
+func TestQuit(t *testing.T) {
+	typeKeys := func(e *editor.Editor, keys string, cancel func()) {
+		for _, r := range keys {
+			e.HandleKey(editor.Key{Value: r}, cancel)
+		}
+	}
+	readFile := func(t *testing.T, path string) string {
+		t.Helper()
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+
+	t.Run("Quit()", func(t *testing.T) {
+		tests := []struct {
+			desc     string
+			answers  string
+			wantA    string
+			wantB    string
+			wantQuit bool
+		}{
+			{desc: "save and discard", answers: "save\rd\r", wantA: "xa\n", wantB: "b\n", wantQuit: true},
+			{desc: "discard all", answers: "discard\rdiscard\r", wantA: "a\n", wantB: "b\n", wantQuit: true},
+			{desc: "cancel", answers: "s\rc\r", wantA: "xa\n", wantB: "b\n", wantQuit: false},
+			{desc: "cancel with ESC", answers: "\x1b", wantA: "a\n", wantB: "b\n", wantQuit: false},
+			{desc: "ask again", answers: "what\rd\rd\r", wantA: "a\n", wantB: "b\n", wantQuit: true},
+		}
+		for _, tt := range tests {
+			dir := t.TempDir()
+			a, b := filepath.Join(dir, "a.txt"), filepath.Join(dir, "b.txt")
+			for path, text := range map[string]string{a: "a\n", b: "b\n", filepath.Join(dir, "c.txt"): "c\n"} {
+				if err := os.WriteFile(path, []byte(text), 0644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			e := editor.New()
+			for _, path := range []string{a, b, filepath.Join(dir, "c.txt")} {
+				if err := e.OpenFile(path); err != nil {
+					t.Fatal(err)
+				}
+				e.Layout.Arrange(0, 0, 80, 24)
+				if path != filepath.Join(dir, "c.txt") {
+					e.InsertText("x")
+				}
+			}
+			quit := false
+			cancel := func() { quit = true }
+			typeKeys(e, "\x11", cancel) // C-q
+			typeKeys(e, tt.answers, cancel)
+			if diff := cmp.Diff(tt.wantA, readFile(t, a)); diff != "" {
+				t.Errorf("%s: %s", tt.desc, diff)
+			}
+			if diff := cmp.Diff(tt.wantB, readFile(t, b)); diff != "" {
+				t.Errorf("%s: %s", tt.desc, diff)
+			}
+			if diff := cmp.Diff(tt.wantQuit, quit); diff != "" {
+				t.Errorf("%s: %s", tt.desc, diff)
+			}
+		}
+	})
+
+	t.Run("Quit() with a buffer without a file", func(t *testing.T) {
+		e := editor.New()
+		e.AddBuffer(editor.NewBuffer("*scratch*"))
+		e.Layout.Arrange(0, 0, 80, 24)
+		e.InsertText("notes")
+		path := filepath.Join(t.TempDir(), "notes.txt")
+		quit := false
+		typeKeys(e, "\x11s\r"+path+"\r", func() { quit = true })
+		if diff := cmp.Diff("notes\n", readFile(t, path)); diff != "" {
+			t.Error(diff)
+		}
+		if diff := cmp.Diff(true, quit); diff != "" {
+			t.Error(diff)
+		}
+	})
+
+	t.Run("PromptCloseBuffer() with a modified buffer", func(t *testing.T) {
+		tests := []struct {
+			desc       string
+			answers    string
+			wantFile   string
+			wantClosed bool
+		}{
+			{desc: "save", answers: "s\r", wantFile: "xa\n", wantClosed: true},
+			{desc: "discard", answers: "d\r", wantFile: "a\n", wantClosed: true},
+			{desc: "cancel", answers: "c\r", wantFile: "a\n", wantClosed: false},
+		}
+		for _, tt := range tests {
+			path := filepath.Join(t.TempDir(), "a.txt")
+			if err := os.WriteFile(path, []byte("a\n"), 0644); err != nil {
+				t.Fatal(err)
+			}
+			e := editor.New()
+			if err := e.OpenFile(path); err != nil {
+				t.Fatal(err)
+			}
+			e.Layout.Arrange(0, 0, 80, 24)
+			e.InsertText("x")
+			typeKeys(e, "\x18k\r"+tt.answers, func() {}) // C-x k
+			if diff := cmp.Diff(tt.wantFile, readFile(t, path)); diff != "" {
+				t.Errorf("%s: %s", tt.desc, diff)
+			}
+			if diff := cmp.Diff(tt.wantClosed, e.FindBuffer("a.txt") == nil); diff != "" {
+				t.Errorf("%s: %s", tt.desc, diff)
+			}
+		}
+	})
+
+	t.Run("SaveEmergencyCopies()", func(t *testing.T) {
+		state := t.TempDir()
+		t.Setenv("XDG_STATE_HOME", state)
+		dir := t.TempDir()
+		a := filepath.Join(dir, "a.txt")
+		for path, text := range map[string]string{a: "a\n", a + ".save": "old\n", filepath.Join(dir, "b.txt"): "b\n"} {
+			if err := os.WriteFile(path, []byte(text), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		e := editor.New()
+		if err := e.OpenFile(a); err != nil {
+			t.Fatal(err)
+		}
+		e.Layout.Arrange(0, 0, 80, 24)
+		e.InsertText("x")
+		if err := e.OpenFile(filepath.Join(dir, "b.txt")); err != nil {
+			t.Fatal(err)
+		}
+		if err := e.OpenReader("*stdin*", strings.NewReader("in\n")); err != nil {
+			t.Fatal(err)
+		}
+		e.InsertText("y")
+
+		paths, err := e.SaveEmergencyCopies()
+		if err != nil {
+			t.Fatal(err)
+		}
+		stdin := filepath.Join(state, "re", "stdin.save")
+		if diff := cmp.Diff([]string{a + ".save.1", stdin}, paths); diff != "" {
+			t.Error(diff)
+		}
+		for path, want := range map[string]string{a: "a\n", a + ".save": "old\n", a + ".save.1": "xa\n", stdin: "yin\n"} {
+			if diff := cmp.Diff(want, readFile(t, path)); diff != "" {
+				t.Errorf("%s: %s", path, diff)
+			}
+		}
+	})
+}
@@ -0,0 +1,84 @@
+package editor
+
+import (
+	"fmt"
+	"unicode"
+)
+
+// Register holds a yanked or deleted text.
+type Register struct {
+	Text     string
+	Linewise bool
+}
+
+// isRegisterName reports whether r names a text register: a lowercase letter, or an uppercase
+// one to append to the register of the lowercase letter.
+func isRegisterName(r rune) bool {
+	return 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z'
+}
+
+// SetRegister stores r in the register name, or appends it for an uppercase name.
+func (e *Editor) SetRegister(name rune, r Register) error {
+	if !isRegisterName(name) {
+		return fmt.Errorf("invalid register: %q", name)
+	}
+	if unicode.IsUpper(name) {
+		name = unicode.ToLower(name)
+		old := e.Registers[name]
+		if old.Linewise && !r.Linewise {
+			r.Text += "\n"
+		} else if !old.Linewise && r.Linewise && old.Text != "" {
+			old.Text += "\n"
+		}
+		r = Register{Text: old.Text + r.Text, Linewise: old.Linewise || r.Linewise}
+	}
+	e.Registers[name] = r
+	return nil
+}
+
+// GetRegister returns the register name.
+func (e *Editor) GetRegister(name rune) (Register, error) {
+	if !isRegisterName(name) {
+		return Register{}, fmt.Errorf("invalid register: %q", name)
+	}
+	r, ok := e.Registers[unicode.ToLower(name)]
+	if !ok {
+		return Register{}, fmt.Errorf("register %c is empty", name)
+	}
+	return r, nil
+}
+
+// CopyLineToRegister stores the line of the cursor in the register name.
+func (e *Editor) CopyLineToRegister(name rune) error {
+	line, _ := e.Screen.Position()
+	if err := e.SetRegister(name, Register{Text: e.Buffer.Lines[line] + "\n", Linewise: true}); err != nil {
+		return err
+	}
+	e.Infof("Copied the line to register %c", name)
+	return nil
+}
+
+// InsertRegister inserts the text of the register name at the cursor.
+func (e *Editor) InsertRegister(name rune) error {
+	r, err := e.GetRegister(name)
+	if err != nil {
+		return err
+	}
+	e.InsertText(r.Text)
+	return nil
+}
+
+// promptName reads the single letter name of a mark or a register in the minibuffer.
+func (e *Editor) promptName(prompt string, done func(name rune) error) {
+	e.Prompt("name", prompt, nil, func(s string, ok bool) {
+		if !ok || s == "" {
+			return
+		}
+		rs := []rune(s)
+		if len(rs) != 1 {
+			e.Error(fmt.Errorf("not a single letter: %s", s))
+			return
+		}
+		e.Error(done(rs[0]))
+	})
+}
@@ -0,0 +1,67 @@
+package editor_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/mizuochikeita/re/editor"
+)
+
+func TestRegister(t *testing.T) {
+	t.Run("SetRegister()", func(t *testing.T) {
+		tests := []struct {
+			desc    string
+			sets    []editor.Register
+			names   string
+			want    editor.Register
+			wantErr bool
+		}{
+			{desc: "set", names: "a", sets: []editor.Register{{Text: "foo"}}, want: editor.Register{Text: "foo"}},
+			{desc: "replace", names: "aa", sets: []editor.Register{{Text: "foo"}, {Text: "bar"}}, want: editor.Register{Text: "bar"}},
+			{desc: "append", names: "aA", sets: []editor.Register{{Text: "foo"}, {Text: "bar"}}, want: editor.Register{Text: "foobar"}},
+			{desc: "append a line to text", names: "aA", sets: []editor.Register{{Text: "foo"}, {Text: "bar\n", Linewise: true}},
+				want: editor.Register{Text: "foo\nbar\n", Linewise: true}},
+			{desc: "append text to lines", names: "aA", sets: []editor.Register{{Text: "foo\n", Linewise: true}, {Text: "bar"}},
+				want: editor.Register{Text: "foo\nbar\n", Linewise: true}},
+			{desc: "invalid name", names: "1", sets: []editor.Register{{Text: "foo"}}, wantErr: true},
+		}
+		for _, tt := range tests {
+			e := editor.New()
+			var err error
+			for i, name := range tt.names {
+				if err = e.SetRegister(name, tt.sets[i]); err != nil {
+					break
+				}
+			}
+			if diff := cmp.Diff(tt.wantErr, err != nil); diff != "" {
+				t.Errorf("%s: %s", tt.desc, diff)
+			}
+			got, _ := e.GetRegister(rune(tt.names[0]))
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("%s: %s", tt.desc, diff)
+			}
+		}
+	})
+
+	t.Run("InsertRegister()", func(t *testing.T) {
+		e := editor.New()
+		if err := e.OpenReader("test", strings.NewReader("one\ntwo\n")); err != nil {
+			t.Fatal(err)
+		}
+		e.Layout.Arrange(0, 0, 80, 24)
+		if err := e.CopyLineToRegister('s'); err != nil {
+			t.Fatal(err)
+		}
+		e.Screen.SetPosition(1, 0)
+		if err := e.InsertRegister('s'); err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff([]string{"one", "one", "two"}, e.Buffer.Lines); diff != "" {
+			t.Error(diff)
+		}
+		if err := e.InsertRegister('t'); err == nil {
+			t.Error("empty register was inserted")
+		}
+	})
+}
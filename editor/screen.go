@@ -1,7 +1,11 @@
 package editor
 
 import (
+	"fmt"
+	"sort"
+	"strings"
 	"unicode"
+	"unicode/utf8"
 )
 
 type Screen struct {
@@ -11,73 +15,350 @@ type Screen struct {
 	Cx      int
 	Cy      int
 	Rows    []*ScreenRow
+	// HexBytes is the number of bytes in a row of the hex layout, or 0 for text.
+	HexBytes int
+	// Nibble is 1 when the cursor is on the low digit of a byte in the hex layout.
+	// Moving the cursor resets it.
+	Nibble int
+	// Hscroll is the first column shown when lines are not wrapped.
+	Hscroll int
+	// NoWrap keeps each buffer line in a single row, scrolled horizontally to the cursor.
+	NoWrap bool
+	// Wrap sets how lines are wrapped otherwise.
+	Wrap WrapOptions
+	// Gutter is the width of the columns left of the text for line numbers.
+	Gutter int
+	// TabWidth is the number of columns between tab stops, or 0 for 8.
+	TabWidth int
+
+	// lineRows holds the index of the first row of each buffer line.
+	lineRows []int
 }
 
 type ScreenRow struct {
+	// Prefix is shown before Body on the rows continuing a wrapped line.
+	Prefix   string
 	Body     string
 	Len      int
 	ScreenXs []int
 }
 
+// WrapOptions set how lines longer than the width of the screen are wrapped.
+type WrapOptions struct {
+	// Word breaks lines at word boundaries rather than at any character.
+	Word bool
+	// Indent shows the rows continuing a line at its indentation.
+	Indent bool
+	// Marker is shown at the start of the rows continuing a line.
+	Marker string
+}
+
+// tabWidth returns the number of columns between tab stops.
+func (s *Screen) tabWidth() int {
+	if s.TabWidth <= 0 {
+		return defaultIndentWidth
+	}
+	return s.TabWidth
+}
+
+// runeWidth returns the number of columns r takes at the column x of the text of a row: up to
+// the next tab stop for a tab, but not beyond room columns if room is positive.
+func (s *Screen) runeWidth(r rune, x, room int) int {
+	if r != '\t' {
+		return RuneWidth(r)
+	}
+	n := s.tabWidth() - x%s.tabWidth()
+	if room > 0 && n > room {
+		n = room
+	}
+	return n
+}
+
+// RuneWidth returns the number of columns the rune k of the row r takes.
+func (s *Screen) RuneWidth(r, k int) int {
+	row := s.Rows[r]
+	if k+1 < row.Len {
+		return row.ScreenXs[k+1] - row.ScreenXs[k]
+	}
+	x := row.ScreenXs[k]
+	room := 0
+	if !s.NoWrap && s.HexBytes == 0 {
+		room = s.Width - x
+	}
+	c, _ := utf8.DecodeRuneInString(row.Body[runeIndex(row.Body, k):])
+	return s.runeWidth(c, x-StringWidth(row.Prefix), room)
+}
+
 func (r *ScreenRow) UpdateXs() {
 	var xs []int
 	x := 0
 	for _, c := range r.Body {
 		xs = append(xs, x)
-		if c <= unicode.MaxASCII {
-			x++
-		} else {
-			x += 2
-		}
+		x += RuneWidth(c)
 	}
 	r.ScreenXs = xs
 }
 
 func (s *Screen) Update(buffer []string) {
 	var (
-		rows []*ScreenRow
+		rows     []*ScreenRow
+		lineRows []int
 	)
 	for _, row := range buffer {
-		rr := []rune(row)
-		rr = append(rr, ' ') // Add a space expressing end of the row
-		l := 0
-		w := 0
-		var screenXs []int
-		var nw int
-		if rr[0] <= unicode.MaxASCII {
-			nw = 1
-		} else {
-			nw = 2
-		}
-		for i := 0; i < len(rr); i++ {
-			screenXs = append(screenXs, w)
-			w = nw
-			if i < len(rr)-1 {
-				if rr[i+1] <= unicode.MaxASCII {
-					nw = w + 1
-				} else {
-					nw = w + 2
-				}
-				if nw > s.Width {
-					rows = append(rows, &ScreenRow{
-						Len:      len(rr[l : i+1]),
-						Body:     string(rr[l : i+1]),
-						ScreenXs: screenXs,
-					})
-					l = i + 1
-					nw = nw - w
-					w = 0
-					screenXs = nil
-				}
+		lineRows = append(lineRows, len(rows))
+		rows = append(rows, s.wrap(row)...)
+	}
+	s.Rows = rows
+	s.lineRows = lineRows
+	s.HexBytes = 0
+	if !s.NoWrap {
+		s.Hscroll = 0
+	}
+}
+
+// UpdateLines rewraps only the lines replaced by the change c, which has been applied to buffer.
+func (s *Screen) UpdateLines(buffer []string, c Change) {
+	if s.HexBytes > 0 || c.OldEndLine >= len(s.lineRows) || c.NewEndLine >= len(buffer) ||
+		len(buffer)-len(s.lineRows) != c.NewEndLine-c.OldEndLine {
+		s.Update(buffer)
+		return
+	}
+	start := s.lineRows[c.Line]
+	end := len(s.Rows)
+	if c.OldEndLine+1 < len(s.lineRows) {
+		end = s.lineRows[c.OldEndLine+1]
+	}
+	var rows []*ScreenRow
+	lineRows := s.lineRows[:c.Line:c.Line]
+	if c.Line == c.OldEndLine && c.Line == c.NewEndLine {
+		// Only the rows from the one with the change are wrapped again, or from the row before
+		// if the change is at the start of a row, because a row ends where the next character does not fit.
+		lineRows = append(lineRows, start)
+		col := 0
+		// A change can move a word to the previous rows when they break at word boundaries.
+		for !s.Wrap.Word && start+1 < end && col+s.Rows[start].Len < c.Col {
+			col += s.Rows[start].Len
+			start++
+		}
+		line := buffer[c.Line]
+		rows = s.wrapFrom(line, runeIndex(line, col))
+	} else {
+		for _, row := range buffer[c.Line : c.NewEndLine+1] {
+			lineRows = append(lineRows, start+len(rows))
+			rows = append(rows, s.wrap(row)...)
+		}
+	}
+	delta := len(rows) - (end - start)
+	if delta == 0 && c.Line == c.OldEndLine && c.Line == c.NewEndLine {
+		copy(s.Rows[start:], rows)
+		return
+	}
+	for _, r := range s.lineRows[c.OldEndLine+1:] {
+		lineRows = append(lineRows, r+delta)
+	}
+	s.Rows = append(append(append(make([]*ScreenRow, 0, len(s.Rows)+delta), s.Rows[:start]...), rows...), s.Rows[end:]...)
+	s.lineRows = lineRows
+}
+
+// wrap splits a buffer line into rows fitting in the width of the screen.
+// Lines are not wrapped before the screen has a width, or with NoWrap.
+func (s *Screen) wrap(line string) []*ScreenRow {
+	return s.wrapFrom(line, 0)
+}
+
+// wrapFrom wraps the text of line from the byte index start, where a row of the line starts.
+func (s *Screen) wrapFrom(line string, start int) []*ScreenRow {
+	row := line[start:] + " " // Add a space expressing end of the row
+	n := utf8.RuneCountInString(row)
+	width := s.Width
+	if s.NoWrap {
+		width = 0
+	}
+	prefix := s.continuationPrefix(line, width)
+	prefixWidth := StringWidth(prefix)
+	// The rows of a line share their allocations, which matters for very long lines.
+	xs := make([]int, n)
+	var block []ScreenRow
+	if width > 0 {
+		block = make([]ScreenRow, 0, n/width+1)
+	}
+	rowPrefix := ""
+	if start > 0 {
+		rowPrefix = prefix
+	}
+	// The first rune of the current row, its byte index, the width so far, and the width of its prefix.
+	l, rowStart, w, pw := 0, 0, StringWidth(rowPrefix), StringWidth(rowPrefix)
+	// runeWidth returns the width of r at the end of the row.
+	runeWidth := func(r rune) int {
+		room := 0
+		if width > 0 {
+			room = width - w
+		}
+		return s.runeWidth(r, w-pw, room)
+	}
+	// The last place in the row where it can be broken at a word boundary.
+	brk, brkByte := -1, 0
+	var lb lineBreaker
+	i := 0
+	for bi, r := range row {
+		rw := runeWidth(r)
+		if s.Wrap.Word && lb.next(r) && i > l {
+			brk, brkByte = i, bi
+		}
+		for width > 0 && w+rw > width && i > l {
+			at, atByte := i, bi
+			// Spaces do not fit at the end of the row, and start the next one.
+			if s.Wrap.Word && brk > l && lineBreakClass(r) != breakSP {
+				at, atByte = brk, brkByte
+			}
+			block = append(block, ScreenRow{
+				Prefix:   rowPrefix,
+				Len:      at - l,
+				Body:     row[rowStart:atByte],
+				ScreenXs: xs[l:at:at],
+			})
+			// The runes after the break move to the next row, where tabs may take another width.
+			l, rowStart, rowPrefix, brk = at, atByte, prefix, -1
+			w, pw = prefixWidth, prefixWidth
+			k := at
+			for _, c := range row[atByte:bi] {
+				xs[k] = w
+				w += runeWidth(c)
+				k++
+			}
+			rw = runeWidth(r)
+		}
+		xs[i] = w
+		w += rw
+		i++
+	}
+	block = append(block, ScreenRow{
+		Prefix:   rowPrefix,
+		Len:      n - l,
+		Body:     row[rowStart:],
+		ScreenXs: xs[l:],
+	})
+	rows := make([]*ScreenRow, len(block))
+	for i := range block {
+		rows[i] = &block[i]
+	}
+	return rows
+}
+
+// continuationPrefix returns the text shown before the rows continuing line: its indentation
+// with Wrap.Indent, and Wrap.Marker. The indentation is dropped if it takes half of the width.
+func (s *Screen) continuationPrefix(line string, width int) string {
+	if width <= 0 {
+		return ""
+	}
+	indent := ""
+	if s.Wrap.Indent {
+		n := 0
+		for _, r := range line {
+			if r != ' ' && r != '\t' {
+				break
 			}
+			n++
+		}
+		indent = strings.Repeat(" ", indentColumn(line[:n], s.tabWidth()))
+	}
+	if StringWidth(indent+s.Wrap.Marker) >= width/2 {
+		indent = ""
+	}
+	if StringWidth(indent+s.Wrap.Marker) >= width {
+		return ""
+	}
+	return indent + s.Wrap.Marker
+}
+
+// hexRowWidth returns the width of a row of the hex layout showing n bytes:
+// the offset, the bytes in hex and the bytes as characters.
+func hexRowWidth(n int) int {
+	return 10 + 3*n + 1 + n
+}
+
+// UpdateHex lays out data, which starts at offset in its file, as a hex dump with a row per
+// HexBytes bytes. Each row is a line, and a column is a byte. The last row has a position after the data.
+func (s *Screen) UpdateHex(data []byte, offset int64) {
+	n := 4
+	for _, m := range []int{16, 8} {
+		if hexRowWidth(m) <= s.Width {
+			n = m
+			break
 		}
-		rows = append(rows, &ScreenRow{
-			Len:      len(rr[l:]),
-			Body:     string(rr[l:]),
-			ScreenXs: screenXs,
-		})
+	}
+	var (
+		rows     []*ScreenRow
+		lineRows []int
+	)
+	for i := 0; i <= len(data); i += n {
+		lineRows = append(lineRows, len(rows))
+		rows = append(rows, hexRow(data, offset, i, n))
 	}
 	s.Rows = rows
+	s.lineRows = lineRows
+	s.HexBytes = n
+	s.Hscroll = 0
+}
+
+// UpdateHexRow lays out the row r of the hex layout again after its bytes are overwritten.
+func (s *Screen) UpdateHexRow(data []byte, offset int64, r int) {
+	if s.HexBytes == 0 || r >= len(s.Rows) {
+		s.UpdateHex(data, offset)
+		return
+	}
+	s.Rows[r] = hexRow(data, offset, r*s.HexBytes, s.HexBytes)
+}
+
+// hexRow returns the row of the hex layout showing the n bytes of data from i: their offset,
+// their values, and their characters in ASCII or UTF-8, with the bytes after the first one of a
+// UTF-8 character shown as spaces so that the columns stay aligned.
+func hexRow(data []byte, offset int64, i, n int) *ScreenRow {
+	end := i + n
+	if end > len(data) {
+		end = len(data)
+	}
+	bs := data[i:end]
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%08x  ", offset+int64(i))
+	xs := make([]int, 0, n+1)
+	for j := 0; j < n; j++ {
+		if j < len(bs) {
+			xs = append(xs, sb.Len())
+			fmt.Fprintf(&sb, "%02x ", bs[j])
+		} else {
+			sb.WriteString("   ")
+		}
+	}
+	sb.WriteByte(' ')
+	for j := 0; j < len(bs); {
+		c := bs[j]
+		if c < utf8.RuneSelf {
+			if c < 0x20 || c > 0x7e {
+				c = '.'
+			}
+			sb.WriteByte(c)
+			j++
+			continue
+		}
+		r, size := utf8.DecodeRune(bs[j:])
+		if r == utf8.RuneError || !unicode.IsPrint(r) || unicode.Is(unicode.M, r) {
+			sb.WriteByte('.')
+			j++
+			continue
+		}
+		sb.WriteRune(r)
+		sb.WriteString(strings.Repeat(" ", size-RuneWidth(r)))
+		j += size
+	}
+	if end == len(data) && len(bs) < n {
+		xs = append(xs, 10+3*len(bs)) // end of the data
+	}
+	return &ScreenRow{
+		Body:     sb.String(),
+		Len:      len(xs),
+		ScreenXs: xs,
+	}
 }
 
 func (s *Screen) Scroll(diff int) {
@@ -94,6 +375,12 @@ func (s *Screen) Scroll(diff int) {
 	s.Vscroll = v
 }
 
+// CenterCursor scrolls the cursor row to the middle of the screen, as far as there are rows.
+func (s *Screen) CenterCursor() {
+	s.Vscroll = s.Cy - s.Height/2
+	s.Scroll(0)
+}
+
 func (s *Screen) View() []*ScreenRow {
 	bottom := s.Vscroll + s.Height
 	if bottom > len(s.Rows) {
@@ -103,6 +390,7 @@ func (s *Screen) View() []*ScreenRow {
 }
 
 func (s *Screen) MoveCursorHorizontally(diff int) {
+	s.Nibble = 0
 	if diff > 0 {
 		rest := s.Rows[s.Cy].Len - s.Cx - 1
 		if diff < rest {
@@ -133,6 +421,7 @@ func (s *Screen) MoveCursorHorizontally(diff int) {
 }
 
 func (s *Screen) MoveCursorVertically(diff int) {
+	s.Nibble = 0
 	origSx := 0
 	if s.Rows[s.Cy].Len > 0 {
 		origSx = s.Rows[s.Cy].ScreenXs[s.Cx]
@@ -165,7 +454,141 @@ func (s *Screen) MoveCursorVertically(diff int) {
 }
 
 func (s *Screen) CursorPosition() (int, int) {
-	x := s.Rows[s.Cy].ScreenXs[s.Cx]
+	x := s.Gutter + s.Rows[s.Cy].ScreenXs[s.Cx] + s.Nibble - s.Hscroll
 	y := s.Cy - s.Vscroll
 	return x, y
 }
+
+// Position returns the cursor position as a buffer line and a rune offset in it.
+func (s *Screen) Position() (int, int) {
+	if len(s.lineRows) == 0 {
+		return 0, 0
+	}
+	line, col := s.RowPosition(s.Cy)
+	return line, col + s.Cx
+}
+
+// RowPosition returns the buffer line of the row r and the rune offset of the row in the line.
+func (s *Screen) RowPosition(r int) (int, int) {
+	line, _ := s.RowLine(r)
+	col := 0
+	for i := s.lineRows[line]; i < r; i++ {
+		col += s.Rows[i].Len
+	}
+	return line, col
+}
+
+// RowLine returns the buffer line of the row r and whether r is the first row of the line.
+func (s *Screen) RowLine(r int) (int, bool) {
+	line := sort.Search(len(s.lineRows), func(i int) bool {
+		return s.lineRows[i] > r
+	}) - 1
+	return line, s.lineRows[line] == r
+}
+
+// LineEnd returns the offset of the last position in the line, which is after its text.
+func (s *Screen) LineEnd(line int) int {
+	end := len(s.Rows)
+	if line+1 < len(s.lineRows) {
+		end = s.lineRows[line+1]
+	}
+	n := 0
+	for _, r := range s.Rows[s.lineRows[line]:end] {
+		n += r.Len
+	}
+	return n - 1
+}
+
+// SetPosition moves the cursor to the rune offset col in the buffer line and scrolls it into view.
+func (s *Screen) SetPosition(line, col int) {
+	if len(s.lineRows) == 0 {
+		return
+	}
+	if line < 0 {
+		line = 0
+	}
+	if line >= len(s.lineRows) {
+		line = len(s.lineRows) - 1
+	}
+	if col < 0 {
+		col = 0
+	}
+	end := len(s.Rows)
+	if line+1 < len(s.lineRows) {
+		end = s.lineRows[line+1]
+	}
+	r := s.lineRows[line]
+	for r < end-1 && col >= s.Rows[r].Len {
+		col -= s.Rows[r].Len
+		r++
+	}
+	if col >= s.Rows[r].Len {
+		col = s.Rows[r].Len - 1
+	}
+	s.Cy = r
+	s.Cx = col
+	s.Nibble = 0
+	s.ScrollToCursor()
+}
+
+// ScrollToCursor adjusts Vscroll so that the cursor row is visible, and Hscroll so that the
+// cursor column is with NoWrap, by half the width to keep some context around it.
+func (s *Screen) ScrollToCursor() {
+	if s.Cy < s.Vscroll {
+		s.Vscroll = s.Cy
+	}
+	if s.Height > 0 && s.Cy >= s.Vscroll+s.Height {
+		s.Vscroll = s.Cy - s.Height + 1
+	}
+	if !s.NoWrap || s.HexBytes > 0 || s.Width <= 2 || s.Cy >= len(s.Rows) {
+		return
+	}
+	row := s.Rows[s.Cy]
+	x := row.ScreenXs[s.Cx]
+	rw := 1
+	if s.Cx+1 < row.Len {
+		rw = row.ScreenXs[s.Cx+1] - x
+	}
+	left, right := s.Hscroll, s.Hscroll+s.Width-1 // the last column is for the overflow indicator
+	if s.Hscroll > 0 {
+		left++
+	}
+	if x < left || x+rw > right {
+		s.Hscroll = x - s.Width/2
+		if s.Hscroll < 0 {
+			s.Hscroll = 0
+		}
+	}
+}
+
+// RowSpan returns the runes of the row r shown with the horizontal scroll, from first up to end,
+// and whether the row has text hidden on the left and on the right. Overflow indicators are
+// shown instead of the text in the first and the last columns then.
+func (s *Screen) RowSpan(r int) (first, end int, left, right bool) {
+	row := s.Rows[r]
+	if !s.NoWrap || s.HexBytes > 0 || s.Width <= 0 {
+		return 0, row.Len, false, false
+	}
+	xs := row.ScreenXs
+	textEnd := xs[row.Len-1] // the width of the text before the end of the row
+	left = s.Hscroll > 0 && textEnd > 0
+	right = textEnd > s.Hscroll+s.Width
+	from, to := s.Hscroll, s.Hscroll+s.Width
+	if left {
+		from++
+	}
+	if right {
+		to--
+	}
+	first = sort.SearchInts(xs[:row.Len], from)
+	for end = first; end < row.Len; end++ {
+		next := xs[end] + 1 // the end of row
+		if end+1 < row.Len {
+			next = xs[end+1]
+		}
+		if next > to {
+			break
+		}
+	}
+	return first, end, left, right
+}
@@ -3,6 +3,7 @@ package editor_test
 import (
 	"fmt"
 	"testing"
+	"unicode/utf8"
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/mizuochikeita/re/editor"
@@ -99,6 +100,357 @@ func TestScreen(t *testing.T) {
 		}
 	})
 
+	t.Run("UpdateLines()", func(t *testing.T) {
+		tests := []struct {
+			desc string
+			wrap editor.WrapOptions
+			edit func(b *editor.Buffer) editor.Change
+		}{
+			{
+				desc: "insert in a wrapped line",
+				edit: func(b *editor.Buffer) editor.Change { return b.Insert(1, 5, "xyzあ") },
+			},
+			{
+				desc: "insert at the start of a row",
+				edit: func(b *editor.Buffer) editor.Change { return b.Insert(1, 4, "x") },
+			},
+			{
+				desc: "insert with a marker",
+				wrap: editor.WrapOptions{Marker: ">"},
+				edit: func(b *editor.Buffer) editor.Change { return b.Insert(1, 6, "x") },
+			},
+			{
+				desc: "insert a word break",
+				wrap: editor.WrapOptions{Word: true},
+				edit: func(b *editor.Buffer) editor.Change { return b.Insert(1, 6, " ") },
+			},
+			{
+				desc: "insert lines",
+				edit: func(b *editor.Buffer) editor.Change { return b.Insert(0, 1, "x\nyz\n") },
+			},
+			{
+				desc: "delete a character",
+				edit: func(b *editor.Buffer) editor.Change {
+					_, c := b.Delete(1, 3, 1, 4)
+					return c
+				},
+			},
+			{
+				desc: "join lines",
+				edit: func(b *editor.Buffer) editor.Change {
+					_, c := b.Delete(0, 2, 2, 1)
+					return c
+				},
+			},
+		}
+		for _, tt := range tests {
+			b := editor.NewBuffer("test")
+			b.Lines = []string{"abc", "defghijklmnあい", "op", ""}
+			sc := &editor.Screen{Width: 4, Wrap: tt.wrap}
+			sc.Update(b.Lines)
+			sc.UpdateLines(b.Lines, tt.edit(b))
+			want := &editor.Screen{Width: 4, Wrap: tt.wrap}
+			want.Update(b.Lines)
+			if diff := cmp.Diff(want.Rows, sc.Rows); diff != "" {
+				t.Errorf("%s: %s", tt.desc, diff)
+			}
+			for line := range b.Lines {
+				sc.SetPosition(line, 0)
+				want.SetPosition(line, 0)
+				if diff := cmp.Diff(want.Cy, sc.Cy); diff != "" {
+					t.Errorf("%s: line %d: %s", tt.desc, line, diff)
+				}
+			}
+		}
+	})
+
+	t.Run("UpdateHex()", func(t *testing.T) {
+		sc := &editor.Screen{Width: 43}
+		sc.UpdateHex([]byte("abcdefghij\x00"), 0x10)
+		var got []string
+		for _, r := range sc.Rows {
+			got = append(got, r.Body)
+		}
+		want := []string{
+			"00000010  61 62 63 64 65 66 67 68  abcdefgh",
+			"00000018  69 6a 00                 ij.",
+		}
+		if diff := cmp.Diff(want, got); diff != "" {
+			t.Error(diff)
+		}
+		if diff := cmp.Diff(8, sc.HexBytes); diff != "" {
+			t.Error(diff)
+		}
+
+		sc.UpdateHex([]byte("aあ\xe3\x81"), 0)
+		if diff := cmp.Diff("00000000  61 e3 81 82 e3 81        aあ ..", sc.Rows[0].Body); diff != "" {
+			t.Error(diff)
+		}
+	})
+
+	t.Run("Update() with WrapOptions", func(t *testing.T) {
+		tests := []struct {
+			desc  string
+			width int
+			wrap  editor.WrapOptions
+			line  string
+			want  []string
+		}{
+			{
+				desc:  "breaks after spaces",
+				width: 9,
+				wrap:  editor.WrapOptions{Word: true},
+				line:  "hello wide world",
+				want:  []string{"hello ", "wide ", "world "},
+			},
+			{
+				desc:  "breaks long words anywhere",
+				width: 4,
+				wrap:  editor.WrapOptions{Word: true},
+				line:  "a abcdefg",
+				want:  []string{"a ", "abcd", "efg "},
+			},
+			{
+				desc:  "starts a row with a space which does not fit",
+				width: 5,
+				wrap:  editor.WrapOptions{Word: true},
+				line:  "hello world",
+				want:  []string{"hello", " ", "world", " "},
+			},
+			{
+				desc:  "breaks after hyphens but not before numbers",
+				width: 8,
+				wrap:  editor.WrapOptions{Word: true},
+				line:  "well-known -12345",
+				want:  []string{"well-", "known ", "-12345 "},
+			},
+			{
+				desc:  "keeps punctuation after the word",
+				width: 6,
+				wrap:  editor.WrapOptions{Word: true},
+				line:  "(abc) def.",
+				want:  []string{"(abc) ", "def. "},
+			},
+			{
+				desc:  "breaks between ideographs",
+				width: 6,
+				wrap:  editor.WrapOptions{Word: true},
+				line:  "日本語の文章",
+				want:  []string{"日本語", "の文章", " "},
+			},
+			{
+				desc:  "kinsoku",
+				width: 6,
+				wrap:  editor.WrapOptions{Word: true},
+				line:  "「あい」。うえ",
+				want:  []string{"「あ", "い」。", "うえ "},
+			},
+			{
+				desc:  "small kana do not start a row",
+				width: 6,
+				wrap:  editor.WrapOptions{Word: true},
+				line:  "あいうぇお",
+				want:  []string{"あい", "うぇお", " "},
+			},
+			{
+				desc:  "quotes keep to the runes around them",
+				width: 6,
+				wrap:  editor.WrapOptions{Word: true},
+				line:  `あい"うえ"お`,
+				want:  []string{"あ", `い"う`, `え"お `},
+			},
+			{
+				desc:  "numbers keep to their prefixes",
+				width: 8,
+				wrap:  editor.WrapOptions{Word: true},
+				line:  "値段は＄100です",
+				want:  []string{"値段は", "＄100で", "す "},
+			},
+			{
+				desc:  "numbers keep to their postfixes",
+				width: 7,
+				wrap:  editor.WrapOptions{Word: true},
+				line:  "値段100％です",
+				want:  []string{"値段", "100％で", "す "},
+			},
+			{
+				desc:  "numbers keep to their separators",
+				width: 10,
+				wrap:  editor.WrapOptions{Word: true},
+				line:  "to $1,000.50 ok",
+				want:  []string{"to ", "$1,000.50 ", "ok "},
+			},
+			{
+				desc:  "numbers keep to their parentheses",
+				width: 6,
+				wrap:  editor.WrapOptions{Word: true},
+				line:  "ab (12) c",
+				want:  []string{"ab ", "(12) c", " "},
+			},
+			{
+				desc:  "hanging indent and marker",
+				width: 8,
+				wrap:  editor.WrapOptions{Word: true, Indent: true, Marker: ">"},
+				line:  "  ab cd ef gh",
+				want:  []string{"  ab cd ", "  >ef gh", "  > "},
+			},
+			{
+				desc:  "marker without word wrap",
+				width: 4,
+				wrap:  editor.WrapOptions{Marker: ">"},
+				line:  "abcdefg",
+				want:  []string{"abcd", ">efg", "> "},
+			},
+			{
+				desc:  "indent too deep",
+				width: 8,
+				wrap:  editor.WrapOptions{Indent: true, Marker: ">"},
+				line:  "      abcdef",
+				want:  []string{"      ab", ">cdef "},
+			},
+		}
+		for _, tt := range tests {
+			sc := &editor.Screen{Width: tt.width, Wrap: tt.wrap}
+			sc.Update([]string{tt.line})
+			var got []string
+			for _, r := range sc.Rows {
+				got = append(got, r.Prefix+r.Body)
+				// The cursor positions follow the widths of the characters after the prefix.
+				x := editor.StringWidth(r.Prefix)
+				for j, c := range r.Body {
+					if diff := cmp.Diff(x, r.ScreenXs[utf8.RuneCountInString(r.Body[:j])]); diff != "" {
+						t.Errorf("%s: %q: %s", tt.desc, r.Body, diff)
+					}
+					x += editor.RuneWidth(c)
+				}
+				if x > tt.width && r.Len > 1 {
+					t.Errorf("%s: %q is wider than %d", tt.desc, r.Prefix+r.Body, tt.width)
+				}
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("%s: %s", tt.desc, diff)
+			}
+		}
+	})
+
+	t.Run("Update() with tabs", func(t *testing.T) {
+		tests := []struct {
+			desc     string
+			width    int
+			tabWidth int
+			noWrap   bool
+			wrap     editor.WrapOptions
+			line     string
+			want     []string
+			wantXs   [][]int
+		}{
+			{
+				desc:     "tab stops",
+				tabWidth: 4,
+				line:     "a\tb\t\tc",
+				want:     []string{"a\tb\t\tc "},
+				wantXs:   [][]int{{0, 1, 4, 5, 8, 12, 13}},
+			},
+			{
+				desc:   "8 columns by default",
+				line:   "\tx",
+				want:   []string{"\tx "},
+				wantXs: [][]int{{0, 8, 9}},
+			},
+			{
+				desc:     "cut at the end of a row",
+				width:    10,
+				tabWidth: 4,
+				line:     "abcdefgh\tx",
+				want:     []string{"abcdefgh\t", "x "},
+				wantXs:   [][]int{{0, 1, 2, 3, 4, 5, 6, 7, 8}, {0, 1}},
+			},
+			{
+				desc:     "starts a row",
+				width:    4,
+				tabWidth: 4,
+				line:     "abcd\tx",
+				want:     []string{"abcd", "\t", "x "},
+				wantXs:   [][]int{{0, 1, 2, 3}, {0}, {0, 1}},
+			},
+			{
+				desc:     "not cut without wrapping",
+				width:    10,
+				tabWidth: 8,
+				noWrap:   true,
+				line:     "\t\tx",
+				want:     []string{"\t\tx "},
+				wantXs:   [][]int{{0, 8, 16, 17}},
+			},
+			{
+				desc:     "hanging indent",
+				width:    12,
+				tabWidth: 4,
+				wrap:     editor.WrapOptions{Word: true, Indent: true},
+				line:     "\tabc def ghi",
+				want:     []string{"\tabc def ", "    ghi "},
+				wantXs:   [][]int{{0, 4, 5, 6, 7, 8, 9, 10, 11}, {4, 5, 6, 7}},
+			},
+		}
+		for _, tt := range tests {
+			sc := &editor.Screen{Width: tt.width, TabWidth: tt.tabWidth, NoWrap: tt.noWrap, Wrap: tt.wrap}
+			sc.Update([]string{tt.line})
+			var got []string
+			var gotXs [][]int
+			for _, r := range sc.Rows {
+				got = append(got, r.Prefix+r.Body)
+				gotXs = append(gotXs, r.ScreenXs)
+			}
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("%s: %s", tt.desc, diff)
+			}
+			if diff := cmp.Diff(tt.wantXs, gotXs); diff != "" {
+				t.Errorf("%s: %s", tt.desc, diff)
+			}
+		}
+	})
+
+	t.Run("NoWrap", func(t *testing.T) {
+		tests := []struct {
+			desc        string
+			line        string
+			col         int
+			hscroll     int
+			wantHscroll int
+			wantCx      int
+			wantSpan    [2]int
+			wantLeft    bool
+			wantRight   bool
+		}{
+			{desc: "start of the line", line: "abcdefghijklmn", col: 0, wantHscroll: 0, wantCx: 0, wantSpan: [2]int{0, 9}, wantRight: true},
+			{desc: "last column is for the indicator", line: "abcdefghijklmn", col: 9, wantHscroll: 4, wantCx: 5, wantSpan: [2]int{5, 14}, wantLeft: true},
+			{desc: "overflow on both sides", line: "abcdefghijklmnopqrstuvwxyz", col: 9, wantHscroll: 4, wantCx: 5, wantSpan: [2]int{5, 13}, wantLeft: true, wantRight: true},
+			{desc: "end of the line", line: "abcdefghijklmn", col: 14, wantHscroll: 9, wantCx: 5, wantSpan: [2]int{10, 15}, wantLeft: true},
+			{desc: "short line", line: "abc", col: 3, wantHscroll: 0, wantCx: 3, wantSpan: [2]int{0, 4}},
+			{desc: "wide characters", line: "aいうえおかきくけこ", col: 8, wantHscroll: 10, wantCx: 5, wantSpan: [2]int{6, 11}, wantLeft: true},
+			{desc: "wide characters cut by the edges", line: "いうえおかきくけこ", col: 6, hscroll: 6, wantHscroll: 7, wantCx: 5, wantSpan: [2]int{4, 7}, wantLeft: true, wantRight: true},
+		}
+		for _, tt := range tests {
+			sc := &editor.Screen{Width: 10, Height: 5, NoWrap: true}
+			sc.Update([]string{tt.line})
+			sc.SetPosition(0, tt.col)
+			cx, _ := sc.CursorPosition()
+			if diff := cmp.Diff([2]int{tt.wantHscroll, tt.wantCx}, [2]int{sc.Hscroll, cx}); diff != "" {
+				t.Errorf("%s: %s", tt.desc, diff)
+			}
+			if tt.hscroll > 0 {
+				sc.Hscroll = tt.hscroll
+			}
+			first, end, left, right := sc.RowSpan(0)
+			if diff := cmp.Diff(tt.wantSpan, [2]int{first, end}); diff != "" {
+				t.Errorf("%s: %s", tt.desc, diff)
+			}
+			if diff := cmp.Diff([2]bool{tt.wantLeft, tt.wantRight}, [2]bool{left, right}); diff != "" {
+				t.Errorf("%s: %s", tt.desc, diff)
+			}
+		}
+	})
+
 	t.Run("Scroll()", func(t *testing.T) {
 		tests := []struct {
 			desc        string
@@ -473,4 +825,65 @@ func TestScreen(t *testing.T) {
 			}
 		}
 	})
+	t.Run("Position()", func(t *testing.T) {
+		tests := []struct {
+			desc     string
+			width    int
+			buffer   []string
+			line     int
+			col      int
+			wantCx   int
+			wantCy   int
+			wantLine int
+			wantCol  int
+		}{
+			{
+				desc:     "first line",
+				width:    80,
+				buffer:   []string{"abc", "de"},
+				line:     0,
+				col:      2,
+				wantCx:   2,
+				wantCy:   0,
+				wantLine: 0,
+				wantCol:  2,
+			},
+			{
+				desc:     "wrapped line",
+				width:    2,
+				buffer:   []string{"a", "bcde"},
+				line:     1,
+				col:      3,
+				wantCx:   1,
+				wantCy:   2,
+				wantLine: 1,
+				wantCol:  3,
+			},
+			{
+				desc:     "clamp to end of line",
+				width:    80,
+				buffer:   []string{"abc", "de"},
+				line:     1,
+				col:      10,
+				wantCx:   2,
+				wantCy:   1,
+				wantLine: 1,
+				wantCol:  2,
+			},
+		}
+		for _, tt := range tests {
+			sc := &editor.Screen{
+				Width: tt.width,
+			}
+			sc.Update(tt.buffer)
+			sc.SetPosition(tt.line, tt.col)
+			if diff := cmp.Diff(fmt.Sprintf("%d,%d", tt.wantCx, tt.wantCy), fmt.Sprintf("%d,%d", sc.Cx, sc.Cy)); diff != "" {
+				t.Errorf("%s: %s", tt.desc, diff)
+			}
+			line, col := sc.Position()
+			if diff := cmp.Diff(fmt.Sprintf("%d,%d", tt.wantLine, tt.wantCol), fmt.Sprintf("%d,%d", line, col)); diff != "" {
+				t.Errorf("%s: %s", tt.desc, diff)
+			}
+		}
+	})
 }
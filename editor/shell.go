@@ -0,0 +1,170 @@
+package editor
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// shellOutputName is the name of the buffer showing the output of shell commands.
+const shellOutputName = "*Shell Command Output*"
+
+var errNoCommand = errors.New("no shell command")
+
+// releaseTerminal gives the terminal back in its original mode, with the screen of the shell, to
+// a program run in it, and returns a function taking it back. Keys are not read from it in the
+// meantime.
+func (e *Editor) releaseTerminal() func() {
+	if !IsTerminal(e.In) {
+		return func() {}
+	}
+	e.input.Lock()
+	e.LeaveAlternateScreen()
+	e.ResetRawMode()
+	return func() {
+		e.Error(e.SetRawMode())
+		e.EnterAlternateScreen()
+		e.input.Unlock()
+		e.ClearScreen()
+	}
+}
+
+// RunShell runs command with the shell in the directory of the current buffer and returns its
+// output. The command reads input, or the terminal if input is nil, in which case it may use the
+// terminal while it runs. What it writes to stderr is shown as a warning, or returned as the error
+// if the command fails.
+func (e *Editor) RunShell(command string, input io.Reader) (string, error) {
+	if strings.TrimSpace(command) == "" {
+		return "", errNoCommand
+	}
+	cmd := exec.Command("sh", "-c", command)
+	if e.Buffer != nil && e.Buffer.Path != "" {
+		cmd.Dir = filepath.Dir(e.Buffer.Path)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdin = input
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	// Filters run without the terminal, leaving the screen as it is.
+	restore := func() {}
+	if input == nil {
+		cmd.Stdin = e.In
+		restore = e.releaseTerminal()
+	}
+	err := cmd.Run()
+	restore()
+	msg := oneLine(stderr.String())
+	if err != nil {
+		if msg == "" {
+			return "", fmt.Errorf("%s: %w", command, err)
+		}
+		return "", fmt.Errorf("%s: %s", command, msg)
+	}
+	if msg != "" {
+		e.Warnf("%s", msg)
+	}
+	return stdout.String(), nil
+}
+
+// ShellCommand shows the output of command in a buffer.
+func (e *Editor) ShellCommand(command string) error {
+	out, err := e.RunShell(command, nil)
+	if err != nil {
+		return err
+	}
+	if out == "" {
+		if e.Message == nil {
+			e.Infof("(Shell command succeeded with no output)")
+		}
+		return nil
+	}
+	b := e.FindBuffer(shellOutputName)
+	if b == nil {
+		b = NewBuffer(shellOutputName)
+		e.AddBuffer(b)
+	}
+	b.Lines = strings.Split(strings.TrimSuffix(out, "\n"), "\n")
+	b.Dirty = false
+	b.clearUndo()
+	b.Line, b.Col, b.Vscroll = 0, 0, 0
+	e.BufferReloaded(b)
+	e.SwitchBuffer(b)
+	e.Screen.SetPosition(0, 0)
+	return nil
+}
+
+// InsertShellCommand inserts the output of command at the cursor, without its final newline.
+func (e *Editor) InsertShellCommand(command string) error {
+	if e.Buffer.ReadOnly {
+		return ErrReadOnly
+	}
+	if e.Buffer.HexMode {
+		return errNotText
+	}
+	out, err := e.RunShell(command, nil)
+	if err != nil {
+		return err
+	}
+	e.InsertText(strings.TrimSuffix(out, "\n"))
+	return nil
+}
+
+// FilterRegion replaces the text from (line, col) up to (endLine, endCol) with the output of
+// command reading it. The output loses its final newline unless the text ends with one.
+func (e *Editor) FilterRegion(line, col, endLine, endCol int, command string) error {
+	b := e.Buffer
+	if b.ReadOnly {
+		return ErrReadOnly
+	}
+	if b.HexMode {
+		return errNotText
+	}
+	text := b.Text(line, col, endLine, endCol)
+	out, err := e.RunShell(command, strings.NewReader(text))
+	if err != nil {
+		return err
+	}
+	if !strings.HasSuffix(text, "\n") {
+		out = strings.TrimSuffix(out, "\n")
+	}
+	c := b.Replace(line, col, endLine, endCol, out)
+	e.BufferChanged(b, c)
+	e.Screen.SetPosition(line, col)
+	return nil
+}
+
+// FilterBuffer replaces the text of the current buffer with the output of command reading it,
+// keeping the cursor on the same text as far as the lines are the same.
+func (e *Editor) FilterBuffer(command string) error {
+	b := e.Buffer
+	if b.ReadOnly {
+		return ErrReadOnly
+	}
+	if b.HexMode {
+		return errNotText
+	}
+	text := strings.Join(b.Lines, "\n")
+	if b.FinalNewline {
+		text += "\n"
+	}
+	out, err := e.RunShell(command, strings.NewReader(text))
+	if err != nil {
+		return err
+	}
+	e.replaceText(b, strings.Split(strings.TrimSuffix(out, "\n"), "\n"))
+	return nil
+}
+
+// promptShellCommand asks for a shell command and runs it with run.
+func (e *Editor) promptShellCommand(prompt string, run func(string) error) {
+	e.Prompt("shell", prompt, nil, func(s string, ok bool) {
+		if !ok || strings.TrimSpace(s) == "" {
+			return
+		}
+		e.Error(run(s))
+	})
+}
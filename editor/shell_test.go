@@ -0,0 +1,107 @@
+package editor_test
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/mizuochikeita/re/editor"
+)
+
+func TestShell(t *testing.T) {
+	newEditor := func(t *testing.T, text string, line, col int) *editor.Editor {
+		t.Helper()
+		e := editor.New()
+		// Commands must not take a terminal the tests run in.
+		null, err := os.Open(os.DevNull)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { null.Close() })
+		e.In = null
+		if err := e.OpenReader("test", strings.NewReader(text)); err != nil {
+			t.Fatal(err)
+		}
+		e.Layout.Arrange(0, 0, 80, 24)
+		e.Screen.SetPosition(line, col)
+		return e
+	}
+
+	tests := []struct {
+		desc    string
+		text    string
+		pos     [2]int
+		run     func(e *editor.Editor) error
+		want    string
+		wantPos [2]int
+		wantErr string
+	}{
+		{desc: "insert", text: "ab", pos: [2]int{0, 1},
+			run:  func(e *editor.Editor) error { return e.InsertShellCommand("echo x") },
+			want: "axb", wantPos: [2]int{0, 2}},
+		{desc: "filter lines", text: "c\nb\na\nz", pos: [2]int{0, 0},
+			run:  func(e *editor.Editor) error { return e.FilterRegion(0, 0, 3, 0, "sort") },
+			want: "a\nb\nc\nz", wantPos: [2]int{0, 0}},
+		{desc: "filter text", text: "x foo y", pos: [2]int{0, 0},
+			run:  func(e *editor.Editor) error { return e.FilterRegion(0, 2, 0, 5, "tr a-z A-Z") },
+			want: "x FOO y", wantPos: [2]int{0, 2}},
+		{desc: "filter the buffer", text: "head\nb   1\naaa 22\n", pos: [2]int{1, 4},
+			run:  func(e *editor.Editor) error { return e.FilterBuffer("tr -s ' '") },
+			want: "head\nb 1\naaa 22\n", wantPos: [2]int{1, 2}},
+		{desc: "failure", text: "a\n", pos: [2]int{0, 0},
+			run:  func(e *editor.Editor) error { return e.FilterBuffer("echo oops >&2; exit 1") },
+			want: "a\n", wantPos: [2]int{0, 0}, wantErr: "echo oops >&2; exit 1: oops"},
+		{desc: "no command", text: "a\n", pos: [2]int{0, 0},
+			run:  func(e *editor.Editor) error { return e.FilterBuffer(" ") },
+			want: "a\n", wantPos: [2]int{0, 0}, wantErr: "no shell command"},
+	}
+	for _, tt := range tests {
+		e := newEditor(t, tt.text, tt.pos[0], tt.pos[1])
+		err := tt.run(e)
+		gotErr := ""
+		if err != nil {
+			gotErr = err.Error()
+		}
+		if diff := cmp.Diff(tt.wantErr, gotErr); diff != "" {
+			t.Errorf("%s: %s", tt.desc, diff)
+		}
+		var sb strings.Builder
+		if _, err := e.Buffer.WriteTo(&sb); err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff(tt.want, sb.String()); diff != "" {
+			t.Errorf("%s: %s", tt.desc, diff)
+		}
+		line, col := e.Screen.Position()
+		if diff := cmp.Diff(tt.wantPos, [2]int{line, col}); diff != "" {
+			t.Errorf("%s: %s", tt.desc, diff)
+		}
+	}
+
+	t.Run("ShellCommand()", func(t *testing.T) {
+		e := newEditor(t, "text", 0, 0)
+		if err := e.ShellCommand("printf 'one\\ntwo\\n'; echo warning >&2"); err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff("*Shell Command Output*", e.Buffer.Name); diff != "" {
+			t.Error(diff)
+		}
+		if diff := cmp.Diff([]string{"one", "two"}, e.Buffer.Lines); diff != "" {
+			t.Error(diff)
+		}
+		if diff := cmp.Diff("warning", e.Message.Text); diff != "" {
+			t.Error(diff)
+		}
+		// The output of the next command replaces it.
+		if err := e.ShellCommand("echo three"); err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff([]string{"three"}, e.Buffer.Lines); diff != "" {
+			t.Error(diff)
+		}
+		if diff := cmp.Diff(2, len(e.Buffers)); diff != "" {
+			t.Error(diff)
+		}
+	})
+}
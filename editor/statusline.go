@@ -0,0 +1,182 @@
+package editor
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	defaultStatusLeft  = "mode path modified"
+	defaultStatusRight = "keys position percent eol encoding"
+)
+
+// StatusLine returns the status line of w exactly width columns wide.
+// The segments shown are set by "left" and "right" in the [statusline] section of the config.
+func (e *Editor) StatusLine(w *Window, width int) string {
+	if width <= 0 {
+		return ""
+	}
+	left := strings.Fields(e.Config.GetDefault("statusline", "left", defaultStatusLeft))
+	right := strings.Fields(e.Config.GetDefault("statusline", "right", defaultStatusRight))
+
+	r := TruncateWidth(e.joinSegments(w, right, "  ", 0), width)
+	avail := width - StringWidth(r) - 1
+	if avail < 0 {
+		avail = 0
+	}
+	l := TruncateWidth(e.joinSegments(w, left, " ", avail), avail)
+	padding := width - StringWidth(l) - StringWidth(r)
+	return l + strings.Repeat(" ", padding) + r
+}
+
+// joinSegments renders the segments. The path is shortened to fit in width if width is positive.
+func (e *Editor) joinSegments(w *Window, names []string, sep string, width int) string {
+	var ss []string
+	pathIndex := -1
+	for _, name := range names {
+		if name == "path" {
+			pathIndex = len(ss)
+			ss = append(ss, "")
+			continue
+		}
+		if s := e.statusSegment(w, name); s != "" {
+			ss = append(ss, s)
+		}
+	}
+	if pathIndex >= 0 {
+		p := displayPath(w.Buffer)
+		if width > 0 {
+			// The other segments and the separators between all the segments take the rest.
+			rest := 0
+			for _, s := range ss {
+				rest += StringWidth(s)
+			}
+			rest += StringWidth(sep) * (len(ss) - 1)
+			p = ShortenPath(p, width-rest)
+		}
+		ss[pathIndex] = p
+	}
+	return strings.Join(ss, sep)
+}
+
+func (e *Editor) statusSegment(w *Window, name string) string {
+	b := w.Buffer
+	switch name {
+	case "mode":
+		if w == e.Window && e.Vi != nil {
+			return "-- " + e.Vi.Mode.String() + " --"
+		}
+	case "name":
+		return b.Name
+	case "modified":
+		switch {
+		case b.ReadOnly:
+			return "[RO]"
+		case b.Dirty:
+			return "[+]"
+		}
+	case "keys":
+		if w == e.Window && len(e.pendingKeys) > 0 {
+			return strings.Join(e.pendingKeys, " ") + "-"
+		}
+	case "position":
+		if b.HexMode {
+			return fmt.Sprintf("0x%x", e.filePosition(w))
+		}
+		line, col := w.Screen.Position()
+		return fmt.Sprintf("%d:%d", b.FirstLine+line+1, col+1)
+	case "percent":
+		pos, size := e.filePosition(w), int64(len(b.Lines)-1)
+		switch {
+		case b.HexMode && b.Large != nil:
+			size = b.Large.Size
+		case b.HexMode:
+			size = int64(len(b.Data))
+		case b.Large != nil:
+			n, done := b.Large.Lines()
+			if !done {
+				return "indexing"
+			}
+			size = int64(n - 1)
+		}
+		if size <= 0 {
+			return "All"
+		}
+		return fmt.Sprintf("%d%%", pos*100/size)
+	case "eol":
+		if !b.HexMode {
+			return b.LineEnding
+		}
+	case "indent":
+		if !b.HexMode {
+			return b.Indent.String()
+		}
+	case "encoding":
+		switch {
+		case b.HexMode:
+			return "hex"
+		case b.BOM:
+			return b.Encoding + " BOM"
+		}
+		return b.Encoding
+	}
+	return ""
+}
+
+// displayPath returns the path of the buffer relative to the working directory or the home directory.
+func displayPath(b *Buffer) string {
+	if b.Path == "" {
+		return b.Name
+	}
+	p, err := filepath.Abs(b.Path)
+	if err != nil {
+		return b.Path
+	}
+	if wd, err := os.Getwd(); err == nil {
+		if rel, err := filepath.Rel(wd, p); err == nil && !strings.HasPrefix(rel, "..") {
+			return rel
+		}
+	}
+	if home, err := os.UserHomeDir(); err == nil && strings.HasPrefix(p, home+string(filepath.Separator)) {
+		return "~" + p[len(home):]
+	}
+	return p
+}
+
+// ShortenPath fits path in width columns by abbreviating its directories to their first letters,
+// and then by cutting it from the left.
+func ShortenPath(path string, width int) string {
+	if width <= 0 {
+		return ""
+	}
+	if StringWidth(path) <= width {
+		return path
+	}
+	sep := string(filepath.Separator)
+	parts := strings.Split(path, sep)
+	for i := 0; i < len(parts)-1 && StringWidth(strings.Join(parts, sep)) > width; i++ {
+		rs := []rune(parts[i])
+		n := 1
+		if len(rs) > 1 && rs[0] == '.' {
+			n = 2
+		}
+		if len(rs) > n {
+			parts[i] = string(rs[:n])
+		}
+	}
+	p := strings.Join(parts, sep)
+	if StringWidth(p) <= width {
+		return p
+	}
+	ellipsis := "…"
+	if StringWidth(ellipsis) >= width {
+		ellipsis = ""
+	}
+	rs := []rune(p)
+	for len(rs) > 0 && StringWidth(ellipsis+string(rs)) > width {
+		rs = rs[1:]
+	}
+	return ellipsis + string(rs)
+}
@@ -0,0 +1,75 @@
+package editor_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/mizuochikeita/re/editor"
+)
+
+func TestStatusLine(t *testing.T) {
+	t.Run("ShortenPath()", func(t *testing.T) {
+		tests := []struct {
+			path  string
+			width int
+			want  string
+		}{
+			{path: "editor/screen.go", width: 20, want: "editor/screen.go"},
+			{path: "editor/screen.go", width: 11, want: "e/screen.go"},
+			{path: "~/.config/re/keymap", width: 14, want: "~/.c/re/keymap"},
+			{path: "editor/screen.go", width: 8, want: "…een.go"},
+			{path: "editor/screen.go", width: 1, want: "o"},
+			{path: "editor/screen.go", width: 0, want: ""},
+		}
+		for _, tt := range tests {
+			if diff := cmp.Diff(tt.want, editor.ShortenPath(tt.path, tt.width)); diff != "" {
+				t.Errorf("%s in %d: %s", tt.path, tt.width, diff)
+			}
+		}
+	})
+
+	t.Run("StatusLine()", func(t *testing.T) {
+		e := editor.New()
+		b := editor.NewBuffer("")
+		b.Path = "日本語/ファイル.txt"
+		b.Lines = []string{"a", "b", "c"}
+		b.Dirty = true
+		e.AddBuffer(b)
+		e.Layout.Arrange(0, 0, 80, 24)
+		e.Screen.SetPosition(1, 1)
+		tests := []struct {
+			width int
+			want  string
+		}{
+			{width: 60, want: "日本語/ファイル.txt [+]" + strings.Repeat(" ", 18) + "2:2  50%  LF  UTF-8"},
+			{width: 30, want: "….txt [+] 2:2  50%  LF  UTF-8"},
+			{width: 10, want: "2:2  50%  "},
+			{width: 3, want: "2:2"},
+			{width: 0, want: ""},
+		}
+		for _, tt := range tests {
+			got := e.StatusLine(e.Window, tt.width)
+			if diff := cmp.Diff(tt.want, got); diff != "" {
+				t.Errorf("width %d: %s", tt.width, diff)
+			}
+			if diff := cmp.Diff(tt.width, editor.StringWidth(got)); diff != "" {
+				t.Errorf("width %d: %s", tt.width, diff)
+			}
+		}
+	})
+
+	t.Run("StatusLine() with custom segments", func(t *testing.T) {
+		e := editor.New()
+		c, err := editor.ParseConfig(strings.NewReader("[statusline]\nleft = name\nright = position\n"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		e.Config = c
+		e.AddBuffer(editor.NewBuffer("scratch"))
+		e.Layout.Arrange(0, 0, 80, 24)
+		if diff := cmp.Diff("scratch        1:1", e.StatusLine(e.Window, 18)); diff != "" {
+			t.Error(diff)
+		}
+	})
+}
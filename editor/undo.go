@@ -0,0 +1,137 @@
+package editor
+
+import "errors"
+
+var (
+	errNoUndo = errors.New("no further undo information")
+	errNoRedo = errors.New("no further redo information")
+)
+
+// edit is a change of the text of a buffer kept for undo, with the text it replaced and the
+// text it inserted.
+type edit struct {
+	Change
+	old string
+	new string
+}
+
+// record keeps the edit c, which replaced old with new, for undo. It joins the last group of
+// edits unless endUndoGroup was called since, and the edits undone can no longer be redone.
+func (b *Buffer) record(c Change, old, new string) {
+	b.redo = nil
+	if !b.undoOpen || len(b.undo) == 0 {
+		if b.savedUndo > len(b.undo) {
+			b.savedUndo = -1
+		}
+		b.undo = append(b.undo, nil)
+		b.undoOpen = true
+	}
+	b.undo[len(b.undo)-1] = append(b.undo[len(b.undo)-1], edit{c, old, new})
+}
+
+// endUndoGroup makes the next edit start a new group of edits undone together.
+func (b *Buffer) endUndoGroup() {
+	b.undoOpen = false
+}
+
+// clearUndo forgets the edits of the buffer after its whole text is replaced.
+func (b *Buffer) clearUndo() {
+	b.undo, b.redo, b.undoOpen = nil, nil, false
+	b.savedUndo = 0
+	if b.Dirty {
+		b.savedUndo = -1
+	}
+}
+
+// modified marks the buffer as changed by other than an edit of its text, which undo does not revert.
+func (b *Buffer) modified() {
+	b.Dirty = true
+	b.savedUndo = -1
+}
+
+// Undo reverts the last group of edits and returns the changes it made, the last one at the
+// first edit of the group, or false if there is nothing to undo. The buffer is modified unless
+// it gets back to the text saved.
+func (b *Buffer) Undo() ([]Change, bool) {
+	if len(b.undo) == 0 {
+		return nil, false
+	}
+	g := b.undo[len(b.undo)-1]
+	b.undo = b.undo[:len(b.undo)-1]
+	b.undoOpen = false
+	var cs []Change
+	for i := len(g) - 1; i >= 0; i-- {
+		ed := g[i]
+		cs = append(cs, b.replace(ed.Line, ed.Col, ed.NewEndLine, ed.NewEndCol, ed.old))
+	}
+	b.redo = append(b.redo, g)
+	b.Dirty = len(b.undo) != b.savedUndo
+	return cs, true
+}
+
+// Redo makes the last group of edits undone again and returns the changes it made, or false
+// if nothing has been undone since the last edit.
+func (b *Buffer) Redo() ([]Change, bool) {
+	if len(b.redo) == 0 {
+		return nil, false
+	}
+	g := b.redo[len(b.redo)-1]
+	b.redo = b.redo[:len(b.redo)-1]
+	var cs []Change
+	for _, ed := range g {
+		cs = append(cs, b.replace(ed.Line, ed.Col, ed.OldEndLine, ed.OldEndCol, ed.new))
+	}
+	b.undo = append(b.undo, g)
+	b.Dirty = len(b.undo) != b.savedUndo
+	return cs, true
+}
+
+// endUndoGroup ends the groups of edits of all the buffers.
+func (e *Editor) endUndoGroup() {
+	for _, b := range e.Buffers {
+		b.endUndoGroup()
+	}
+}
+
+// Undo reverts the last command editing the current buffer and moves the cursor to where it edited.
+func (e *Editor) Undo() {
+	e.Error(e.undo(false))
+}
+
+// Redo makes the last command undone in the current buffer again.
+func (e *Editor) Redo() {
+	e.Error(e.undo(true))
+}
+
+func (e *Editor) undo(redo bool) error {
+	b := e.Buffer
+	if b.ReadOnly {
+		return ErrReadOnly
+	}
+	if b.HexMode {
+		return errNotText
+	}
+	var cs []Change
+	var ok bool
+	if redo {
+		cs, ok = b.Redo()
+	} else {
+		cs, ok = b.Undo()
+	}
+	if !ok {
+		if redo {
+			return errNoRedo
+		}
+		return errNoUndo
+	}
+	for _, c := range cs {
+		e.BufferChanged(b, c)
+	}
+	c := cs[len(cs)-1]
+	if redo {
+		e.Screen.SetPosition(c.NewEndLine, c.NewEndCol)
+	} else {
+		e.Screen.SetPosition(c.Line, c.Col)
+	}
+	return nil
+}
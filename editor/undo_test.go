@@ -0,0 +1,152 @@
+package editor_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/mizuochikeita/re/editor"
+)
+
+func TestUndo(t *testing.T) {
+	var (
+		undo = editor.Key{Value: '\x1f'}          // C-_
+		redo = editor.Key{Value: '_', Meta: true} // M-_
+	)
+	typed := func(s string) []editor.Key {
+		var ks []editor.Key
+		for _, r := range s {
+			ks = append(ks, editor.Key{Value: r})
+		}
+		return ks
+	}
+	keys := func(kss ...[]editor.Key) []editor.Key {
+		var ks []editor.Key
+		for _, k := range kss {
+			ks = append(ks, k...)
+		}
+		return ks
+	}
+	newEditor := func(t *testing.T) *editor.Editor {
+		t.Helper()
+		e := editor.New()
+		out, err := os.Create(filepath.Join(t.TempDir(), "out"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { out.Close() })
+		e.Out = out
+		return e
+	}
+	handleKeys := func(e *editor.Editor, ks []editor.Key) {
+		for _, k := range ks {
+			e.HandleKey(k, func() {})
+		}
+	}
+
+	t.Run("HandleKey()", func(t *testing.T) {
+		tests := []struct {
+			desc      string
+			vi        bool
+			text      string
+			keys      []editor.Key
+			wantLines []string
+			wantPos   [2]int
+			wantMsg   string
+		}{
+			{desc: "typed runes", text: "x",
+				keys:      keys(typed("abc"), []editor.Key{undo}),
+				wantLines: []string{"x"}, wantPos: [2]int{0, 0}},
+			{desc: "a command after typing", text: "",
+				keys:      keys(typed("ab\rc"), []editor.Key{undo}),
+				wantLines: []string{"ab", ""}, wantPos: [2]int{1, 0}},
+			{desc: "undo again", text: "",
+				keys:      keys(typed("ab\rc"), []editor.Key{undo, undo}),
+				wantLines: []string{"ab"}, wantPos: [2]int{0, 2}},
+			{desc: "redo", text: "",
+				keys:      keys(typed("ab\rc"), []editor.Key{undo, undo, redo}),
+				wantLines: []string{"ab", ""}, wantPos: [2]int{1, 0}},
+			{desc: "typing drops the edits undone", text: "",
+				keys:      keys(typed("ab"), []editor.Key{undo}, typed("x"), []editor.Key{redo}),
+				wantLines: []string{"x"}, wantPos: [2]int{0, 1}, wantMsg: "no further redo information"},
+			{desc: "nothing to undo", text: "x",
+				keys:      []editor.Key{undo},
+				wantLines: []string{"x"}, wantPos: [2]int{0, 0}, wantMsg: "no further undo information"},
+			{desc: "vi insert", vi: true, text: "x",
+				keys:      typed("ihello\rworld\x1bu"),
+				wantLines: []string{"x"}, wantPos: [2]int{0, 0}},
+			{desc: "vi redo", vi: true, text: "x",
+				keys:      typed("ihello\x1bu\x12"),
+				wantLines: []string{"hellox"}, wantPos: [2]int{0, 5}},
+			{desc: "vi count", vi: true, text: "a\nb\nc",
+				keys:      typed("dddd2u"),
+				wantLines: []string{"a", "b", "c"}, wantPos: [2]int{0, 0}},
+		}
+		for _, tt := range tests {
+			e := newEditor(t)
+			if err := e.OpenReader("test", strings.NewReader(tt.text)); err != nil {
+				t.Fatal(err)
+			}
+			e.Layout.Arrange(0, 0, 80, 24)
+			e.SetViMode(tt.vi)
+			handleKeys(e, tt.keys)
+			if diff := cmp.Diff(tt.wantLines, e.Buffer.Lines); diff != "" {
+				t.Errorf("%s: %s", tt.desc, diff)
+			}
+			line, col := e.Screen.Position()
+			if diff := cmp.Diff(tt.wantPos, [2]int{line, col}); diff != "" {
+				t.Errorf("%s: %s", tt.desc, diff)
+			}
+			msg := ""
+			if e.Message != nil {
+				msg = e.Message.Text
+			}
+			if diff := cmp.Diff(tt.wantMsg, msg); diff != "" {
+				t.Errorf("%s: %s", tt.desc, diff)
+			}
+		}
+	})
+
+	t.Run("SaveBuffer() with a formatter", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "a.txt")
+		if err := os.WriteFile(path, []byte("x  =  1\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		e := newEditor(t)
+		config, err := editor.ParseConfig(strings.NewReader("[format.txt]\ncommand = tr -s ' '\n"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		e.Config = config
+		if err := e.OpenFile(path); err != nil {
+			t.Fatal(err)
+		}
+		e.Layout.Arrange(0, 0, 80, 24)
+		tests := []struct {
+			desc      string
+			keys      []editor.Key
+			wantLines []string
+			wantDirty bool
+		}{
+			{desc: "save", keys: keys(typed("y"), []editor.Key{{Value: '\x13'}}), // C-s
+				wantLines: []string{"yx = 1"}, wantDirty: false},
+			{desc: "undo the formatting", keys: []editor.Key{undo},
+				wantLines: []string{"yx  =  1"}, wantDirty: true},
+			{desc: "undo the typing", keys: []editor.Key{undo},
+				wantLines: []string{"x  =  1"}, wantDirty: true},
+			{desc: "redo up to the text saved", keys: []editor.Key{redo, redo},
+				wantLines: []string{"yx = 1"}, wantDirty: false},
+		}
+		for _, tt := range tests {
+			handleKeys(e, tt.keys)
+			if diff := cmp.Diff(tt.wantLines, e.Buffer.Lines); diff != "" {
+				t.Errorf("%s: %s", tt.desc, diff)
+			}
+			if diff := cmp.Diff(tt.wantDirty, e.Buffer.Dirty); diff != "" {
+				t.Errorf("%s: %s", tt.desc, diff)
+			}
+		}
+	})
+}
@@ -3,6 +3,7 @@ package editor
 import (
 	"fmt"
 	"os"
+	"unicode"
 )
 
 func ToControl(r rune) rune {
@@ -18,3 +19,35 @@ func Debugf(format string, a ...interface{}) {
 	fmt.Fprintf(f, format, a...)
 	fmt.Fprintln(f)
 }
+
+// StringWidth returns the number of screen columns s occupies.
+func StringWidth(s string) int {
+	w := 0
+	for _, c := range s {
+		w += RuneWidth(c)
+	}
+	return w
+}
+
+// RuneWidth returns the number of screen columns c occupies. An escape rune shows as \xNN.
+func RuneWidth(c rune) int {
+	if _, ok := EscapedByte(c); ok {
+		return 4
+	}
+	if c <= unicode.MaxASCII {
+		return 1
+	}
+	return 2
+}
+
+// TruncateWidth cuts s so that it fits in width screen columns.
+func TruncateWidth(s string, width int) string {
+	w := 0
+	for i, c := range s {
+		w += RuneWidth(c)
+		if w > width {
+			return s[:i]
+		}
+	}
+	return s
+}
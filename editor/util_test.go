@@ -21,3 +21,22 @@ func TestToControl(t *testing.T) {
 		}
 	}
 }
+
+func TestTruncateWidth(t *testing.T) {
+	tests := []struct {
+		in    string
+		width int
+		want  string
+	}{
+		{in: "abc", width: 5, want: "abc"},
+		{in: "abc", width: 2, want: "ab"},
+		{in: "あいう", width: 3, want: "あ"},
+		{in: "abc", width: 0, want: ""},
+		{in: "a" + string(editor.EscapeRune(0xff)), width: 4, want: "a"},
+	}
+	for _, tt := range tests {
+		if diff := cmp.Diff(tt.want, editor.TruncateWidth(tt.in, tt.width)); diff != "" {
+			t.Errorf("%s: %s", tt.in, diff)
+		}
+	}
+}
@@ -0,0 +1,1135 @@
+package editor
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type ViMode int
+
+const (
+	ViNormal ViMode = iota
+	ViInsert
+	ViVisual
+	ViVisualLine
+	ViCommandLine
+)
+
+func (m ViMode) String() string {
+	switch m {
+	case ViInsert:
+		return "INSERT"
+	case ViVisual:
+		return "VISUAL"
+	case ViVisualLine:
+		return "V-LINE"
+	case ViCommandLine:
+		return "COMMAND"
+	default:
+		return "NORMAL"
+	}
+}
+
+// Vi is a modal input layer interpreting keys like vi.
+type Vi struct {
+	Mode     ViMode
+	Register Register
+	// register is the named register given with '"' to the command being run, or 0.
+	register rune
+
+	// keys holds the keys of the normal mode command being typed.
+	keys []rune
+	// recording holds the keys of the change being made, to be repeated by ".".
+	recording  []Key
+	lastChange []Key
+	replaying  bool
+
+	anchorLine int
+	anchorCol  int
+}
+
+type viStatus int
+
+const (
+	viIncomplete viStatus = iota
+	viInvalid
+	viDone
+)
+
+type viPos struct {
+	line int
+	col  int
+}
+
+func (p viPos) less(q viPos) bool {
+	return p.line < q.line || p.line == q.line && p.col < q.col
+}
+
+// viMotion is the target of a motion. The range to it includes the target if inclusive,
+// and covers whole lines if linewise.
+type viMotion struct {
+	viPos
+	linewise  bool
+	inclusive bool
+}
+
+func NewVi() *Vi {
+	return &Vi{}
+}
+
+// SetViMode enables or disables the vi input layer.
+func (e *Editor) SetViMode(enabled bool) {
+	if !enabled {
+		e.Vi = nil
+		return
+	}
+	e.Vi = NewVi()
+	e.Vi.clampCursor(e)
+}
+
+func (v *Vi) HandleKey(e *Editor, k Key) {
+	if !v.replaying && (v.Mode == ViInsert || v.recording != nil) {
+		v.recording = append(v.recording, k)
+	}
+	if v.Mode == ViInsert {
+		if k.Value == '\x1b' && !k.IsEscaped() && !k.Meta {
+			v.Mode = ViNormal
+			v.finishChange()
+			line, col := e.Screen.Position()
+			e.Screen.SetPosition(line, col-1)
+			return
+		}
+		e.Error(e.ProcessKey(k))
+		return
+	}
+	if v.Mode == ViNormal && !k.IsEscaped() && !k.Meta && len(e.pendingKeys) == 0 {
+		switch k.Value {
+		case '\x0f': // C-o
+			v.keys = nil
+			e.JumpBack()
+			v.clampCursor(e)
+			return
+		case '\t':
+			v.keys = nil
+			e.JumpForward()
+			v.clampCursor(e)
+			return
+		case '\x12': // C-r
+			v.keys = nil
+			e.Redo()
+			v.clampCursor(e)
+			return
+		}
+	}
+	if k.IsEscaped() || k.Meta || len(e.pendingKeys) > 0 ||
+		k.IsControl() && k.Value != '\x1b' && k.Value != '\r' && k.Value != '\x7f' {
+		v.keys = nil
+		e.Error(e.ProcessKey(k))
+		v.clampCursor(e)
+		return
+	}
+	r := k.Value
+	switch r {
+	case '\x1b':
+		v.keys = nil
+		v.recording = nil
+		if v.Mode == ViVisual || v.Mode == ViVisualLine {
+			v.Mode = ViNormal
+		}
+		return
+	case '\r':
+		r = 'j'
+	case '\x7f':
+		r = 'h'
+	}
+	if len(v.keys) == 0 && !v.replaying {
+		v.recording = []Key{k}
+	}
+	v.keys = append(v.keys, r)
+	var st viStatus
+	if v.Mode == ViVisual || v.Mode == ViVisualLine {
+		st = v.runVisual(e, v.keys)
+	} else {
+		st = v.run(e, v.keys)
+	}
+	if st == viIncomplete {
+		return
+	}
+	v.keys = nil
+	v.register = 0
+	if st == viInvalid {
+		v.recording = nil
+	}
+	if v.Mode == ViNormal {
+		v.clampCursor(e)
+	}
+}
+
+// finishChange remembers the keys of the change just made for ".".
+func (v *Vi) finishChange() {
+	if v.recording != nil && !v.replaying {
+		v.lastChange = v.recording
+	}
+	v.recording = nil
+}
+
+// run executes a normal mode command if rs is a complete one.
+func (v *Vi) run(e *Editor, rs []rune) viStatus {
+	rs, st := v.parseRegister(rs)
+	if st != viDone {
+		return st
+	}
+	count, rs := parseCount(rs)
+	if len(rs) == 0 {
+		return viIncomplete
+	}
+	n := count
+	if n == 0 {
+		n = 1
+	}
+	cur := v.cursor(e)
+	b := e.Buffer
+	switch c := rs[0]; c {
+	case 'd', 'c', 'y':
+		count2, rest := parseCount(rs[1:])
+		if count2 > 0 {
+			n *= count2
+			count = n
+		}
+		if len(rest) == 0 {
+			return viIncomplete
+		}
+		switch {
+		case rest[0] == c:
+			last := cur.line + n - 1
+			if last >= len(b.Lines) {
+				last = len(b.Lines) - 1
+			}
+			v.operate(e, c, cur, viMotion{viPos: viPos{last, 0}, linewise: true})
+		case rest[0] == 'i' || rest[0] == 'a':
+			if len(rest) < 2 {
+				return viIncomplete
+			}
+			from, to, ok := v.textObject(b, cur, rest[0] == 'i', rest[1])
+			if !ok {
+				return viInvalid
+			}
+			v.operate(e, c, from, viMotion{viPos: to})
+		default:
+			m, st := v.motion(e, rest, count)
+			if st != viDone {
+				return st
+			}
+			if c == 'c' && (rest[0] == 'w' || rest[0] == 'W') && !unicode.IsSpace(runeAt(b, cur)) {
+				// "cw" changes to the end of the word like "ce".
+				m, _ = v.motion(e, []rune{map[rune]rune{'w': 'e', 'W': 'E'}[rest[0]]}, count)
+			}
+			v.operate(e, c, cur, m)
+		}
+		if c == 'd' {
+			v.finishChange()
+		} else if c == 'y' {
+			v.recording = nil
+		}
+	case 'x', 'X', 's', 'D', 'C':
+		motion := map[rune]string{'x': "dl", 'X': "dh", 's': "cl", 'D': "d$", 'C': "c$"}[c]
+		return v.run(e, []rune(strconv.Itoa(n)+motion))
+	case 'p', 'P':
+		v.paste(e, c == 'P', n)
+		v.finishChange()
+	case 'i', 'a', 'I', 'A', 'o', 'O':
+		switch c {
+		case 'a':
+			if cur.col < b.LineLen(cur.line) {
+				cur.col++
+			}
+		case 'I':
+			cur.col = firstNonBlank(b, cur.line)
+		case 'A':
+			cur.col = b.LineLen(cur.line)
+		case 'o':
+			e.Screen.SetPosition(cur.line, b.LineLen(cur.line))
+			e.Newline()
+			cur = v.cursor(e)
+		case 'O':
+			indent := leadingSpace(b.Lines[cur.line])
+			e.Screen.SetPosition(cur.line, 0)
+			e.InsertText(indent + "\n")
+			cur.col = utf8.RuneCountInString(indent)
+		}
+		e.Screen.SetPosition(cur.line, cur.col)
+		v.Mode = ViInsert
+	case '>', '<':
+		if len(rs) < 2 {
+			return viIncomplete
+		}
+		if rs[1] != c {
+			return viInvalid
+		}
+		level := 1
+		if c == '<' {
+			level = -1
+		}
+		e.IndentLines(cur.line, cur.line+n-1, level)
+		e.Screen.SetPosition(cur.line, firstNonBlank(b, cur.line))
+		v.finishChange()
+	case 'J':
+		for i := 0; i < n-1 || i == 0; i++ {
+			if cur.line+1 >= len(b.Lines) {
+				break
+			}
+			end := b.LineLen(cur.line)
+			next := firstNonBlank(b, cur.line+1)
+			rest := b.LineLen(cur.line+1) - next
+			e.DeleteRange(cur.line, end, cur.line+1, next)
+			if end > 0 && rest > 0 && !unicode.IsSpace(runeAt(b, viPos{cur.line, end - 1})) {
+				e.Screen.SetPosition(cur.line, end)
+				e.InsertText(" ")
+			}
+			e.Screen.SetPosition(cur.line, end)
+		}
+		v.finishChange()
+	case 'v', 'V':
+		v.anchorLine, v.anchorCol = cur.line, cur.col
+		v.Mode = ViVisual
+		if c == 'V' {
+			v.Mode = ViVisualLine
+		}
+		v.recording = nil
+	case 'm':
+		if len(rs) < 2 {
+			return viIncomplete
+		}
+		v.recording = nil
+		e.Error(e.SetMark(rs[1]))
+	case ':':
+		v.recording = nil
+		v.promptCommandLine(e)
+	case '.':
+		v.repeat(e, count)
+	case 'u':
+		v.recording = nil
+		for i := 0; i < n; i++ {
+			if err := e.undo(false); err != nil {
+				e.Error(err)
+				break
+			}
+		}
+	default:
+		m, st := v.motion(e, rs, count)
+		if st != viDone {
+			return st
+		}
+		v.recording = nil
+		v.moveTo(e, rs[0], m, n)
+	}
+	return viDone
+}
+
+// runVisual executes a command in the visual mode on the selection.
+func (v *Vi) runVisual(e *Editor, rs []rune) viStatus {
+	rs, st := v.parseRegister(rs)
+	if st != viDone {
+		return st
+	}
+	count, rs := parseCount(rs)
+	if len(rs) == 0 {
+		return viIncomplete
+	}
+	n := count
+	if n == 0 {
+		n = 1
+	}
+	cur := v.cursor(e)
+	from, to := viPos{v.anchorLine, v.anchorCol}, cur
+	if to.less(from) {
+		from, to = to, from
+	}
+	m := viMotion{viPos: to, inclusive: true, linewise: v.Mode == ViVisualLine}
+	switch c := rs[0]; c {
+	case 'd', 'x', 'c', 's', 'y':
+		op := map[rune]rune{'d': 'd', 'x': 'd', 'c': 'c', 's': 'c', 'y': 'y'}[c]
+		v.Mode = ViNormal
+		v.operate(e, op, from, m)
+		if op == 'd' {
+			v.finishChange()
+		}
+	case '>', '<':
+		level := n
+		if c == '<' {
+			level = -n
+		}
+		v.Mode = ViNormal
+		e.IndentLines(from.line, to.line, level)
+		e.Screen.SetPosition(from.line, firstNonBlank(e.Buffer, from.line))
+		v.finishChange()
+	case 'i', 'a':
+		if len(rs) < 2 {
+			return viIncomplete
+		}
+		f, t, ok := v.textObject(e.Buffer, cur, c == 'i', rs[1])
+		if !ok {
+			return viInvalid
+		}
+		v.anchorLine, v.anchorCol = f.line, f.col
+		t, _ = prevPos(e.Buffer, t)
+		e.Screen.SetPosition(t.line, t.col)
+	case 'o':
+		v.anchorLine, v.anchorCol, cur = cur.line, cur.col, viPos{v.anchorLine, v.anchorCol}
+		e.Screen.SetPosition(cur.line, cur.col)
+	case 'v', 'V':
+		mode := ViVisual
+		if c == 'V' {
+			mode = ViVisualLine
+		}
+		if v.Mode == mode {
+			v.Mode = ViNormal
+		} else {
+			v.Mode = mode
+		}
+	case ':':
+		v.Mode = ViNormal
+		v.promptCommandLine(e)
+	case '!':
+		v.Mode = ViNormal
+		v.filterLines(e, from.line, to.line)
+	default:
+		m, st := v.motion(e, rs, count)
+		if st != viDone {
+			return st
+		}
+		v.moveTo(e, rs[0], m, n)
+	}
+	v.recording = nil
+	return viDone
+}
+
+// parseRegister sets the register given as '"' and a name at the start of rs, and returns
+// the rest of the command.
+func (v *Vi) parseRegister(rs []rune) ([]rune, viStatus) {
+	if len(rs) == 0 || rs[0] != '"' {
+		return rs, viDone
+	}
+	if len(rs) < 2 {
+		return rs, viIncomplete
+	}
+	if !isRegisterName(rs[1]) {
+		return rs, viInvalid
+	}
+	v.register = rs[1]
+	return rs[2:], viDone
+}
+
+// setRegister stores the text yanked or deleted, also in the named register given.
+func (v *Vi) setRegister(e *Editor, r Register) {
+	v.Register = r
+	if v.register != 0 {
+		e.Error(e.SetRegister(v.register, r))
+	}
+}
+
+// Selection returns the range selected in the visual mode, the end being exclusive.
+func (v *Vi) Selection(e *Editor) (line, col, endLine, endCol int, ok bool) {
+	if v.Mode != ViVisual && v.Mode != ViVisualLine {
+		return 0, 0, 0, 0, false
+	}
+	from, to := viPos{v.anchorLine, v.anchorCol}, v.cursor(e)
+	if to.less(from) {
+		from, to = to, from
+	}
+	if v.Mode == ViVisualLine {
+		return from.line, 0, to.line, e.Buffer.LineLen(to.line) + 1, true
+	}
+	return from.line, from.col, to.line, to.col + 1, true
+}
+
+func (v *Vi) moveTo(e *Editor, c rune, m viMotion, n int) {
+	switch c {
+	case 'j':
+		e.Screen.MoveCursorVertically(n)
+		e.Screen.ScrollToCursor()
+	case 'k':
+		e.Screen.MoveCursorVertically(-n)
+		e.Screen.ScrollToCursor()
+	case 'G', 'g', '\'', '`':
+		e.PushJump()
+		e.Screen.SetPosition(m.line, m.col)
+	default:
+		e.Screen.SetPosition(m.line, m.col)
+	}
+}
+
+// operate applies the operator op to the text from cur to the motion target.
+func (v *Vi) operate(e *Editor, op rune, cur viPos, m viMotion) {
+	b := e.Buffer
+	from, to := cur, m.viPos
+	if to.less(from) {
+		from, to = to, from
+	}
+	if m.linewise {
+		from.col = 0
+		to.col = b.LineLen(to.line)
+		text := b.Text(from.line, from.col, to.line, to.col) + "\n"
+		v.setRegister(e, Register{Text: text, Linewise: true})
+		switch op {
+		case 'd':
+			switch {
+			case to.line+1 < len(b.Lines):
+				e.DeleteRange(from.line, 0, to.line+1, 0)
+			case from.line > 0:
+				e.DeleteRange(from.line-1, b.LineLen(from.line-1), to.line, to.col)
+				from.line--
+			default:
+				e.DeleteRange(from.line, 0, to.line, to.col)
+			}
+			e.Screen.SetPosition(from.line, firstNonBlank(b, from.line))
+		case 'c':
+			indent := firstNonBlank(b, from.line)
+			e.DeleteRange(from.line, indent, to.line, to.col)
+			e.Screen.SetPosition(from.line, indent)
+			v.Mode = ViInsert
+		case 'y':
+			e.Screen.SetPosition(from.line, cur.col)
+		}
+		return
+	}
+	if m.inclusive {
+		if next, ok := nextPos(b, to); ok {
+			to = next
+		} else {
+			to.col = b.LineLen(to.line)
+		}
+	}
+	v.setRegister(e, Register{Text: b.Text(from.line, from.col, to.line, to.col)})
+	switch op {
+	case 'd', 'c':
+		e.DeleteRange(from.line, from.col, to.line, to.col)
+		if op == 'c' {
+			v.Mode = ViInsert
+		}
+	}
+	e.Screen.SetPosition(from.line, from.col)
+}
+
+func (v *Vi) paste(e *Editor, before bool, n int) {
+	r := v.Register
+	if v.register != 0 {
+		var err error
+		if r, err = e.GetRegister(v.register); err != nil {
+			e.Error(err)
+			return
+		}
+	}
+	if r.Text == "" {
+		return
+	}
+	text := strings.Repeat(r.Text, n)
+	cur := v.cursor(e)
+	b := e.Buffer
+	if r.Linewise {
+		if before {
+			e.Screen.SetPosition(cur.line, 0)
+			e.InsertText(text)
+			e.Screen.SetPosition(cur.line, firstNonBlank(b, cur.line))
+			return
+		}
+		e.Screen.SetPosition(cur.line, b.LineLen(cur.line))
+		e.InsertText("\n" + strings.TrimSuffix(text, "\n"))
+		e.Screen.SetPosition(cur.line+1, firstNonBlank(b, cur.line+1))
+		return
+	}
+	if !before && cur.col < b.LineLen(cur.line) {
+		cur.col++
+	}
+	e.Screen.SetPosition(cur.line, cur.col)
+	e.InsertText(text)
+	line, col := e.Screen.Position()
+	e.Screen.SetPosition(line, col-1)
+}
+
+// repeat replays the last change.
+func (v *Vi) repeat(e *Editor, count int) {
+	keys := v.lastChange
+	if len(keys) == 0 {
+		return
+	}
+	if count > 0 {
+		// Replace the count of the last change.
+		i := 0
+		for i < len(keys) && !keys[i].IsControl() && unicode.IsDigit(keys[i].Value) && !(i == 0 && keys[i].Value == '0') {
+			i++
+		}
+		var ks []Key
+		for _, r := range strconv.Itoa(count) {
+			ks = append(ks, Key{Value: r})
+		}
+		keys = append(ks, keys[i:]...)
+	}
+	v.replaying = true
+	defer func() {
+		v.replaying = false
+		v.recording = nil
+	}()
+	v.keys = nil
+	for _, k := range keys {
+		v.HandleKey(e, k)
+	}
+}
+
+func (v *Vi) promptCommandLine(e *Editor) {
+	v.Mode = ViCommandLine
+	e.Prompt("vi", ":", nil, func(s string, ok bool) {
+		v.Mode = ViNormal
+		if ok {
+			v.ExecuteCommandLine(e, s)
+		}
+		v.clampCursor(e)
+	})
+}
+
+// ExecuteCommandLine runs an ex command such as "w", "q!" or "42".
+func (v *Vi) ExecuteCommandLine(e *Editor, s string) {
+	s = strings.TrimSpace(s)
+	if n, err := strconv.Atoi(s); err == nil {
+		// Line 0 is the first line as in vi, and the lines beyond the last one are the last one.
+		if n < 1 {
+			n = 1
+		}
+		from := e.currentJump()
+		if err := e.gotoLine(n-1, 0); err != nil {
+			e.Error(err)
+			return
+		}
+		e.Jumps.Push(from)
+		line, _ := e.Screen.Position()
+		e.Screen.SetPosition(line, firstNonBlank(e.Buffer, line))
+		return
+	}
+	switch {
+	case strings.HasPrefix(s, "!"):
+		e.Error(e.ShellCommand(s[1:]))
+		return
+	case strings.HasPrefix(s, "%!"):
+		e.Error(e.FilterBuffer(s[2:]))
+		return
+	case strings.HasPrefix(s, "r !"), strings.HasPrefix(s, "r!"), strings.HasPrefix(s, "read !"):
+		v.readShellCommand(e, s[strings.IndexByte(s, '!')+1:])
+		return
+	}
+	name, arg := s, ""
+	if i := strings.IndexByte(s, ' '); i >= 0 {
+		name, arg = s[:i], strings.TrimSpace(s[i+1:])
+	}
+	switch name {
+	case "w", "wq", "x":
+		if arg != "" {
+			e.setPath(e.Buffer, arg)
+		}
+		if name != "x" || e.Buffer.Dirty {
+			e.SaveBuffer()
+		}
+		if name != "w" && !e.Buffer.Dirty {
+			v.quitWindow(e, false)
+		}
+	case "q":
+		if e.Buffer.Dirty {
+			e.Error(errors.New("no write since last change (add ! to override)"))
+			return
+		}
+		v.quitWindow(e, false)
+	case "q!":
+		v.quitWindow(e, true)
+	case "qa":
+		e.Execute("quit")
+	case "qa!":
+		e.quit = true
+	case "e", "edit":
+		if arg == "" {
+			return
+		}
+		e.Error(e.OpenFile(arg))
+	case "go", "goto":
+		n, err := strconv.ParseInt(arg, 10, 64)
+		if arg == "" {
+			n, err = 1, nil
+		}
+		if err != nil || n <= 0 {
+			e.Error(fmt.Errorf("invalid byte count: %s", arg))
+			return
+		}
+		e.Error(e.Goto("#" + strconv.FormatInt(n-1, 10)))
+	case "sp", "split":
+		e.SplitWindow(false)
+	case "vs", "vsplit":
+		e.SplitWindow(true)
+	case "set":
+		switch arg {
+		case "novi":
+			e.SetViMode(false)
+		case "wrap":
+			e.SetWrap(true)
+		case "nowrap":
+			e.SetWrap(false)
+		case "lbr", "linebreak":
+			e.SetWordWrap(true)
+		case "nolbr", "nolinebreak":
+			e.SetWordWrap(false)
+		case "nu", "number":
+			e.SetLineNumbers(LineNumbersAbsolute)
+		case "rnu", "relativenumber":
+			e.SetLineNumbers(LineNumbersRelative)
+		case "nonu", "nonumber":
+			e.SetLineNumbers(LineNumbersOff)
+		case "nornu", "norelativenumber":
+			if e.Buffer.LineNumbers == LineNumbersRelative {
+				e.SetLineNumbers(LineNumbersAbsolute)
+			}
+		case "ff=unix", "fileformat=unix":
+			e.SetLineEnding("LF")
+		case "ff=dos", "fileformat=dos":
+			e.SetLineEnding("CRLF")
+		default:
+			e.Error(fmt.Errorf("unknown option: %s", arg))
+		}
+	default:
+		if _, ok := e.Commands[s]; !ok {
+			e.Error(fmt.Errorf("not an editor command: %s", s))
+			return
+		}
+		e.Execute(s)
+	}
+}
+
+// readShellCommand inserts the output of command below the line of the cursor.
+func (v *Vi) readShellCommand(e *Editor, command string) {
+	out, err := e.RunShell(command, nil)
+	if err != nil || out == "" {
+		e.Error(err)
+		return
+	}
+	line, _ := e.Screen.Position()
+	e.Screen.SetPosition(line, e.Buffer.LineLen(line))
+	e.InsertText("\n" + strings.TrimSuffix(out, "\n"))
+	e.Screen.SetPosition(line+1, firstNonBlank(e.Buffer, line+1))
+}
+
+// filterLines replaces the lines from line to endLine with the output of a shell command
+// reading them.
+func (v *Vi) filterLines(e *Editor, line, endLine int) {
+	e.Prompt("shell", "!", nil, func(command string, ok bool) {
+		if !ok {
+			return
+		}
+		b := e.Buffer
+		if endLine+1 < len(b.Lines) {
+			e.Error(e.FilterRegion(line, 0, endLine+1, 0, command))
+		} else {
+			e.Error(e.FilterRegion(line, 0, endLine, b.LineLen(endLine), command))
+		}
+		v.clampCursor(e)
+	})
+}
+
+// quitWindow closes the current window, or exits the editor in the last one. With force, the
+// changes of the buffers are discarded without asking.
+func (v *Vi) quitWindow(e *Editor, force bool) {
+	switch {
+	case len(e.Layout.Windows()) > 1:
+		e.CloseWindow()
+	case force:
+		e.quit = true
+	default:
+		e.Execute("quit")
+	}
+}
+
+func (v *Vi) cursor(e *Editor) viPos {
+	line, col := e.Screen.Position()
+	return viPos{line, col}
+}
+
+// clampCursor keeps the cursor on a character as vi does in the normal mode.
+func (v *Vi) clampCursor(e *Editor) {
+	if e.Screen == nil {
+		return
+	}
+	line, col := e.Screen.Position()
+	if l := e.Buffer.LineLen(line); col >= l && l > 0 {
+		e.Screen.SetPosition(line, l-1)
+	}
+}
+
+// motion returns the target of the motion rs from the cursor.
+func (v *Vi) motion(e *Editor, rs []rune, count int) (viMotion, viStatus) {
+	n := count
+	if n == 0 {
+		n = 1
+	}
+	b := e.Buffer
+	cur := v.cursor(e)
+	m := viMotion{viPos: cur}
+	switch rs[0] {
+	case 'h':
+		m.col -= n
+		if m.col < 0 {
+			m.col = 0
+		}
+	case 'l', ' ':
+		m.col += n
+		if l := b.LineLen(cur.line); m.col > l {
+			m.col = l
+		}
+	case 'j', '+':
+		m.line += n
+		m.linewise = true
+	case 'k', '-':
+		m.line -= n
+		m.linewise = true
+	case 'w', 'W':
+		for i := 0; i < n; i++ {
+			m.viPos = wordForward(b, m.viPos, rs[0] == 'W')
+		}
+	case 'b', 'B':
+		for i := 0; i < n; i++ {
+			m.viPos = wordBackward(b, m.viPos, rs[0] == 'B')
+		}
+	case 'e', 'E':
+		for i := 0; i < n; i++ {
+			m.viPos = wordEnd(b, m.viPos, rs[0] == 'E')
+		}
+		m.inclusive = true
+	case '0':
+		m.col = 0
+	case '^':
+		m.col = firstNonBlank(b, cur.line)
+	case '$':
+		m.line += n - 1
+		if m.line >= len(b.Lines) {
+			m.line = len(b.Lines) - 1
+		}
+		m.col = b.LineLen(m.line)
+	case 'G':
+		m.line = len(b.Lines) - 1
+		if count > 0 {
+			m.line = count - 1
+		}
+		m.linewise = true
+	case 'g':
+		if len(rs) < 2 {
+			return m, viIncomplete
+		}
+		if rs[1] != 'g' {
+			return m, viInvalid
+		}
+		m.line = n - 1
+		m.linewise = true
+	case '\'', '`':
+		if len(rs) < 2 {
+			return m, viIncomplete
+		}
+		mark, err := e.GetMark(rs[1])
+		if err != nil || mark.Line < b.FirstLine || mark.Line >= b.FirstLine+len(b.Lines) {
+			e.Error(err)
+			return m, viInvalid
+		}
+		m.line, m.col = mark.Line-b.FirstLine, mark.Col
+		if l := b.LineLen(m.line); m.col > l {
+			m.col = l
+		}
+		m.linewise = rs[0] == '\''
+	case 'f', 't', 'F', 'T':
+		if len(rs) < 2 {
+			return m, viIncomplete
+		}
+		line := []rune(b.Lines[cur.line])
+		col := cur.col
+		for i := 0; i < n; i++ {
+			col = findInLine(line, col, rs[1], rs[0] == 'f' || rs[0] == 't')
+			if col < 0 {
+				return m, viInvalid
+			}
+		}
+		switch rs[0] {
+		case 't':
+			col--
+		case 'T':
+			col++
+		}
+		m.col = col
+		m.inclusive = rs[0] == 'f' || rs[0] == 't'
+	default:
+		return m, viInvalid
+	}
+	if m.line < 0 {
+		m.line = 0
+	}
+	if m.line >= len(b.Lines) {
+		m.line = len(b.Lines) - 1
+	}
+	if m.linewise && rs[0] != 'j' && rs[0] != 'k' {
+		m.col = firstNonBlank(b, m.line)
+	}
+	return m, viDone
+}
+
+// textObject returns the range of the text object such as a word or a quoted string at p.
+func (v *Vi) textObject(b *Buffer, p viPos, inner bool, obj rune) (viPos, viPos, bool) {
+	switch obj {
+	case 'w', 'W':
+		line := []rune(b.Lines[p.line])
+		if len(line) == 0 {
+			return p, p, false
+		}
+		big := obj == 'W'
+		cls := charClass(line[p.col], big)
+		from, to := p.col, p.col
+		for from > 0 && charClass(line[from-1], big) == cls {
+			from--
+		}
+		for to < len(line) && charClass(line[to], big) == cls {
+			to++
+		}
+		if !inner {
+			if to < len(line) && unicode.IsSpace(line[to]) {
+				for to < len(line) && unicode.IsSpace(line[to]) {
+					to++
+				}
+			} else {
+				for from > 0 && unicode.IsSpace(line[from-1]) {
+					from--
+				}
+			}
+		}
+		return viPos{p.line, from}, viPos{p.line, to}, true
+	case '"', '\'', '`':
+		line := []rune(b.Lines[p.line])
+		var qs []int
+		for i, r := range line {
+			if r == obj && (i == 0 || line[i-1] != '\\') {
+				qs = append(qs, i)
+			}
+		}
+		for i := 0; i+1 < len(qs); i += 2 {
+			if p.col > qs[i+1] {
+				continue
+			}
+			// Like vi, this selects the first quoted string after the cursor if it is not in any.
+			from, to := qs[i], qs[i+1]+1
+			if inner {
+				return viPos{p.line, from + 1}, viPos{p.line, to - 1}, true
+			}
+			for to < len(line) && unicode.IsSpace(line[to]) {
+				to++
+			}
+			return viPos{p.line, from}, viPos{p.line, to}, true
+		}
+		return p, p, false
+	}
+	pairs := map[rune][2]rune{
+		'(': {'(', ')'}, ')': {'(', ')'}, 'b': {'(', ')'},
+		'{': {'{', '}'}, '}': {'{', '}'}, 'B': {'{', '}'},
+		'[': {'[', ']'}, ']': {'[', ']'},
+		'<': {'<', '>'}, '>': {'<', '>'},
+	}
+	pair, ok := pairs[obj]
+	if !ok {
+		return p, p, false
+	}
+	open, ok := findUnmatched(b, p, pair[1], pair[0], false)
+	if !ok {
+		return p, p, false
+	}
+	start, _ := nextPos(b, open)
+	end, ok := findUnmatched(b, start, pair[0], pair[1], true)
+	if !ok {
+		return p, p, false
+	}
+	if inner {
+		return start, end, true
+	}
+	after, _ := nextPos(b, end)
+	if after == end {
+		after.col++
+	}
+	return open, after, true
+}
+
+// findUnmatched searches from p for the rune target not balanced by other.
+// It searches forward if forward is set, or backward including p otherwise.
+func findUnmatched(b *Buffer, p viPos, other, target rune, forward bool) (viPos, bool) {
+	depth := 0
+	if !forward && runeAt(b, p) == other {
+		// The cursor is on the closing rune.
+		var ok bool
+		if p, ok = prevPos(b, p); !ok {
+			return p, false
+		}
+	}
+	for {
+		switch runeAt(b, p) {
+		case target:
+			if depth == 0 {
+				return p, true
+			}
+			depth--
+		case other:
+			depth++
+		}
+		var ok bool
+		if forward {
+			p, ok = nextPos(b, p)
+		} else {
+			p, ok = prevPos(b, p)
+		}
+		if !ok {
+			return p, false
+		}
+	}
+}
+
+func parseCount(rs []rune) (int, []rune) {
+	i := 0
+	for i < len(rs) && unicode.IsDigit(rs[i]) && !(i == 0 && rs[i] == '0') {
+		i++
+	}
+	if i == 0 {
+		return 0, rs
+	}
+	n, _ := strconv.Atoi(string(rs[:i]))
+	return n, rs[i:]
+}
+
+// runeAt returns the rune at p, or '\n' at the end of a line.
+func runeAt(b *Buffer, p viPos) rune {
+	line := []rune(b.Lines[p.line])
+	if p.col >= len(line) {
+		return '\n'
+	}
+	return line[p.col]
+}
+
+func nextPos(b *Buffer, p viPos) (viPos, bool) {
+	if p.col < b.LineLen(p.line) {
+		return viPos{p.line, p.col + 1}, true
+	}
+	if p.line+1 < len(b.Lines) {
+		return viPos{p.line + 1, 0}, true
+	}
+	return p, false
+}
+
+func prevPos(b *Buffer, p viPos) (viPos, bool) {
+	if p.col > 0 {
+		return viPos{p.line, p.col - 1}, true
+	}
+	if p.line > 0 {
+		return viPos{p.line - 1, b.LineLen(p.line - 1)}, true
+	}
+	return p, false
+}
+
+// charClass classifies runes into spaces (0), punctuations (1) and word characters (2).
+// Big words consist of any non-space runes.
+func charClass(r rune, big bool) int {
+	switch {
+	case unicode.IsSpace(r):
+		return 0
+	case big || r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r):
+		return 2
+	default:
+		return 1
+	}
+}
+
+func wordForward(b *Buffer, p viPos, big bool) viPos {
+	cls := charClass(runeAt(b, p), big)
+	var ok bool
+	for cls != 0 && charClass(runeAt(b, p), big) == cls {
+		if p, ok = nextPos(b, p); !ok {
+			return p
+		}
+	}
+	for charClass(runeAt(b, p), big) == 0 {
+		line := p.line
+		if p, ok = nextPos(b, p); !ok {
+			return p
+		}
+		if p.line != line && b.LineLen(p.line) == 0 {
+			// An empty line is a word.
+			return p
+		}
+	}
+	return p
+}
+
+func wordBackward(b *Buffer, p viPos, big bool) viPos {
+	var ok bool
+	if p, ok = prevPos(b, p); !ok {
+		return p
+	}
+	for charClass(runeAt(b, p), big) == 0 {
+		if p.col == 0 && b.LineLen(p.line) == 0 {
+			return p
+		}
+		if p, ok = prevPos(b, p); !ok {
+			return p
+		}
+	}
+	cls := charClass(runeAt(b, p), big)
+	for p.col > 0 {
+		q := viPos{p.line, p.col - 1}
+		if charClass(runeAt(b, q), big) != cls {
+			break
+		}
+		p = q
+	}
+	return p
+}
+
+func wordEnd(b *Buffer, p viPos, big bool) viPos {
+	var ok bool
+	if p, ok = nextPos(b, p); !ok {
+		return p
+	}
+	for charClass(runeAt(b, p), big) == 0 {
+		if p, ok = nextPos(b, p); !ok {
+			return p
+		}
+	}
+	cls := charClass(runeAt(b, p), big)
+	for p.col+1 < b.LineLen(p.line) && charClass(runeAt(b, viPos{p.line, p.col + 1}), big) == cls {
+		p.col++
+	}
+	return p
+}
+
+func firstNonBlank(b *Buffer, line int) int {
+	for i, r := range []rune(b.Lines[line]) {
+		if !unicode.IsSpace(r) {
+			return i
+		}
+	}
+	return 0
+}
+
+// findInLine returns the offset of the next (or previous) r from col, or -1.
+func findInLine(line []rune, col int, r rune, forward bool) int {
+	if forward {
+		for i := col + 1; i < len(line); i++ {
+			if line[i] == r {
+				return i
+			}
+		}
+		return -1
+	}
+	for i := col - 1; i >= 0; i-- {
+		if line[i] == r {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,339 @@
+package editor_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/mizuochikeita/re/editor"
+)
+
+func TestVi(t *testing.T) {
+	const esc = "\x1b"
+	newEditor := func(lines []string, line, col int) *editor.Editor {
+		e := editor.New()
+		b := editor.NewBuffer("test")
+		b.Lines = lines
+		e.AddBuffer(b)
+		e.Layout.Arrange(0, 0, 80, 24)
+		e.Screen.SetPosition(line, col)
+		e.SetViMode(true)
+		return e
+	}
+	typeKeys := func(e *editor.Editor, keys string) {
+		for _, r := range keys {
+			e.Vi.HandleKey(e, editor.Key{Value: r})
+		}
+	}
+
+	t.Run("HandleKey()", func(t *testing.T) {
+		tests := []struct {
+			desc      string
+			lines     []string
+			line      int
+			col       int
+			keys      string
+			wantLines []string
+			wantPos   [2]int
+			wantMode  editor.ViMode
+		}{
+			{
+				desc:      "delete a word",
+				lines:     []string{"foo bar"},
+				keys:      "dw",
+				wantLines: []string{"bar"},
+				wantPos:   [2]int{0, 0},
+			},
+			{
+				desc:      "delete words with count in motion",
+				lines:     []string{"a b c d"},
+				keys:      "d2w",
+				wantLines: []string{"c d"},
+			},
+			{
+				desc:      "delete words with count before operator",
+				lines:     []string{"a b c d"},
+				keys:      "2dw",
+				wantLines: []string{"c d"},
+			},
+			{
+				desc:      "change inside quotes",
+				lines:     []string{`x = "hello"`},
+				col:       6,
+				keys:      `ci"bye` + esc,
+				wantLines: []string{`x = "bye"`},
+				wantPos:   [2]int{0, 7},
+			},
+			{
+				desc:      "delete inside parentheses",
+				lines:     []string{"f(a, (b))"},
+				col:       2,
+				keys:      "di(",
+				wantLines: []string{"f()"},
+				wantPos:   [2]int{0, 2},
+			},
+			{
+				desc:      "delete around parentheses over lines",
+				lines:     []string{"f(", "  a", ")", "x"},
+				line:      1,
+				col:       2,
+				keys:      "da)",
+				wantLines: []string{"f", "x"},
+				wantPos:   [2]int{0, 0},
+			},
+			{
+				desc:      "delete lines",
+				lines:     []string{"a", "b", "c", "d"},
+				line:      1,
+				keys:      "2dd",
+				wantLines: []string{"a", "d"},
+				wantPos:   [2]int{1, 0},
+			},
+			{
+				desc:      "delete last line",
+				lines:     []string{"a", "b"},
+				line:      1,
+				keys:      "dd",
+				wantLines: []string{"a"},
+				wantPos:   [2]int{0, 0},
+			},
+			{
+				desc:      "yank and paste a line",
+				lines:     []string{"a", "b"},
+				keys:      "yyjp",
+				wantLines: []string{"a", "b", "a"},
+				wantPos:   [2]int{2, 0},
+			},
+			{
+				desc:      "delete to end of line and paste",
+				lines:     []string{"abc def"},
+				col:       3,
+				keys:      "D0P",
+				wantLines: []string{" defabc"},
+				wantPos:   [2]int{0, 3},
+			},
+			{
+				desc:      "repeat delete",
+				lines:     []string{"abcdef"},
+				keys:      "x..",
+				wantLines: []string{"def"},
+			},
+			{
+				desc:      "repeat change",
+				lines:     []string{"foo", "bar"},
+				keys:      "cwbaz" + esc + "j0.",
+				wantLines: []string{"baz", "baz"},
+				wantPos:   [2]int{1, 2},
+			},
+			{
+				desc:      "repeat with count",
+				lines:     []string{"a b c d e"},
+				keys:      "dw2.",
+				wantLines: []string{"d e"},
+			},
+			{
+				desc:      "append to line",
+				lines:     []string{"abc"},
+				keys:      "A!" + esc,
+				wantLines: []string{"abc!"},
+				wantPos:   [2]int{0, 3},
+			},
+			{
+				desc:      "open line below",
+				lines:     []string{"a", "c"},
+				keys:      "ob" + esc,
+				wantLines: []string{"a", "b", "c"},
+				wantPos:   [2]int{1, 0},
+			},
+			{
+				desc:      "stay in insert mode",
+				lines:     []string{"a"},
+				keys:      "ib",
+				wantLines: []string{"ba"},
+				wantPos:   [2]int{0, 1},
+				wantMode:  editor.ViInsert,
+			},
+			{
+				desc:      "go to line",
+				lines:     []string{"a", "  b", "c"},
+				keys:      "2G",
+				wantLines: []string{"a", "  b", "c"},
+				wantPos:   [2]int{1, 2},
+			},
+			{
+				desc:      "move to end of words",
+				lines:     []string{"foo.bar baz"},
+				keys:      "3e",
+				wantLines: []string{"foo.bar baz"},
+				wantPos:   [2]int{0, 6},
+			},
+			{
+				desc:      "find in line",
+				lines:     []string{"a,b,c"},
+				keys:      "dt,",
+				wantLines: []string{",b,c"},
+			},
+			{
+				desc:      "join lines",
+				lines:     []string{"a", "  b"},
+				keys:      "J",
+				wantLines: []string{"a b"},
+				wantPos:   [2]int{0, 1},
+			},
+			{
+				desc:      "delete visual selection",
+				lines:     []string{"abcdef"},
+				col:       1,
+				keys:      "vlld",
+				wantLines: []string{"aef"},
+				wantPos:   [2]int{0, 1},
+			},
+			{
+				desc:      "change visual line selection",
+				lines:     []string{"a", "b", "c"},
+				keys:      "Vjcx" + esc,
+				wantLines: []string{"x", "c"},
+				wantPos:   [2]int{0, 0},
+			},
+			{
+				desc:      "indent lines",
+				lines:     []string{"a", "b", "c"},
+				keys:      "2>>",
+				wantLines: []string{"\ta", "\tb", "c"},
+				wantPos:   [2]int{0, 1},
+			},
+			{
+				desc:      "dedent a visual selection",
+				lines:     []string{"  a", "  b", "  c"},
+				line:      1,
+				keys:      "Vj<",
+				wantLines: []string{"  a", "b", "c"},
+				wantPos:   [2]int{1, 0},
+			},
+			{
+				desc:      "open lines with the indentation",
+				lines:     []string{"\tfoo"},
+				keys:      "ox" + esc + "Oy" + esc,
+				wantLines: []string{"\tfoo", "\ty", "\tx"},
+				wantPos:   [2]int{1, 1},
+			},
+			{
+				desc:      "jump to a mark moved by an edit above",
+				lines:     []string{"a", "  b", "c"},
+				line:      1,
+				col:       2,
+				keys:      "magg" + "Ox" + esc + "G`a",
+				wantLines: []string{"x", "a", "  b", "c"},
+				wantPos:   [2]int{2, 2},
+			},
+			{
+				desc:      "delete to the line of a mark",
+				lines:     []string{"a", "b", "c", "d"},
+				line:      2,
+				keys:      "mbggd'b",
+				wantLines: []string{"d"},
+				wantPos:   [2]int{0, 0},
+			},
+			{
+				desc:      "yank to a named register and put it",
+				lines:     []string{"foo bar"},
+				keys:      `"ayw` + "w" + `yw` + `"aP`,
+				wantLines: []string{"foo foo bar"},
+				wantPos:   [2]int{0, 7},
+			},
+			{
+				desc:      "append to a named register",
+				lines:     []string{"a", "b", "c"},
+				keys:      `"xyyj"Xyyj"xp`,
+				wantLines: []string{"a", "b", "c", "a", "b"},
+				wantPos:   [2]int{3, 0},
+			},
+			{
+				desc:      "delete characters to a named register",
+				lines:     []string{"abc"},
+				keys:      `"q2x$"qp`,
+				wantLines: []string{"cab"},
+				wantPos:   [2]int{0, 2},
+			},
+		}
+		for _, tt := range tests {
+			e := newEditor(tt.lines, tt.line, tt.col)
+			typeKeys(e, tt.keys)
+			if diff := cmp.Diff(tt.wantLines, e.Buffer.Lines); diff != "" {
+				t.Errorf("%s: %s", tt.desc, diff)
+			}
+			line, col := e.Screen.Position()
+			if diff := cmp.Diff(tt.wantPos, [2]int{line, col}); diff != "" {
+				t.Errorf("%s: %s", tt.desc, diff)
+			}
+			if diff := cmp.Diff(tt.wantMode, e.Vi.Mode); diff != "" {
+				t.Errorf("%s: %s", tt.desc, diff)
+			}
+		}
+	})
+
+	t.Run("ExecuteCommandLine()", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "a.txt")
+		e := newEditor([]string{"a", "b"}, 0, 0)
+		// ESC cancels the command line.
+		for _, r := range ":w" + esc {
+			e.HandleKey(editor.Key{Value: r}, func() {})
+		}
+		if diff := cmp.Diff([2]interface{}{true, editor.ViNormal}, [2]interface{}{e.Minibuffer == nil, e.Vi.Mode}); diff != "" {
+			t.Error(diff)
+		}
+		e.Vi.ExecuteCommandLine(e, "w "+path)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if diff := cmp.Diff("a\nb\n", string(data)); diff != "" {
+			t.Error(diff)
+		}
+		for _, tt := range []struct {
+			s    string
+			want int
+		}{
+			{s: "2", want: 1},
+			{s: "100", want: 1},
+			{s: "0", want: 0},
+		} {
+			e.Vi.ExecuteCommandLine(e, tt.s)
+			line, _ := e.Screen.Position()
+			if diff := cmp.Diff(tt.want, line); diff != "" {
+				t.Errorf("%q: %s", tt.s, diff)
+			}
+		}
+
+		// q! discards the changes of all the buffers.
+		e.InsertText("x")
+		other := editor.NewBuffer("other")
+		other.Dirty = true
+		e.AddBuffer(other)
+		quit := false
+		for _, r := range ":q!\r" {
+			e.HandleKey(editor.Key{Value: r}, func() { quit = true })
+		}
+		if diff := cmp.Diff(true, quit); diff != "" {
+			t.Error(diff)
+		}
+
+		null, err := os.Open(os.DevNull)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer null.Close()
+		e = newEditor([]string{"b", "a"}, 0, 0)
+		e.In = null
+		e.Vi.ExecuteCommandLine(e, "%!sort")
+		e.Vi.ExecuteCommandLine(e, "r !echo c")
+		if diff := cmp.Diff([]string{"a", "c", "b"}, e.Buffer.Lines); diff != "" {
+			t.Error(diff)
+		}
+		line, col := e.Screen.Position()
+		if diff := cmp.Diff([2]int{1, 0}, [2]int{line, col}); diff != "" {
+			t.Error(diff)
+		}
+	})
+}
@@ -0,0 +1,112 @@
+package editor
+
+// Window shows a buffer in a rectangle of the terminal.
+// The first row of the rectangle is the status line of the window.
+type Window struct {
+	Buffer *Buffer
+	Screen *Screen
+	X      int
+	Y      int
+	Width  int
+	Height int
+}
+
+func NewWindow(b *Buffer) *Window {
+	w := &Window{
+		Screen: &Screen{},
+	}
+	w.SetBuffer(b)
+	return w
+}
+
+// SetBuffer shows b in the window, restoring the position where b was viewed last.
+func (w *Window) SetBuffer(b *Buffer) {
+	if w.Buffer == b {
+		return
+	}
+	if w.Buffer != nil {
+		w.Buffer.Line, w.Buffer.Col = w.Screen.Position()
+		w.Buffer.Vscroll = w.Screen.Vscroll
+	}
+	w.Buffer = b
+	w.update()
+	w.Screen.Vscroll = b.Vscroll
+	w.Screen.SetPosition(b.Line, b.Col)
+}
+
+// Resize moves the window to the rectangle, rewrapping the buffer if its width changed.
+func (w *Window) Resize(x, y, width, height int) {
+	resized := w.Width != width
+	w.X, w.Y, w.Width, w.Height = x, y, width, height
+	s := w.Screen
+	s.Height = height - 1 // for status line
+	if !resized {
+		s.ScrollToCursor()
+		return
+	}
+	line, col := s.Position()
+	if s.HexBytes > 0 {
+		// Keep the cursor on the same byte with another number of bytes in a row.
+		line, col = line*s.HexBytes+col, 0
+	}
+	w.update()
+	if s.HexBytes > 0 {
+		line, col = line/s.HexBytes, line%s.HexBytes
+	}
+	s.SetPosition(line, col)
+}
+
+// Refresh rewraps the buffer after c and keeps the cursor on the same text.
+func (w *Window) Refresh(c Change) {
+	s := w.Screen
+	line, col := s.Position()
+	if w.Buffer.HexMode {
+		// Overwriting bytes changes only their row.
+		if c.OldEndCol == c.NewEndCol && s.HexBytes > 0 {
+			s.UpdateHexRow(w.Buffer.Data, w.Buffer.Offset, c.Col/s.HexBytes)
+		} else {
+			w.update()
+		}
+		_, pos := c.Adjust(0, line*s.HexBytes+col)
+		s.SetPosition(pos/s.HexBytes, pos%s.HexBytes)
+		return
+	}
+	if w.gutterWidth() != s.Gutter {
+		// The text is narrower or wider with another number of digits in the line numbers.
+		w.update()
+	} else {
+		s.UpdateLines(w.Buffer.Lines, c)
+	}
+	s.SetPosition(c.Adjust(line, col))
+}
+
+// Reload lays out the buffer again after its whole text is replaced, keeping the cursor position.
+func (w *Window) Reload() {
+	line, col := w.Screen.Position()
+	w.update()
+	w.Screen.SetPosition(line, col)
+}
+
+// update lays out the whole buffer on the screen, in the width of the window left of the gutter.
+func (w *Window) update() {
+	s := w.Screen
+	s.Gutter = w.gutterWidth()
+	s.Width = w.Width - s.Gutter
+	if w.Buffer.HexMode {
+		w.Screen.UpdateHex(w.Buffer.Data, w.Buffer.Offset)
+		return
+	}
+	w.Screen.NoWrap = w.Buffer.NoWrap
+	w.Screen.Wrap = w.Buffer.Wrap
+	w.Screen.TabWidth = w.Buffer.Indent.tabWidth()
+	w.Screen.Update(w.Buffer.Lines)
+}
+
+// gutterWidth returns the width of the gutter of the buffer, or 0 if the window is too narrow for it.
+func (w *Window) gutterWidth() int {
+	g := gutterWidth(w.Buffer)
+	if g >= w.Width/2 {
+		return 0
+	}
+	return g
+}
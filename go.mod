@@ -3,7 +3,8 @@ module github.com/mizuochikeita/re
 go 1.17
 
 require (
-	github.com/google/go-cmp v0.5.8 // indirect
-	github.com/pkg/term v1.1.0 // indirect
-	golang.org/x/sys v0.0.0-20200909081042-eff7692f9009 // indirect
+	github.com/pkg/term v1.1.0
+	golang.org/x/sys v0.0.0-20200909081042-eff7692f9009
 )
+
+require github.com/google/go-cmp v0.5.8
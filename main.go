@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"fmt"
 	"os"
 	"os/signal"
 	"syscall"
@@ -10,23 +11,81 @@ import (
 )
 
 func main() {
+	args := editor.ParseArgs(os.Args[1:])
+	e := editor.New()
+	if err := e.OpenTerminal(); err != nil {
+		fmt.Fprintln(os.Stderr, "re:", err)
+		os.Exit(1)
+	}
+
+	e.Error(e.LoadConfig())
+	e.Error(e.LoadKeymap())
+	if args.Filter && len(args.Files) == 0 && !editor.IsTerminal(os.Stdin) {
+		args.Files = []editor.FileArg{{Path: "-"}}
+	}
+	for _, fa := range args.Files {
+		e.Error(e.OpenFileArg(fa))
+	}
+	if len(e.Buffers) == 0 {
+		e.AddBuffer(editor.NewBuffer("*scratch*"))
+	}
+	// The buffers are in the order they were opened until the first switch.
+	first := e.Buffers[0]
+	e.SwitchBuffer(first)
+	if args.Filter {
+		e.Output = first
+	}
+
+	if sig := run(e); sig != 0 {
+		// Killed, the editor keeps the changes not saved in copies of the buffers.
+		paths, err := e.SaveEmergencyCopies()
+		for _, path := range paths {
+			fmt.Fprintf(os.Stderr, "re: %s: saved changes to %s\n", sig, path)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "re:", err)
+		}
+		os.Exit(128 + int(sig))
+	}
+
+	if args.Filter {
+		if _, err := first.WriteTo(os.Stdout); err != nil {
+			fmt.Fprintln(os.Stderr, "re:", err)
+			os.Exit(1)
+		}
+	}
+}
+
+// run reads and handles keys until the editor quits, or a signal terminating it is received,
+// which it returns.
+func run(e *editor.Editor) syscall.Signal {
 	ctx, cancel := context.WithCancel(context.Background())
-	ctx, stop := signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
-	defer stop()
+	defer cancel()
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP)
+	defer signal.Stop(sigs)
 
-	e := editor.New()
 	if err := e.SetRawMode(); err != nil {
 		panic(err)
 	}
 	defer e.ResetRawMode()
-
-	if err := e.OpenFile(os.Args[1]); err != nil {
-		panic(err)
-	}
+	e.EnterAlternateScreen()
+	defer e.LeaveAlternateScreen()
 	if err := e.RefreshScreen(); err != nil {
 		panic(err)
 	}
-	for k := range e.ReadKey(ctx) {
-		e.HandleKey(k, cancel)
+	keys := e.ReadKey(ctx)
+	for {
+		select {
+		case k, ok := <-keys:
+			if !ok {
+				return 0
+			}
+			e.HandleKey(k, cancel)
+		case <-e.Wakeup():
+			e.HandleWakeup()
+		case sig := <-sigs:
+			return sig.(syscall.Signal)
+		}
 	}
 }